@@ -1,27 +1,38 @@
 package cmd
 
 import (
-	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/user"
 	"regexp"
 	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
-	trqhelper "github.com/Donders-Institute/hpc-torque-helper/pkg/client"
 	dg "github.com/Donders-Institute/hpc-utility/internal/datagetter"
+	"github.com/Donders-Institute/hpc-utility/internal/history"
+	"github.com/Donders-Institute/hpc-utility/internal/hostlist"
+	"github.com/Donders-Institute/hpc-utility/internal/machinelist"
+	"github.com/Donders-Institute/hpc-utility/internal/node"
+	"github.com/Donders-Institute/hpc-utility/internal/output"
+	"github.com/Donders-Institute/hpc-utility/internal/scheduler"
 	"github.com/Donders-Institute/hpc-utility/internal/slurm"
 	"github.com/Donders-Institute/hpc-utility/internal/util"
+	"github.com/Donders-Institute/hpc-utility/internal/vnc"
 	"github.com/olekukonko/tablewriter"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var xml bool
+var schedulerName string
 
 const (
 	gib float64 = 1024 * 1024 * 1024
@@ -30,8 +41,21 @@ const (
 // variable may be set at the build time to fix the default location for the TorqueHelper server certificate.
 var defTorqueHelperCert string
 var defMachineListFile string
+var nodeMachineListFile string
+var nodeGroups []string
 var vncUser string
-var vncMachineListFile string
+var jobListUsers []string
+var jobTraceSince string
+var jobTraceUntil string
+var jobMeminfoWatch time.Duration
+var vncSources []string
+var vncStartHost string
+var vncStartGeometry string
+var vncGateway string
+var vncStale bool
+var vncStaleSummary bool
+var vncMaxIdle time.Duration
+var vncMaxSessions int
 
 // switches for node resource display.
 var nodeResourceShowAll bool
@@ -39,32 +63,119 @@ var nodeResourceShowProcs bool
 var nodeResourceShowGpus bool
 var nodeResourceShowMemGB bool
 var nodeResourceShowDiskGB bool
+var nodeResourceShowLoad bool
+var nodeResourceShowBootTime bool
 var nodeResourceShowFeatures []string
-
-// this list of features consists of Torque node features and Slurm partitions
-var nodeResourceDefFeatures []string = []string{"matlab", "cuda", "vgl", "lcmodel", "gpu", "batch"}
+var nodeResourceSummary bool
+var nodeResourceReasons bool
+var nodeStatusWatch time.Duration
+var nodeStatusRecord string
+
+// options of the node history.
+var nodeHistoryFrom string
+var nodeHistorySince string
+var nodeHistoryUntil string
+var nodeHistoryBy string
+var nodeHistoryStep time.Duration
+var nodeHistoryDowntime bool
+var nodeHistoryMaxGap time.Duration
+
+// criteria for node selection.
+var nodeFilterClusters []string
+var nodeFilterStates []string
+var nodeFilterPartitions []string
+var nodeFilterMinFreeProcs int
+var nodeFilterMinFreeMem string
+var nodeFilterGPUModel string
+var nodeFilterVendor string
+
+// resource requirements of a job for the node fit.
+var nodeFitProcs int
+var nodeFitMem string
+var nodeFitGPUs int
+var nodeFitGPUModel string
+var nodeFitTmp string
+var nodeFitPartition string
+
+// this list of features consists of Torque node features; Slurm partitions are appended
+// to it at runtime.
+var nodeResourceDefFeatures []string = []string{"matlab", "cuda", "vgl", "lcmodel"}
 
 func init() {
 
 	qstatCmd.Flags().BoolVarP(&xml, "xml", "x", false, "XML output")
 
+	jobListCmd.Flags().StringSliceVarP(&jobListUsers, "user", "u", []string{}, "only list jobs of the users specified by a comma-separated list")
+
 	clusterCmd.PersistentFlags().StringVarP(&TorqueServerHost, "server", "s", "torque.dccn.nl", "Torque server hostname")
 	clusterCmd.PersistentFlags().IntVarP(&TorqueHelperPort, "port", "p", 60209, "Torque helper service port")
 	clusterCmd.PersistentFlags().StringVarP(&TorqueHelperCert, "cert", "c", defTorqueHelperCert, "Torque helper service certificate")
+	clusterCmd.PersistentFlags().StringVarP(&schedulerName, "scheduler", "", "auto",
+		fmt.Sprintf("cluster scheduler: auto, %s", strings.Join(scheduler.Names(), ", ")))
+
+	jobTraceCmd.Flags().StringVarP(&jobTraceSince, "since", "", "", "only show Slurm job records since the given time, e.g. 2024-11-01 or now-14days")
+	jobTraceCmd.Flags().StringVarP(&jobTraceUntil, "until", "", "", "only show Slurm job records until the given time, e.g. 2024-11-30T12:00:00")
+
+	jobMeminfoCmd.Flags().DurationVarP(&jobMeminfoWatch, "watch", "w", 0, "refresh the memory usage at the given interval, e.g. 5s")
 
 	nodeVncCmd.Flags().StringVarP(&vncUser, "user", "u", "", "username of the VNC owner")
-	nodeVncCmd.Flags().StringVarP(&vncMachineListFile, "machine-list", "l", defMachineListFile, "path to the machinelist file")
+	nodeVncCmd.Flags().BoolVarP(&vncStale, "stale", "", false, "only show the stale VNC sessions, i.e. idle for too long or of users holding too many sessions")
+	nodeVncCmd.Flags().BoolVarP(&vncStaleSummary, "summary", "", false, "with --stale, show the summary of the VNC sessions per user instead")
+	nodeVncCmd.Flags().DurationVarP(&vncMaxIdle, "max-idle", "", 72*time.Hour, "with --stale, the idle time after which a VNC session is stale")
+	nodeVncCmd.Flags().IntVarP(&vncMaxSessions, "max-sessions", "", 2, "with --stale, the number of VNC sessions a user may hold across the hosts")
+	nodeVncCmd.PersistentFlags().StringSliceVarP(&vncSources, "source", "", []string{vncSourceHelper},
+		fmt.Sprintf("sources of the VNC servers: %s (Torque helper on the access nodes), %s (processes on this host) or %s (interactive Slurm jobs)", vncSourceHelper, vncSourceProc, vncSourceSlurm))
 
 	nodeStatusCmd.Flags().BoolVarP(&nodeResourceShowAll, "all", "", false, "show all node resource status")
 	nodeStatusCmd.Flags().BoolVarP(&nodeResourceShowProcs, "procs", "", false, "toggle display of CPU resource status")
 	nodeStatusCmd.Flags().BoolVarP(&nodeResourceShowGpus, "gpus", "", false, "toggle display of GPU resource status")
 	nodeStatusCmd.Flags().BoolVarP(&nodeResourceShowMemGB, "mem", "", false, "toggle display of memory resource status")
 	nodeStatusCmd.Flags().BoolVarP(&nodeResourceShowDiskGB, "disk", "", false, "toggle display of disk resource status")
+	nodeStatusCmd.Flags().BoolVarP(&nodeResourceShowLoad, "load", "", false, "toggle display of CPU load and free memory (Slurm only)")
+	nodeStatusCmd.Flags().BoolVarP(&nodeResourceShowBootTime, "boot", "", false, "toggle display of node boot time (Slurm only)")
 	nodeStatusCmd.Flags().StringSliceVarP(&nodeResourceShowFeatures, "features", "", []string{}, "toggle display of selected node features specified by a comma-separated list.")
-
-	nodeCmd.AddCommand(nodeVncCmd, nodeStatusCmd)
-	jobCmd.AddCommand(jobTraceCmd, jobMeminfoCmd)
-	clusterCmd.AddCommand(qstatCmd, configCmd, matlabCmd, jobCmd, nodeCmd)
+	nodeStatusCmd.Flags().BoolVarP(&nodeResourceSummary, "summary", "", false, "show the resource capacity per cluster, partition and node state instead of the nodes")
+	nodeStatusCmd.Flags().DurationVarP(&nodeStatusWatch, "watch", "w", 0, "refresh the node status at the given interval, e.g. --watch=5s; the interval is 2s if not given")
+	nodeStatusCmd.Flags().Lookup("watch").NoOptDefVal = "2s"
+	nodeStatusCmd.Flags().StringVarP(&nodeStatusRecord, "record", "", "", "record a snapshot of the node status into the given directory or SQLite database (e.g. nodes.db) at every refresh")
+	nodeStatusCmd.Flags().BoolVarP(&nodeResourceReasons, "reasons", "", false, "show the state flags, reasons and reservations of the nodes not fully available")
+
+	nodeStatusCmd.Flags().StringSliceVarP(&nodeFilterClusters, "cluster", "", []string{}, "only show nodes of the clusters specified by a comma-separated list, e.g. slurm")
+	nodeStatusCmd.Flags().StringSliceVarP(&nodeFilterStates, "state", "", []string{}, "only show nodes in the states specified by a comma-separated list, e.g. idle,mixed")
+	nodeStatusCmd.Flags().StringSliceVarP(&nodeFilterPartitions, "partition", "", []string{}, "only show nodes in the Slurm partitions specified by a comma-separated list")
+	nodeStatusCmd.Flags().IntVarP(&nodeFilterMinFreeProcs, "min-free-cpus", "", 0, "only show nodes with at least the given number of available CPU cores")
+	nodeStatusCmd.Flags().StringVarP(&nodeFilterMinFreeMem, "min-free-mem", "", "", "only show nodes with at least the given size of available memory, e.g. 64G")
+	nodeStatusCmd.Flags().StringVarP(&nodeFilterGPUModel, "gpu-model", "", "", "only show nodes with the GPU model containing the given string, e.g. a100")
+	nodeStatusCmd.Flags().StringVarP(&nodeFilterVendor, "vendor", "", "", "only show nodes with the given CPU vendor, e.g. amd")
+
+	nodeFitCmd.Flags().IntVarP(&nodeFitProcs, "cpus", "", 1, "number of CPU cores required by the job")
+	nodeFitCmd.Flags().StringVarP(&nodeFitMem, "mem", "", "", "size of memory required by the job, e.g. 32G")
+	nodeFitCmd.Flags().IntVarP(&nodeFitGPUs, "gpus", "", 0, "number of GPUs required by the job")
+	nodeFitCmd.Flags().StringVarP(&nodeFitGPUModel, "gpu-type", "", "", "GPU model required by the job, e.g. a100")
+	nodeFitCmd.Flags().StringVarP(&nodeFitTmp, "tmp", "", "", "size of local disk space required by the job, e.g. 200G")
+	nodeFitCmd.Flags().StringVarP(&nodeFitPartition, "partition", "", "", "Slurm partition the job is submitted to")
+
+	nodeHistoryCmd.Flags().StringVarP(&nodeHistoryFrom, "from", "", "", "directory or SQLite database of the node-status snapshots recorded by \"nodes status --record\"")
+	nodeHistoryCmd.Flags().StringVarP(&nodeHistorySince, "since", "", "now-7days", "only use the snapshots since the given time, e.g. 2024-11-01 or now-14days")
+	nodeHistoryCmd.Flags().StringVarP(&nodeHistoryUntil, "until", "", "", "only use the snapshots until the given time, e.g. 2024-11-30T12:00:00")
+	nodeHistoryCmd.Flags().StringVarP(&nodeHistoryBy, "by", "", node.SummaryTotal,
+		fmt.Sprintf("group the utilisation by %s, %s, %s or %s", node.SummaryTotal, node.SummaryCluster, node.SummaryPartition, history.GroupNode))
+	nodeHistoryCmd.Flags().DurationVarP(&nodeHistoryStep, "step", "", time.Hour, "time window of the average utilisation, e.g. 24h")
+	nodeHistoryCmd.Flags().BoolVarP(&nodeHistoryDowntime, "downtime", "", false, "show the time the nodes spent in the DOWN or DRAIN state instead of the utilisation")
+	nodeHistoryCmd.Flags().DurationVarP(&nodeHistoryMaxGap, "max-gap", "", time.Hour, "maximum time between two snapshots; a longer time is a gap in the recording")
+	nodeHistoryCmd.MarkFlagRequired("from")
+
+	nodeVncStartCmd.Flags().StringVarP(&vncStartHost, "host", "", "", "host on which the VNC server is started; the current host if not given")
+	nodeVncStartCmd.Flags().StringVarP(&vncStartGeometry, "geometry", "", "1920x1080", "desktop size of the VNC server in the form of {width}x{height}")
+	nodeVncCmd.PersistentFlags().StringVarP(&vncGateway, "gateway", "", "", "SSH gateway between your computer and the VNC host, e.g. ssh.dccn.nl")
+	nodeVncCmd.AddCommand(nodeVncStartCmd, nodeVncStopCmd, nodeVncConnectCmd)
+
+	nodeCmd.PersistentFlags().StringVarP(&nodeMachineListFile, "machine-list", "l", defMachineListFile, "path to the machinelist file")
+	nodeCmd.PersistentFlags().StringSliceVarP(&nodeGroups, "group", "", []string{},
+		"select the hosts of the groups in the machinelist file specified by a comma-separated list, e.g. access or cluster=slurm")
+	nodeCmd.AddCommand(nodeVncCmd, nodeStatusCmd, nodeFitCmd, nodeHistoryCmd)
+	jobCmd.AddCommand(jobInfoCmd, jobTraceCmd, jobMeminfoCmd, jobEfficiencyCmd)
+	clusterCmd.AddCommand(qstatCmd, jobListCmd, partitionCmd, configCmd, matlabCmd, jobCmd, nodeCmd)
 
 	rootCmd.AddCommand(clusterCmd)
 }
@@ -82,147 +193,334 @@ var qstatCmd = &cobra.Command{
 	Args:  cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		log.Debug("qstat command is triggerd.")
-		c := trqhelper.TorqueHelperSrvClient{
-			SrvHost:     TorqueServerHost,
-			SrvPort:     TorqueHelperPort,
-			SrvCertFile: TorqueHelperCert,
+
+		printed := false
+		for _, s := range getSchedulers() {
+			if q, ok := s.(scheduler.QueuePrinter); ok {
+				if err := q.PrintQueue(cmd.Context(), cmd.OutOrStdout(), xml); err != nil {
+					log.Errorf("%+v\n", err)
+				}
+				printed = true
+			}
 		}
-		if err := c.PrintClusterQstat(xml); err != nil {
-			log.Errorf("%+v\n", err)
+
+		if !printed {
+			log.Errorf("no scheduler with a job queue in the server memory: %s", schedulerName)
 		}
 	},
 }
 
-var configCmd = &cobra.Command{
-	Use:   "config",
-	Short: "Print Torque and Moab server configurations.",
+var jobListCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "Print jobs of the Torque and Slurm clusters.",
 	Long:  ``,
 	Args:  cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 
-		if cmd.Flags().Changed("verbose") {
-			log.SetLevel(log.DebugLevel)
-		}
+		scheds := getSchedulers()
+
+		jobs := make(chan scheduler.Job)
+
+		// worker group, one worker per scheduler
+		wg := new(sync.WaitGroup)
+		wg.Add(len(scheds))
 
-		c := trqhelper.TorqueHelperSrvClient{
-			SrvHost:     TorqueServerHost,
-			SrvPort:     TorqueHelperPort,
-			SrvCertFile: TorqueHelperCert,
+		for _, s := range scheds {
+			go func(s scheduler.Scheduler) {
+				defer wg.Done()
+				sjobs, err := s.ListJobs(cmd.Context(), jobListUsers...)
+				if err != nil {
+					log.Errorf("fail get jobs from %s: %s", s.Name(), err)
+				}
+				for _, j := range sjobs {
+					jobs <- j
+				}
+			}(s)
 		}
-		if err := c.PrintClusterConfig(); err != nil {
-			log.Errorf("%+v\n", err)
+
+		go func() {
+			wg.Wait()
+			close(jobs)
+		}()
+
+		// reorganise internal data structure for sorting
+		var _jobs []scheduler.Job
+		for j := range jobs {
+			_jobs = append(_jobs, j)
 		}
+
+		// sorts by cluster and then by job id
+		sort.Slice(_jobs, func(i, j int) bool {
+			if _jobs[i].Cluster != _jobs[j].Cluster {
+				return _jobs[i].Cluster < _jobs[j].Cluster
+			}
+			return scheduler.LessJobID(_jobs[i].ID, _jobs[j].ID)
+		})
+
+		renderOutput(_jobs, func(w io.Writer) {
+			table := tablewriter.NewWriter(w)
+			table.SetHeader([]string{
+				"cluster",
+				"job id",
+				"user",
+				"name",
+				"queue",
+				"state",
+				"procs",
+				"walltime\n(used/limit)",
+				"nodes",
+			})
+			for _, j := range _jobs {
+				nodes := hostlist.Compress(j.Nodes)
+				// show the pending reason in place of the nodes
+				if j.State == "PENDING" && j.Reason != "" {
+					nodes = fmt.Sprintf("(%s)", j.Reason)
+				}
+				table.Append([]string{
+					j.Cluster,
+					j.ID,
+					j.User,
+					j.Name,
+					j.Queue,
+					j.State,
+					fmt.Sprintf("%d", j.NumProcs),
+					fmt.Sprintf("%s/%s", j.TimeUsed, j.TimeLimit),
+					nodes,
+				})
+			}
+			table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
+			table.Render()
+		})
 	},
 }
 
-var matlabCmd = &cobra.Command{
-	Use:   "matlablic",
-	Short: "Print a summary of the Matlab license usage.",
-	Long:  ``,
-	Args:  cobra.NoArgs,
+var partitionCmd = &cobra.Command{
+	Use:   "partitions [name1 name2 ...]",
+	Short: "Print limits and resource availability of Slurm partitions.",
+	Long: `Print limits and resource availability of Slurm partitions.
+
+The default partition is marked with "*".  Empty allowed QOS or accounts mean that
+the partition is open to all QOS or accounts.`,
+	Args: cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		stdout, stderr, ec, err := util.ExecCmd("lmstat", []string{"-a"})
+
+		partitions, err := slurm.GetPartitionInfo(cmd.Context(), "ALL")
 		if err != nil {
-			log.Fatalf("%s: exit code %d\n", err, ec)
+			log.Fatalf("fail get partitions from Slurm: %s", err)
 		}
-		if ec != 0 {
-			log.Fatal(stderr.String())
+
+		usages, err := slurm.GetPartitionUsage(cmd.Context())
+		if err != nil {
+			log.Errorf("fail get partition usage from Slurm: %s", err)
 		}
 
-		rePkg := regexp.MustCompile(`^Users of (\S+):  \(Total of (\d+) licenses issued;  Total of (\d+) licenses in use\)$`)
-		reUse := regexp.MustCompile(`^\s+(\S+) (\S+).*\((v[0-9]+)\).*, start (.*)$`)
-		reRsv := regexp.MustCompile(`^\s+([0-9]+) RESERVATION[s]{0,1} for (HOST_GROUP|GROUP) (\S+)\s+.*$`)
+		records := []partitionRecord{}
+		for _, p := range partitions {
+			if len(args) > 0 && slices.Index(args, p.Name) < 0 {
+				continue
+			}
 
-		var lic matlabLicense
-		var lics []matlabLicense
-		for {
-			line, err := stdout.ReadString('\n')
-			if err == io.EOF {
-				break
+			r := partitionRecord{
+				Name:          p.Name,
+				Default:       p.Default,
+				State:         p.State,
+				NodeStates:    map[string]int{},
+				TotalCPUs:     p.TotalCPUs,
+				DefaultTime:   p.DefaultTime,
+				MaxTime:       p.MaxTime,
+				MaxMemPerCPU:  p.MaxMemPerCPU,
+				MaxNodes:      p.MaxNodes,
+				AllowQOS:      p.AllowQOS,
+				AllowAccounts: p.AllowAccounts,
 			}
-			if err != nil {
-				log.Fatalln("fail parsing lmstat data")
+
+			// node states and resource availability
+			if u, ok := usages[p.Name]; ok {
+				r.NodeStates = u.NodeStates
+				r.IdleCPUs = u.IdleCPUs
+				r.TotalCPUs = u.TotalCPUs
+				r.IdleGPUs = u.IdleGPUs
+				r.TotalGPUs = u.TotalGPUs
+				r.hasUsage = true
 			}
 
-			line = strings.TrimSuffix(line, "\n")
-			if d := rePkg.FindAllStringSubmatch(line, -1); d != nil {
+			records = append(records, r)
+		}
 
-				log.Debugf("find license package: %s\n", line)
+		renderOutput(records, func(w io.Writer) {
+			table := tablewriter.NewWriter(w)
+			table.SetHeader([]string{
+				"partition",
+				"state",
+				"nodes\n(by state)",
+				"cpus\n(idle/total)",
+				"gpus\n(idle/total)",
+				"walltime\n(default/max)",
+				"max mem\nper cpu [gb]",
+				"max\nnodes",
+				"allowed\nqos",
+				"allowed\naccounts",
+			})
+
+			for _, r := range records {
+				name := r.Name
+				if r.Default {
+					name = fmt.Sprintf("%s*", name)
+				}
 
-				// new license package found, put current lic into lics if the current lic is not nil
-				if lic.Package != "" {
-					lics = append(lics, lic)
+				states := []string{}
+				for s, n := range r.NodeStates {
+					states = append(states, fmt.Sprintf("%s:%d", s, n))
 				}
+				sort.Strings(states)
 
-				// create a new matlabLicense with the parsed data
-				n := d[0][1]
-				t, _ := strconv.Atoi(d[0][2])
-				lic = matlabLicense{Package: n, Total: t}
+				cpus := fmt.Sprintf("-/%d", r.TotalCPUs)
+				gpus := "-"
+				if r.hasUsage {
+					cpus = fmt.Sprintf("%d/%d", r.IdleCPUs, r.TotalCPUs)
+					gpus = fmt.Sprintf("%d/%d", r.IdleGPUs, r.TotalGPUs)
+				}
 
-				continue
-			}
+				// limits
+				maxMem := "unlimited"
+				if r.MaxMemPerCPU > 0 {
+					maxMem = fmt.Sprintf("%.1f", float64(r.MaxMemPerCPU)/1024)
+				}
+				maxNodes := "unlimited"
+				if r.MaxNodes > 0 {
+					maxNodes = fmt.Sprintf("%d", r.MaxNodes)
+				}
 
-			if d := reUse.FindAllStringSubmatch(line, -1); d != nil {
-				log.Debugf("find package usage: %s\n", line)
-				// new license usage found, parse it and add it to the license package's usage attribute.
-				usage := matlabLicenseUsageInfo{User: d[0][1], Host: d[0][2], Version: d[0][3], Since: d[0][4]}
-				lic.Usages = append(lic.Usages, usage)
-				continue
+				table.Append([]string{
+					name,
+					r.State,
+					strings.Join(states, "\n"),
+					cpus,
+					gpus,
+					fmt.Sprintf("%s/%s", r.DefaultTime, r.MaxTime),
+					maxMem,
+					maxNodes,
+					strings.Join(r.AllowQOS, "\n"),
+					strings.Join(r.AllowAccounts, "\n"),
+				})
 			}
 
-			if d := reRsv.FindAllStringSubmatch(line, -1); d != nil {
-				log.Debugf("find package reservation: %s\n", line)
-				if nlics, err := strconv.ParseInt(d[0][1], 10, 0); err == nil {
-					rsv := matlabLicenseReservationInfo{Group: d[0][3], NumberOfLicense: int(nlics)}
-					lic.Reservations = append(lic.Reservations, rsv)
-				}
-				continue
+			table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
+			table.SetAlignment(tablewriter.ALIGN_RIGHT)
+			table.SetRowLine(true)
+			table.Render()
+		})
+	},
+}
+
+// partitionRecord defines the output record of a Slurm partition in the `partitions` command.
+type partitionRecord struct {
+	Name    string `json:"name"`
+	Default bool   `json:"default"`
+	State   string `json:"state"`
+	// NodeStates is the number of nodes by the node state, e.g. `idle` -> 3.
+	NodeStates  map[string]int `json:"node_states"`
+	IdleCPUs    int            `json:"idle_cpus"`
+	TotalCPUs   int            `json:"total_cpus"`
+	IdleGPUs    int            `json:"idle_gpus"`
+	TotalGPUs   int            `json:"total_gpus"`
+	DefaultTime string         `json:"default_time"`
+	MaxTime     string         `json:"max_time"`
+	// MaxMemPerCPU is the maximum memory per CPU in megabytes; 0 means unlimited.
+	MaxMemPerCPU int `json:"max_mem_per_cpu_mb"`
+	// MaxNodes is the maximum number of nodes of a job; 0 means unlimited.
+	MaxNodes      int      `json:"max_nodes"`
+	AllowQOS      []string `json:"allow_qos"`
+	AllowAccounts []string `json:"allow_accounts"`
+
+	// hasUsage is false if the current usage of the partition is not available.
+	hasUsage bool
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Print configurations of the cluster schedulers.",
+	Long: `Print configurations of the cluster schedulers.
+
+For Torque, configurations of the Torque and Moab servers are printed.  For Slurm, the
+output of "scontrol show config" is printed.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if cmd.Flags().Changed("verbose") {
+			log.SetLevel(log.DebugLevel)
+		}
+
+		for _, s := range getSchedulers() {
+			if err := s.Config(cmd.Context(), cmd.OutOrStdout()); err != nil {
+				log.Errorf("%s: %+v\n", s.Name(), err)
 			}
 		}
+	},
+}
+
+var matlabCmd = &cobra.Command{
+	Use:   "matlablic",
+	Short: "Print a summary of the Matlab license usage.",
+	Long:  ``,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		stdout, err := util.ExecCmdContext(cmd.Context(), "lmstat", []string{"-a"})
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		lics, err := parseLmstat(stdout)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
 		// print license usages
-		var summaries []string
-		for _, lic := range lics {
-			if len(lic.Usages) == 0 {
-				continue
-			}
-			table := tablewriter.NewWriter(os.Stdout)
-			table.SetHeader([]string{"User", "Host", "Version", "Since"})
-			cntLocal := 0
-			cntGlobal := 0
-			for _, usage := range lic.Usages {
-				// TODO: use a better way to filter and present local usage
-				if strings.HasSuffix(strings.ToLower(usage.Host), "dccn.nl") || strings.HasPrefix(strings.ToLower(usage.Host), "dccn") {
-					table.Append([]string{usage.User, usage.Host, usage.Version, usage.Since})
-					cntLocal++
+		renderOutput(lics, func(w io.Writer) {
+			var summaries []string
+			for _, lic := range lics {
+				if len(lic.Usages) == 0 {
+					continue
 				}
-				cntGlobal++
-			}
-			for _, rsv := range lic.Reservations {
-				// NOTE: do not count the reservation as part of the DCCN user usage.
-				//       this is compatible with the old cluster-matlab script.
-				//
-				// if strings.Contains(strings.ToLower(rsv.Group), "dccn") {
-				// 	// expand reserved licenses by the number of reservation, is it a good representation??
-				// 	for i := 0; i < rsv.NumberOfLicense; i++ {
-				// 		table.Append([]string{rsv.Group, "reservation", "", ""})
-				// 	}
-				// 	cntLocal += rsv.NumberOfLicense
-				// }
-				cntGlobal += rsv.NumberOfLicense
-			}
-
-			if cntLocal > 0 {
-				s := fmt.Sprintf("package %s: %d of %d in use (%d by dccn users)", lic.Package, cntGlobal, lic.Total, cntLocal)
-				summaries = append(summaries, s)
-				fmt.Fprintf(os.Stdout, "\n%s\n", s)
-				table.Render()
-			}
-		}
-		// print summary
-		fmt.Fprintf(os.Stdout, "Summary:\n")
-		for _, s := range summaries {
-			fmt.Fprintf(os.Stdout, "%s\n", s)
-		}
+				table := tablewriter.NewWriter(w)
+				table.SetHeader([]string{"User", "Host", "Version", "Since"})
+				cntLocal := 0
+				cntGlobal := 0
+				for _, usage := range lic.Usages {
+					// TODO: use a better way to filter and present local usage
+					if strings.HasSuffix(strings.ToLower(usage.Host), "dccn.nl") || strings.HasPrefix(strings.ToLower(usage.Host), "dccn") {
+						table.Append([]string{usage.User, usage.Host, usage.Version, usage.Since})
+						cntLocal++
+					}
+					cntGlobal++
+				}
+				for _, rsv := range lic.Reservations {
+					// NOTE: do not count the reservation as part of the DCCN user usage.
+					//       this is compatible with the old cluster-matlab script.
+					//
+					// if strings.Contains(strings.ToLower(rsv.Group), "dccn") {
+					// 	// expand reserved licenses by the number of reservation, is it a good representation??
+					// 	for i := 0; i < rsv.NumberOfLicense; i++ {
+					// 		table.Append([]string{rsv.Group, "reservation", "", ""})
+					// 	}
+					// 	cntLocal += rsv.NumberOfLicense
+					// }
+					cntGlobal += rsv.NumberOfLicense
+				}
+
+				if cntLocal > 0 {
+					s := fmt.Sprintf("package %s: %d of %d in use (%d by dccn users)", lic.Package, cntGlobal, lic.Total, cntLocal)
+					summaries = append(summaries, s)
+					fmt.Fprintf(w, "\n%s\n", s)
+					table.Render()
+				}
+			}
+			// print summary
+			fmt.Fprintf(w, "Summary:\n")
+			for _, s := range summaries {
+				fmt.Fprintf(w, "%s\n", s)
+			}
+		})
 	},
 }
 
@@ -233,21 +531,48 @@ var jobCmd = &cobra.Command{
 	Long:  ``,
 }
 
+var jobInfoCmd = &cobra.Command{
+	Use:   "info [id1 id2 ...]",
+	Short: "Print detailed information of jobs.",
+	Long: `Print detailed information of jobs.
+
+The job is firstly looked up in the Slurm cluster.  If it is not a Slurm job, the job is
+looked up in the memory of the Torque server.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		scheds := getSchedulers()
+
+		for _, id := range args {
+			_, err := runOnJobScheduler(scheds, func(s scheduler.Scheduler) error {
+				return s.JobInfo(cmd.Context(), cmd.OutOrStdout(), id)
+			})
+			if err != nil {
+				log.Errorf("%s: %s", err, id)
+			}
+		}
+	},
+}
+
 var jobTraceCmd = &cobra.Command{
 	Use:   "trace [id]",
 	Short: "Print trace log of a job.",
-	Long: `Print trace log of a job retrieved from the Torque server.
+	Long: `Print trace log of a job.
 
-Only the trace log recorded in the last 3 days will be shown.
+For a Slurm job, the lifecycle of the job (submit, eligible, start, end, requeue and job
+steps) is assembled from the Slurm accounting records.  The time window of the records can
+be specified by the "--since" and "--until" options.
+
+For a Torque job, the trace log is retrieved from the Torque server.  Only the trace log
+recorded in the last 3 days will be shown.
 `,
 	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		c := trqhelper.TorqueHelperSrvClient{
-			SrvHost:     TorqueServerHost,
-			SrvPort:     TorqueHelperPort,
-			SrvCertFile: TorqueHelperCert,
-		}
-		if err := c.PrintClusterTracejob(args[0]); err != nil {
+
+		_, err := runOnJobScheduler(getSchedulers(), func(s scheduler.Scheduler) error {
+			return s.JobTrace(cmd.Context(), cmd.OutOrStdout(), args[0], jobTraceSince, jobTraceUntil)
+		})
+		if err != nil {
 			log.Errorf("fail get job trace info: %+v\n", err)
 		}
 	},
@@ -256,20 +581,220 @@ Only the trace log recorded in the last 3 days will be shown.
 var jobMeminfoCmd = &cobra.Command{
 	Use:   "meminfo [id]",
 	Short: "Print memory usage of a running job.",
-	Long:  ``,
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Print memory usage of a running job.
+
+For a Slurm job, the current and peak resident memory (RSS) of each job step and each node
+are shown.  For a Torque job, the memory usage is retrieved from the Torque helper service
+on the node where the job is running.
+
+With the "--watch" option, the memory usage is refreshed at the given interval until the
+command is interrupted.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		scheds := getSchedulers()
+
+		// the scheduler managing the job, resolved at the first iteration
+		var sched scheduler.Scheduler
+
+		for {
+			if jobMeminfoWatch > 0 && OutputFormat == output.FormatTable {
+				// clear the terminal and move cursor to the top-left corner
+				fmt.Fprint(cmd.OutOrStdout(), "\033[H\033[2J")
+				fmt.Fprintf(cmd.OutOrStdout(), "Every %s: %s\n\n", jobMeminfoWatch, time.Now().Format(time.RFC3339))
+			}
+
+			var err error
+			if sched == nil {
+				sched, err = runOnJobScheduler(scheds, func(s scheduler.Scheduler) error {
+					return s.JobMemory(cmd.Context(), cmd.OutOrStdout(), args[0])
+				})
+			} else {
+				err = sched.JobMemory(cmd.Context(), cmd.OutOrStdout(), args[0])
+			}
+
+			if err != nil {
+				log.Errorf("fail get job memory utilisation: %+v\n", err)
+				return
+			}
+
+			if jobMeminfoWatch == 0 {
+				return
+			}
+
+			select {
+			case <-cmd.Context().Done():
+				return
+			case <-time.After(jobMeminfoWatch):
+			}
+		}
+	},
+}
+
+var jobEfficiencyCmd = &cobra.Command{
+	Use:   "efficiency [id1 id2 ...]",
+	Short: "Print CPU, memory and GPU efficiency of Slurm jobs.",
+	Long: `Print CPU, memory and GPU efficiency of Slurm jobs.
+
+The CPU efficiency is the ratio of the consumed CPU time to the elapsed time multiplied by
+the number of allocated CPUs.  The memory efficiency is the ratio of the maximum resident
+memory of the job steps to the requested memory.  The efficiency of each job step is also
+shown.
+
+If the id refers to a job array, the efficiency of all array tasks is shown and aggregated
+in a summary.`,
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		c := trqhelper.TorqueHelperMomClient{
-			SrvHost:     TorqueServerHost,
-			SrvPort:     TorqueHelperPort,
-			SrvCertFile: TorqueHelperCert,
+
+		var effs []slurm.Efficiency
+		for _, id := range args {
+			records, err := slurm.GetAcctRecords(cmd.Context(), id, "", "")
+			if err != nil {
+				log.Errorf("fail get accounting records of %s: %s", id, err)
+				continue
+			}
+			if len(records) == 0 {
+				log.Errorf("job not found: %s", id)
+				continue
+			}
+			e, err := slurm.ComputeEfficiency(records)
+			if err != nil {
+				log.Errorf("fail compute efficiency of %s: %s", id, err)
+				continue
+			}
+			effs = append(effs, e...)
+		}
+
+		if len(effs) == 0 {
+			return
 		}
-		if err := c.PrintJobMemoryInfo(args[0]); err != nil {
-			log.Errorf("fail get job memory utilisation: %+v\n", err)
+
+		records := make([]jobEfficiency, 0, len(effs))
+		for _, e := range effs {
+			r := jobEfficiency{
+				JobID:          e.JobID,
+				ArrayJobID:     e.ArrayJobID,
+				State:          e.State,
+				AllocCPUs:      e.AllocCPUs,
+				ElapsedSeconds: e.Elapsed.Seconds(),
+				CPUSeconds:     e.TotalCPU.Seconds(),
+				CPUEfficiency:  e.CPUEfficiency,
+				MaxRSSGB:       float64(e.MaxRSSBytes) / gib,
+				ReqMemGB:       float64(e.ReqMemBytes) / gib,
+				MemEfficiency:  e.MemEfficiency,
+				AllocGPUs:      e.AllocGPUs,
+				ReqGPUs:        e.ReqGPUs,
+				Steps:          []jobStepEfficiency{},
+			}
+			for _, st := range e.Steps {
+				r.Steps = append(r.Steps, jobStepEfficiency{
+					JobID:          st.JobID,
+					Name:           st.Name,
+					State:          st.State,
+					AllocCPUs:      st.AllocCPUs,
+					ElapsedSeconds: st.Elapsed.Seconds(),
+					CPUSeconds:     st.TotalCPU.Seconds(),
+					CPUEfficiency:  st.CPUEfficiency,
+					MaxRSSGB:       float64(st.MaxRSSBytes) / gib,
+				})
+			}
+			records = append(records, r)
 		}
+
+		renderOutput(records, func(w io.Writer) {
+
+			// formatDuration prints duration rounded to seconds.
+			formatDuration := func(d time.Duration) string {
+				return d.Round(time.Second).String()
+			}
+
+			table := tablewriter.NewWriter(w)
+			table.SetHeader([]string{
+				"job id",
+				"state",
+				"cpus",
+				"elapsed",
+				"cpu time",
+				"cpu\neff.",
+				"mem [gb]\n(used/req)",
+				"mem\neff.",
+				"gpus\n(alloc/req)",
+			})
+
+			for _, e := range effs {
+				table.Append([]string{
+					e.JobID,
+					e.State,
+					fmt.Sprintf("%d", e.AllocCPUs),
+					formatDuration(e.Elapsed),
+					formatDuration(e.TotalCPU),
+					fmt.Sprintf("%.1f%%", e.CPUEfficiency*100),
+					fmt.Sprintf("%.1f/%.1f", float64(e.MaxRSSBytes)/gib, float64(e.ReqMemBytes)/gib),
+					fmt.Sprintf("%.1f%%", e.MemEfficiency*100),
+					fmt.Sprintf("%d/%d", e.AllocGPUs, e.ReqGPUs),
+				})
+				for _, s := range e.Steps {
+					table.Append([]string{
+						s.JobID,
+						s.State,
+						fmt.Sprintf("%d", s.AllocCPUs),
+						formatDuration(s.Elapsed),
+						formatDuration(s.TotalCPU),
+						fmt.Sprintf("%.1f%%", s.CPUEfficiency*100),
+						fmt.Sprintf("%.1f", float64(s.MaxRSSBytes)/gib),
+						"",
+						"",
+					})
+				}
+			}
+			table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
+			table.SetAlignment(tablewriter.ALIGN_RIGHT)
+			table.Render()
+
+			// print summary of job arrays
+			aggs := slurm.AggregateArrayEfficiency(effs)
+			if len(aggs) > 0 {
+				fmt.Fprintf(w, "Summary:\n")
+			}
+			for _, a := range aggs {
+				fmt.Fprintf(w, "job array %s: %d tasks, cpu eff. %.1f%%, mem eff. %.1f%% (mean) %.1f%% (max)\n",
+					a.ArrayJobID, a.NumTasks, a.CPUEfficiency*100, a.MeanMemEfficiency*100, a.MaxMemEfficiency*100)
+			}
+		})
 	},
 }
 
+// jobEfficiency defines the output record of a job in the `job efficiency` command.  The
+// efficiencies are ratios, e.g. 0.85 for 85%.
+type jobEfficiency struct {
+	JobID string `json:"job_id"`
+	// ArrayJobID is the id of the job array; it is empty if the job is not an array task.
+	ArrayJobID     string              `json:"array_job_id"`
+	State          string              `json:"state"`
+	AllocCPUs      int                 `json:"alloc_cpus"`
+	ElapsedSeconds float64             `json:"elapsed_seconds"`
+	CPUSeconds     float64             `json:"cpu_seconds"`
+	CPUEfficiency  float64             `json:"cpu_efficiency"`
+	MaxRSSGB       float64             `json:"max_rss_gb"`
+	ReqMemGB       float64             `json:"req_mem_gb"`
+	MemEfficiency  float64             `json:"mem_efficiency"`
+	AllocGPUs      int                 `json:"alloc_gpus"`
+	ReqGPUs        int                 `json:"req_gpus"`
+	Steps          []jobStepEfficiency `json:"steps"`
+}
+
+// jobStepEfficiency defines the output record of a job step in the `job efficiency` command.
+type jobStepEfficiency struct {
+	JobID          string  `json:"job_id"`
+	Name           string  `json:"name"`
+	State          string  `json:"state"`
+	AllocCPUs      int     `json:"alloc_cpus"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	CPUSeconds     float64 `json:"cpu_seconds"`
+	CPUEfficiency  float64 `json:"cpu_efficiency"`
+	MaxRSSGB       float64 `json:"max_rss_gb"`
+}
+
 var nodeCmd = &cobra.Command{
 	Use:   "nodes",
 	Short: "Retrieve information about cluster nodes.",
@@ -283,8 +808,43 @@ var nodeCmd = &cobra.Command{
 var nodeStatusCmd = &cobra.Command{
 	Use:   "status [node1 node2 ...]",
 	Short: "Print resource status of all or the specified compute nodes.",
-	Long:  ``,
-	Args:  cobra.ArbitraryArgs,
+	Long: `Print resource status of all or the specified compute nodes.
+
+Multiple nodes can be given by a hostlist expression, e.g. "dccn-c[080-089,101]".
+
+The nodes can be selected by the "--cluster", "--state", "--partition", "--min-free-cpus",
+"--min-free-mem", "--gpu-model" and "--vendor" flags; only the nodes matching all the given
+criteria are shown.  For example, the following command shows the idle or mixed Slurm nodes
+in the "gpu" partition with at least 8 CPU cores and 64 GB memory available:
+
+  hpcutil cluster nodes status --state idle,mixed --partition gpu --min-free-cpus 8 --min-free-mem 64G
+
+The memory size without unit is in gigabytes.
+
+With the "--gpus" flag, the available and total GPUs are shown for each GPU model on the node,
+followed by the indices of the GPUs in use, e.g. "used: 0,2".
+
+With the "--reasons" flag, the nodes which are not fully available are shown with the state
+flags (e.g. "IDLE+DRAIN"), the reason with the user and the time it was set, the last time
+the node was busy and the current and upcoming reservations (Slurm only), e.g.
+
+  hpcutil cluster nodes status --reasons
+
+With the "--watch" flag, the node status is refreshed in place at the given interval (2s if
+not given) until the command is interrupted.  In the node table, the cells changed since the
+previous refresh, e.g. the node state or the available resources, are highlighted.
+
+  hpcutil cluster nodes status --procs --watch
+  hpcutil cluster nodes status --procs --watch=10s
+
+With the "--record" flag, a snapshot of the listed nodes is saved at every refresh into the
+given directory, one JSON file per snapshot, or into a SQLite database given as a file with
+the extension ".db", ".sqlite" or ".sqlite3", or as "sqlite:{path}".  The recorded snapshots
+are reported by "nodes history".
+
+  hpcutil cluster nodes status --record /data/hpcutil/nodes --watch=10m
+  hpcutil cluster nodes status --record /data/hpcutil/nodes.db`,
+	Args: cobra.ArbitraryArgs,
 	PreRun: func(cmd *cobra.Command, args []string) {
 		// toggle all display switches
 		if nodeResourceShowAll {
@@ -292,371 +852,1388 @@ var nodeStatusCmd = &cobra.Command{
 			nodeResourceShowGpus = true
 			nodeResourceShowMemGB = true
 			nodeResourceShowDiskGB = true
+			nodeResourceShowLoad = true
+			nodeResourceShowBootTime = true
 			nodeResourceShowFeatures = nodeResourceDefFeatures
+
+			for _, s := range getSchedulers() {
+				if s.Name() != node.ClusterSlurm {
+					continue
+				}
+				partitions, err := slurm.GetPartitionInfo(cmd.Context(), "ALL")
+				if err != nil {
+					log.Errorf("fail get partitions from Slurm: %s", err)
+				}
+				for _, p := range partitions {
+					nodeResourceShowFeatures = append(nodeResourceShowFeatures, p.Name)
+				}
+			}
 		}
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) == 0 {
+		if len(args) == 0 && len(nodeGroups) == 0 {
 			args = []string{"ALL"}
 		}
 
-		hosts := make(chan string, len(args))
-		trqNodes := make(chan trqhelper.NodeResourceStatus)
-		slurmNodes := make(chan trqhelper.NodeResourceStatus)
+		filter, err := newNodeFilter()
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		// the schedulers are kept over the iterations of the watch mode, so that the
+		// reservations retrieved by the Slurm scheduler are reused.  The Torque helper
+		// client connects to the service at every call; its API does not allow to keep
+		// the connection.
+		scheds := getSchedulers()
 
-		// worker group
-		wg := new(sync.WaitGroup)
-		nworker := 4
-		wg.Add(nworker)
+		hosts := expandHosts(args)
 
-		// torque helper client shared by go routines
-		c := trqhelper.TorqueHelperSrvClient{
-			SrvHost:     TorqueServerHost,
-			SrvPort:     TorqueHelperPort,
-			SrvCertFile: TorqueHelperCert,
+		// the store of the node-status snapshots
+		var store history.Store
+		if nodeStatusRecord != "" {
+			if store, err = history.Open(nodeStatusRecord); err != nil {
+				log.Fatalln(err)
+			}
+			defer store.Close()
 		}
 
-		for i := 0; i < nworker; i++ {
-			go func() {
-				for h := range hosts {
-					log.Debugf("work on %s", h)
+		// rows of the node table in the previous iteration, with the key `{cluster}:{id}`
+		var prevRows map[string][]string
 
-					// slurm resources
-					slurmResources, err := slurm.GetNodeInfo(strings.TrimSuffix(h, fmt.Sprintf(".%s", NetDomain)))
-					if err != nil {
-						log.Errorf("fail get status of %s from Slurm: %s", h, err)
-					}
+		for {
+			now := time.Now()
+			nodes := getNodes(cmd.Context(), scheds, hosts)
+
+			// the snapshot is recorded regardless of the filter criteria, so that the history
+			// does not depend on the nodes selected for the display
+			if store != nil {
+				if err := store.Save(history.Snapshot{Time: now, Nodes: nodes}); err != nil {
+					log.Errorf("fail record node status: %s", err)
+				}
+			}
 
-					for _, r := range slurmResources {
-						slurmNodes <- r
-					}
+			// select nodes matching the filter criteria
+			_nodes := filter.Select(nodes)
 
-					// torque trqResources (conditional)
-					if h == "ALL" || len(slurmResources) == 0 {
-						trqResources, err := c.GetNodeResourceStatus(h)
-						if err != nil {
-							log.Errorf("%s: %s", c.SrvHost, err)
-						}
+			if nodeStatusWatch > 0 && OutputFormat == output.FormatTable {
+				// clear the terminal and move cursor to the top-left corner
+				fmt.Fprint(cmd.OutOrStdout(), "\033[H\033[2J")
+				fmt.Fprintf(cmd.OutOrStdout(), "Every %s: %s\n\n", nodeStatusWatch, time.Now().Format(time.RFC3339))
+			}
 
-						for _, r := range trqResources {
-							if r.ID != "GLOBAL" {
-								trqNodes <- r
-							}
-						}
+			switch {
+			case nodeResourceSummary:
+				// print the resource capacity in place of the nodes
+				caps := node.Summarize(_nodes)
+				renderOutput(caps, func(w io.Writer) {
+					printCapacities(w, caps)
+				})
+			case nodeResourceReasons:
+				// print why the nodes do not take jobs in place of the resource status
+				reasons := []node.Node{}
+				for _, n := range _nodes {
+					if n.Unavailable() || n.Reason != "" || len(n.StateFlags) > 0 || len(n.Reservations) > 0 {
+						reasons = append(reasons, n)
 					}
 				}
+				renderOutput(reasons, func(w io.Writer) {
+					printNodeReasons(w, reasons)
+				})
+			default:
+				// make tabluar display on stdout; cells changed since the previous iteration
+				// are highlighted.
+				rows := make(map[string][]string)
+				renderOutput(_nodes, func(w io.Writer) {
+					rows = printNodes(w, _nodes, prevRows)
+				})
+				prevRows = rows
+			}
 
-				log.Debugln("worker is about to leave")
-				wg.Done()
-			}()
-		}
+			if nodeStatusWatch == 0 {
+				return
+			}
 
-		for _, h := range args {
-			hosts <- h
+			select {
+			case <-cmd.Context().Done():
+				return
+			case <-time.After(nodeStatusWatch):
+			}
 		}
-		close(hosts)
+	},
+}
 
-		done := make(chan bool)
-		go func() {
-			wg.Wait()
-			close(trqNodes)
-			close(slurmNodes)
-			done <- true
-		}()
+var nodeHistoryCmd = &cobra.Command{
+	Use:   "history [node1 node2 ...]",
+	Short: "Print utilisation trends and downtime of nodes from the recorded snapshots.",
+	Long: `Print utilisation trends and downtime of nodes from the recorded snapshots.
 
-		// reorganise internal data structure for sorting
-		var _nodes []trqhelper.NodeResourceStatus
-		var _trqNodeIds []string
+The snapshots of the node status are recorded by the "--record" flag of "nodes status", e.g.
+every 10 minutes in the watch mode:
 
-	waitLoop:
-		for {
-			select {
-			case n, ok := <-trqNodes:
-				if ok {
-					_nodes = append(_nodes, n)
-					_trqNodeIds = append(_trqNodeIds, n.ID)
-				}
-			case n, ok := <-slurmNodes:
-				if ok {
-					n.ID = fmt.Sprintf("%s.%s", n.ID, NetDomain)
-					_nodes = append(_nodes, n)
-				}
-			case <-done:
-				break waitLoop
-			}
-		}
+  hpcutil cluster nodes status --record /data/hpcutil/nodes --watch=10m
 
-		// sorts by node's hostname
-		sort.Slice(_nodes, func(i, j int) bool {
-			return _nodes[i].ID < _nodes[j].ID
-		})
+or periodically by a cron job without the "--watch" flag.
 
-		// sort _nodes and make tabluar display on stdout
-		table := tablewriter.NewWriter(os.Stdout)
+The average CPU, GPU and memory allocation is shown per time window given by "--step", for
+the nodes in total or grouped by the "--by" flag, e.g. the daily utilisation per partition in
+the last 30 days:
 
-		// table headers
-		headers := []string{
-			"cluster",
-			"hostname",
-			"cpu\nvendor",
-			"state",
-			"netbw",
-		}
-		if nodeResourceShowProcs {
-			headers = append(headers, "procs\n(avail/total)")
+  hpcutil cluster nodes history --from /data/hpcutil/nodes --by partition --step 24h --since now-30days
+
+With the "--downtime" flag, the time each node spent in the DOWN or DRAIN state is shown
+instead.  Multiple nodes can be given by a hostlist expression, e.g. "dccn-c[080-089]".`,
+	Args: cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		switch nodeHistoryBy {
+		case node.SummaryTotal, node.SummaryCluster, node.SummaryPartition, history.GroupNode:
+		default:
+			log.Fatalf("invalid grouping: %s", nodeHistoryBy)
 		}
-		if nodeResourceShowGpus {
-			headers = append(headers, "gpus\n(avail/total)")
+
+		if nodeHistoryStep <= 0 || nodeHistoryMaxGap <= 0 {
+			log.Fatalln("step and max-gap should be positive")
 		}
-		if nodeResourceShowMemGB {
-			headers = append(headers, "mem [gb]\n(avail/total)")
+
+		now := time.Now()
+		since, err := history.ParseTime(nodeHistorySince, now)
+		if err != nil {
+			log.Fatalln(err)
 		}
-		if nodeResourceShowDiskGB {
-			headers = append(headers, "disk [gb]\n(avail/total)")
+		until, err := history.ParseTime(nodeHistoryUntil, now)
+		if err != nil {
+			log.Fatalln(err)
 		}
-		if len(nodeResourceShowFeatures) > 0 {
-			headers = append(headers, "features")
+
+		store, err := history.Open(nodeHistoryFrom)
+		if err != nil {
+			log.Fatalln(err)
 		}
-		table.SetHeader(headers)
+		defer store.Close()
 
-		// table content
-		for _, n := range _nodes {
+		snaps, err := store.Load(since, until)
+		if err != nil {
+			log.Fatalln(err)
+		}
 
-			// cluster and id
-			rdata := []string{
-				"slurm",
-				n.ID,
+		// only keep the given nodes
+		if _hosts := expandHosts(args); len(_hosts) > 0 {
+			hosts := make(map[string]bool)
+			for _, h := range _hosts {
+				hosts[hostlist.Short(h)] = true
 			}
-
-			// check if it is a torque node
-			if slices.Index(_trqNodeIds, n.ID) >= 0 {
-				rdata[0] = "torque"
+			for i := range snaps {
+				nodes := []node.Node{}
+				for _, n := range snaps[i].Nodes {
+					if hosts[hostlist.Short(n.ID)] {
+						nodes = append(nodes, n)
+					}
+				}
+				snaps[i].Nodes = nodes
 			}
+		}
 
-			// cpu vendor
-			switch {
-			case n.IsAMD:
-				rdata = append(rdata, "AMD")
-			case n.IsIntel:
-				rdata = append(rdata, "INTEL")
-			default:
-				rdata = append(rdata, "N.A.")
-			}
+		if nodeHistoryDowntime {
+			dts := history.Downtimes(snaps, nodeHistoryMaxGap)
+			renderOutput(dts, func(w io.Writer) {
+				printDowntimes(w, dts)
+			})
+			return
+		}
 
-			// state
-			rdata = append(rdata, n.State)
+		usages := history.Trend(snaps, nodeHistoryBy, nodeHistoryStep)
+		renderOutput(usages, func(w io.Writer) {
+			printUsages(w, usages)
+		})
+	},
+}
 
-			// network bandwidth
-			rdata = append(rdata, fmt.Sprintf("%d", n.NetworkGbps))
+var nodeFitCmd = &cobra.Command{
+	Use:   "fit [node1 node2 ...]",
+	Short: "Check which nodes can run a job with the given resource requirements.",
+	Long: `Check which nodes can run a job with the given resource requirements.
 
-			// ncores
-			if nodeResourceShowProcs {
-				rdata = append(rdata, fmt.Sprintf("%d/%d", n.AvailProcs, n.TotalProcs))
-			}
+The resource requirements of the job on a single node are given by the "--cpus", "--mem",
+"--gpus", "--gpu-type", "--tmp" and "--partition" flags.  For each node, it is reported
+whether the job can be started on the node:
 
-			// ngpus
-			if nodeResourceShowGpus {
-				rdata = append(rdata, fmt.Sprintf("%d/%d", n.AvailGPUS, n.TotalGPUS))
-			}
-
-			// memgb
-			if nodeResourceShowMemGB {
-				rdata = append(rdata, fmt.Sprintf("%d/%d", n.AvailMemGB, n.TotalMemGB))
-			}
+  now    the node has the required resources available;
+  later  the node has the required resources configured, but they are allocated to other
+         jobs or the node is out of service;
+  never  the configured resources or the partitions of the node do not satisfy the
+         requirements.
 
-			// diskgb
-			if nodeResourceShowDiskGB {
-				rdata = append(rdata, fmt.Sprintf("%d/%d", n.AvailDiskGB, n.TotalDiskGB))
-			}
+The memory and disk size without unit is in gigabytes.`,
+	Args: cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 && len(nodeGroups) == 0 {
+			args = []string{"ALL"}
+		}
 
-			// features
-			if len(nodeResourceShowFeatures) > 0 {
-				features := []string{}
-				for _, f := range n.Features {
-					if slices.Index(nodeResourceShowFeatures, f) >= 0 {
-						features = append(features, f)
-					}
-				}
-				rdata = append(rdata, strings.Join(features, "\n"))
-			}
+		req, err := newNodeRequest()
+		if err != nil {
+			log.Fatalln(err)
+		}
 
-			table.Append(rdata)
+		fits := []nodeFit{}
+		for _, n := range getNodes(cmd.Context(), getSchedulers(), expandHosts(args)) {
+			fit, reasons := n.Fit(req)
+			fits = append(fits, nodeFit{
+				ID:      n.ID,
+				Cluster: n.Cluster,
+				Fit:     fit.String(),
+				Reasons: reasons,
+				fit:     fit,
+			})
 		}
 
-		table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
-		table.SetAlignment(tablewriter.ALIGN_RIGHT)
-		if len(nodeResourceShowFeatures) > 0 {
+		// sorts by fit from now to never, nodes of the same fit remain sorted by hostname
+		sort.SliceStable(fits, func(i, j int) bool {
+			return fits[i].fit > fits[j].fit
+		})
+
+		renderOutput(fits, func(w io.Writer) {
+			table := tablewriter.NewWriter(w)
+			table.SetHeader([]string{"cluster", "hostname", "fit", "reasons"})
+			table.SetAutoWrapText(false)
+
+			counts := make(map[node.Fit]int)
+			for _, f := range fits {
+				counts[f.fit]++
+				table.Append([]string{f.Cluster, f.ID, f.Fit, strings.Join(f.Reasons, "\n")})
+			}
 			table.SetRowLine(true)
-		}
-		table.Render()
+			table.Render()
+
+			fmt.Fprintf(w, "Summary: %d now, %d later, %d never\n", counts[node.FitNow], counts[node.FitLater], counts[node.FitNever])
+		})
 	},
 }
 
+// nodeFit defines the output record of a node in the `nodes fit` command.
+type nodeFit struct {
+	ID      string `json:"id"`
+	Cluster string `json:"cluster"`
+	// Fit is whether the job can be started on the node, i.e. `now`, `later` or `never`.
+	Fit string `json:"fit"`
+	// Reasons are the reasons why the job cannot be started on the node right now.
+	Reasons []string `json:"reasons"`
+
+	fit node.Fit
+}
+
 var nodeVncCmd = &cobra.Command{
 	Use:   "vnc [host1 host2 ...]",
 	Short: "Print VNC servers in the cluster or on specific nodes.",
 	Long: `Print VNC servers in the cluster or on specific nodes.
 
-If the {hostname} is specified, only the VNCs on the node will be shown.
+If the {hostname} is specified, only the VNCs on the node will be shown.  Multiple nodes can
+be given by a hostlist expression, e.g. "mentat00[1-5]".  The hosts of a group in the
+machinelist file are given by the "--group" flag, e.g. "--group access".  Without the hosts,
+the Torque helper service is queried on all hosts in the machinelist file.
+
+When the username is specified by the "-u" option, only the VNCs owned by the user will be shown.
+
+By default, the VNCs are retrieved from the Torque helper service on the access nodes.  On the
+hosts without the service, the "--source" flag selects other sources of the VNCs:
+
+  proc:  the Xvnc processes (e.g. TigerVNC or TurboVNC) running on this host
+  slurm: the running Slurm jobs of which the command is a VNC server with the display, e.g.
+         "salloc Xvnc :5" or "salloc vncserver -fg :5", or with the job comment
+         "vnc:{display}", e.g. "salloc --comment=vnc:5"
+
+Multiple sources can be given, e.g. "--source proc,slurm".
 
-When the username is specified by the "-u" option, only the VNCs owned by the user will be shown.`,
+With the "--stale" flag, only the stale VNC sessions are shown together with the start time,
+the idle time and the CPU time of the session, and the reasons why the session is stale: being
+idle for longer than "--max-idle", or the owner holding more than "--max-sessions" sessions
+across the hosts.  The idle time is the time since the last connection or disconnection of a
+VNC client logged by the VNC server; it is only known for the VNC servers found by the "proc"
+source, for the other sources the time since the start is taken if known.  The "--summary" flag
+shows the number of sessions, the stale sessions and the hosts per user instead, e.g.
+
+  hpcutil cluster nodes vnc --source proc --stale --summary`,
 	Args: cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 
-		nodes := make(chan string, 4)
-		vncservers := make(chan trqhelper.VNCServer)
+		hosts := expandHosts(args)
 
-		// worker group
-		wg := new(sync.WaitGroup)
-		nworker := 4
-		wg.Add(nworker)
-
-		// spin off two gRPC workers as go routines
-		for i := 0; i < nworker; i++ {
-			go func() {
-				c := trqhelper.TorqueHelperAccClient{
-					SrvPort:     TorqueHelperPort,
-					SrvCertFile: TorqueHelperCert,
-				}
-				for h := range nodes {
-					log.Debugf("work on %s", h)
+		_vncs := getVNCServers(cmd.Context(), hosts, vncUser)
 
-					c.SrvHost = h
-					servers, err := c.GetVNCServers()
-					if err != nil {
-						log.Errorf("%s: %s", c.SrvHost, err)
-					}
+		if vncStale {
+			reports := vnc.Stale(_vncs, time.Now(), vncMaxIdle, vncMaxSessions)
 
-					for _, s := range servers {
-						if vncUser == "" || s.Owner == vncUser {
-							vncservers <- s
-						}
-					}
+			if vncStaleSummary {
+				summaries := []vncUserSummary{}
+				for _, u := range vnc.SummarizeUsers(reports) {
+					summaries = append(summaries, newVNCUserSummary(u))
 				}
+				renderOutput(summaries, func(w io.Writer) {
+					printVNCUserSummaries(w, summaries)
+				})
+				return
+			}
 
-				log.Debugln("worker is about to leave")
-				wg.Done()
-			}()
+			stale := []vncStaleSession{}
+			unknown := 0
+			for _, r := range reports {
+				if len(r.Reasons) > 0 {
+					stale = append(stale, newVNCStaleSession(r))
+				} else if r.IdleUnknown {
+					unknown++
+				}
+			}
+			if unknown > 0 && vncMaxIdle > 0 {
+				log.Warnf("%d VNC sessions not checked for idleness: the start and activity time is unknown, e.g. with the %s source", unknown, vncSourceHelper)
+			}
+			renderOutput(stale, func(w io.Writer) {
+				printVNCStaleSessions(w, stale)
+			})
+			return
 		}
 
-		// wait for all workers to finish
-		go func() {
-			wg.Wait()
-			close(vncservers)
-		}()
+		records := make([]vncSession, 0, len(_vncs))
+		for _, srv := range _vncs {
+			records = append(records, newVNCSession(srv))
+		}
 
-		// filling access node hosts
-		go func() {
+		// make tabluar display on stdout
+		renderOutput(records, func(w io.Writer) {
+			table := tablewriter.NewWriter(w)
+			table.SetHeader([]string{"Username", "VNC session"})
+			for _, vnc := range records {
+				table.Append([]string{vnc.User, vnc.Session})
+			}
+			table.Render()
+		})
+	},
+}
+
+var nodeVncStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start a VNC server.",
+	Long: `Start a VNC server.
+
+The VNC server is started by "vncserver" on the current host, or via SSH on the host given by
+the "--host" flag.  The host must be one of the hosts in the machinelist file given by the
+"--machine-list" flag, if the file is available.  The size of the desktop is given by the "--geometry" flag, e.g.
+
+  hpcutil cluster nodes vnc start --host mentat001 --geometry 2560x1440
+
+The SSH tunnel command for connecting to the new VNC session is printed, in the same way as
+"vnc connect".`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if !reGeometry.MatchString(vncStartGeometry) {
+			log.Fatalf("invalid geometry: %s", vncStartGeometry)
+		}
+
+		host := vncStartHost
+		if host == "" {
+			h, err := os.Hostname()
+			if err != nil {
+				log.Fatalln(err)
+			}
+			host = h
+		}
+		if err := checkVNCHost(host); err != nil {
+			log.Fatalln(err)
+		}
+		host = hostlist.FQDN(host, NetDomain)
+
+		out, err := execVNCServer(cmd.Context(), host, "-geometry", vncStartGeometry)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		m := reVNCStarted.FindStringSubmatch(out)
+		if m == nil {
+			log.Fatalf("cannot find the display of the new VNC server: %s", out)
+		}
+
+		c, err := newVNCConnection(fmt.Sprintf("%s:%s", host, m[1]))
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		renderOutput([]vncConnection{c}, func(w io.Writer) {
+			printVNCConnection(w, c)
+		})
+	},
+}
+
+var nodeVncStopCmd = &cobra.Command{
+	Use:   "stop {host}:{display}",
+	Short: "Stop a VNC server owned by you.",
+	Long: `Stop a VNC server owned by you.
+
+The VNC session is given in the form of {host}:{display} as listed by "nodes vnc", e.g.
+
+  hpcutil cluster nodes vnc stop mentat001.dccn.nl:5
+
+The VNC session is looked up from the sources given by the "--source" flag; only the sessions
+owned by you can be stopped.  A VNC server started by "vncserver" is killed via SSH on the
+host; a VNC server of a Slurm job is stopped by cancelling the job.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
 
-			// counter for number of nodes to visit
-			mcnt := 0
+		host, display, err := vnc.SplitID(args[0])
+		if err != nil {
+			log.Fatalln(err)
+		}
+		host = hostlist.FQDN(host, NetDomain)
+
+		me, err := user.Current()
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		var session *vnc.Server
+		for _, s := range getVNCServers(cmd.Context(), []string{host}, "") {
+			h, d, _ := vnc.SplitID(s.ID)
+			if hostlist.Short(h) == hostlist.Short(host) && d == display {
+				session = &s
+				break
+			}
+		}
+
+		if session == nil {
+			log.Fatalf("VNC session not found: %s", args[0])
+		}
 
-			// 1. read machinelist from user provided hosts from commandline arguments
-			sort.Strings(args)
-			for _, n := range args {
-				if !strings.HasSuffix(n, fmt.Sprintf(".%s", NetDomain)) {
-					n = fmt.Sprintf("%s.%s", n, NetDomain)
+		if session.Owner != me.Username {
+			log.Fatalf("VNC session %s is owned by %s, only your own sessions can be stopped", session.ID, session.Owner)
+		}
+
+		if session.JobID != "" {
+			_, err = util.ExecCmdContext(cmd.Context(), "scancel", []string{session.JobID})
+		} else {
+			_, err = execVNCServer(cmd.Context(), host, "-kill", fmt.Sprintf(":%d", display))
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "VNC session %s stopped\n", session.ID)
+	},
+}
+
+var nodeVncConnectCmd = &cobra.Command{
+	Use:   "connect {host}:{display}",
+	Short: "Print the SSH tunnel command for connecting to a VNC server.",
+	Long: `Print the SSH tunnel command for connecting to a VNC server.
+
+The VNC session is given in the form of {host}:{display} as listed by "nodes vnc", e.g.
+
+  hpcutil cluster nodes vnc connect mentat001.dccn.nl:5
+
+It prints the port of the VNC server and the SSH command to run on your computer for forwarding
+the port, after which the VNC viewer is connected to the port on localhost.  If the VNC host is
+not reachable from your computer, an SSH gateway can be given by the "--gateway" flag.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		c, err := newVNCConnection(args[0])
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		renderOutput([]vncConnection{c}, func(w io.Writer) {
+			printVNCConnection(w, c)
+		})
+	},
+}
+
+// printNodes prints the resource status of the `nodes` in a table to `w`, with the columns
+// toggled by the display flags of the `nodes status` command.  The cells changed from the
+// previous rows `prevRows` are highlighted.  It returns the rows of the table (without
+// highlighting) with the key `{cluster}:{id}`, to be given as `prevRows` of the next call.
+func printNodes(w io.Writer, nodes []node.Node, prevRows map[string][]string) map[string][]string {
+
+	table := tablewriter.NewWriter(w)
+
+	// table headers
+	headers := []string{
+		"cluster",
+		"hostname",
+		"cpu\nvendor",
+		"state",
+		"netbw",
+	}
+	if nodeResourceShowProcs {
+		headers = append(headers, "procs\n(avail/total)")
+	}
+	if nodeResourceShowGpus {
+		headers = append(headers, "gpus\n(avail/total)")
+	}
+	if nodeResourceShowMemGB {
+		headers = append(headers, "mem [gb]\n(avail/total)")
+	}
+	if nodeResourceShowDiskGB {
+		headers = append(headers, "disk [gb]\n(avail/total)")
+	}
+	if nodeResourceShowLoad {
+		headers = append(headers, "cpu\nload", "free mem\n[gb]")
+	}
+	if nodeResourceShowBootTime {
+		headers = append(headers, "boot time")
+	}
+	if len(nodeResourceShowFeatures) > 0 {
+		headers = append(headers, "features")
+	}
+	table.SetHeader(headers)
+
+	// table content
+	rows := make(map[string][]string)
+	for _, n := range nodes {
+
+		// cluster and id
+		rdata := []string{
+			n.Cluster,
+			n.ID,
+		}
+
+		// cpu vendor
+		if n.CPUVendor != "" {
+			rdata = append(rdata, n.CPUVendor)
+		} else {
+			rdata = append(rdata, "N.A.")
+		}
+
+		// state
+		rdata = append(rdata, n.FullState())
+
+		// network bandwidth
+		rdata = append(rdata, fmt.Sprintf("%d", n.NetworkGbps))
+
+		// ncores
+		if nodeResourceShowProcs {
+			rdata = append(rdata, fmt.Sprintf("%d/%d", n.AvailProcs, n.TotalProcs))
+		}
+
+		// ngpus
+		if nodeResourceShowGpus {
+			rdata = append(rdata, formatNodeGPUs(n))
+		}
+
+		// memgb
+		if nodeResourceShowMemGB {
+			rdata = append(rdata, fmt.Sprintf("%d/%d", n.AvailMemGB, n.TotalMemGB))
+		}
+
+		// diskgb
+		if nodeResourceShowDiskGB {
+			rdata = append(rdata, fmt.Sprintf("%d/%d", n.AvailDiskGB, n.TotalDiskGB))
+		}
+
+		// cpu load and free memory
+		if nodeResourceShowLoad {
+			if n.Cluster == node.ClusterSlurm {
+				rdata = append(rdata, fmt.Sprintf("%.2f", n.CPULoad), fmt.Sprintf("%d", n.FreeMemGB))
+			} else {
+				rdata = append(rdata, "N.A.", "N.A.")
+			}
+		}
+
+		// boot time
+		if nodeResourceShowBootTime {
+			if n.BootTime.IsZero() {
+				rdata = append(rdata, "N.A.")
+			} else {
+				rdata = append(rdata, n.BootTime.Format(time.RFC3339))
+			}
+		}
+
+		// features
+		if len(nodeResourceShowFeatures) > 0 {
+			features := []string{}
+			for _, f := range nodeResourceShowFeatures {
+				if n.HasFeature(f) {
+					features = append(features, f)
 				}
-				log.Debugf("add node %s\n", n)
-				nodes <- n
-				mcnt++
 			}
+			rdata = append(rdata, strings.Join(features, "\n"))
+		}
 
-			// 2. read machinelist from the machinelist file
-			if mcnt == 0 {
-				// read nodes from user provided machinelist
+		key := fmt.Sprintf("%s:%s", n.Cluster, n.ID)
+		rows[key] = append([]string{}, rdata...)
 
-				if fml, err := os.Open(vncMachineListFile); err == nil {
-					defer fml.Close()
-					scanner := bufio.NewScanner(fml)
-					for scanner.Scan() {
-						n := strings.Split(scanner.Text(), " ")[0]
-						if !strings.HasSuffix(n, fmt.Sprintf(".%s", NetDomain)) {
-							n = fmt.Sprintf("%s.%s", n, NetDomain)
-						}
-						nodes <- n
-						mcnt++
-					}
+		// highlight the cells changed since the previous rows; a new node is not highlighted
+		if prev, ok := prevRows[key]; ok {
+			for i := range rdata {
+				if i < len(prev) && rdata[i] != prev[i] {
+					rdata[i] = highlight(rdata[i])
+				}
+			}
+		}
 
-					if err := scanner.Err(); err != nil {
-						log.Warnln(err)
-					}
-				} else {
-					log.Warnln(err)
+		table.Append(rdata)
+	}
+
+	// footer with the total resources of the nodes; empty cells are given a space to
+	// keep the column borders.
+	total := node.Summarize(nodes)[0]
+	footer := []string{node.SummaryTotal, fmt.Sprintf("%d nodes", total.Nodes), " ", " ", " "}
+	if nodeResourceShowProcs {
+		footer = append(footer, fmt.Sprintf("%d/%d", total.AvailProcs, total.TotalProcs))
+	}
+	if nodeResourceShowGpus {
+		footer = append(footer, fmt.Sprintf("%d/%d", total.AvailGPUS, total.TotalGPUS))
+	}
+	if nodeResourceShowMemGB {
+		footer = append(footer, fmt.Sprintf("%d/%d", total.AvailMemGB, total.TotalMemGB))
+	}
+	if nodeResourceShowDiskGB {
+		footer = append(footer, fmt.Sprintf("%d/%d", total.AvailDiskGB, total.TotalDiskGB))
+	}
+	if nodeResourceShowLoad {
+		footer = append(footer, " ", " ")
+	}
+	if nodeResourceShowBootTime {
+		footer = append(footer, " ")
+	}
+	if len(nodeResourceShowFeatures) > 0 {
+		footer = append(footer, " ")
+	}
+	table.SetFooter(footer)
+
+	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAlignment(tablewriter.ALIGN_RIGHT)
+	if len(nodeResourceShowFeatures) > 0 {
+		table.SetRowLine(true)
+	}
+	table.Render()
+
+	return rows
+}
+
+// printUsages prints the resource utilisation trend `usages` in a table to `w`.
+func printUsages(w io.Writer, usages []history.Usage) {
+
+	// usage formats the average allocated and total amount of a resource with the
+	// percentage.
+	usage := func(alloc, total float64) string {
+		if total == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f/%.1f (%.0f%%)", alloc, total, 100*alloc/total)
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{
+		"time",
+		"group",
+		"name",
+		"samples",
+		"cpus\n(alloc/total)",
+		"gpus\n(alloc/total)",
+		"mem [gb]\n(alloc/total)",
+	})
+	table.SetAutoWrapText(false)
+
+	for _, u := range usages {
+		table.Append([]string{
+			u.Time.Local().Format("2006-01-02 15:04"),
+			u.Group,
+			u.Name,
+			fmt.Sprintf("%d", u.Samples),
+			usage(u.AllocProcs, u.TotalProcs),
+			usage(u.AllocGPUS, u.TotalGPUS),
+			usage(u.AllocMemGB, u.TotalMemGB),
+		})
+	}
+
+	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAlignment(tablewriter.ALIGN_RIGHT)
+	table.Render()
+}
+
+// printDowntimes prints the time the nodes spent in the DOWN or DRAIN state in a table to `w`.
+func printDowntimes(w io.Writer, dts []history.Downtime) {
+
+	// hours formats the hours with the percentage of the observed time.
+	hours := func(h, observed float64) string {
+		if observed == 0 {
+			return fmt.Sprintf("%.1f", h)
+		}
+		return fmt.Sprintf("%.1f (%.0f%%)", h, 100*h/observed)
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{
+		"cluster",
+		"hostname",
+		"observed\n[hours]",
+		"down\n[hours]",
+		"drain\n[hours]",
+	})
+	table.SetAutoWrapText(false)
+
+	for _, dt := range dts {
+		table.Append([]string{
+			dt.Cluster,
+			dt.ID,
+			fmt.Sprintf("%.1f", dt.ObservedHours),
+			hours(dt.DownHours, dt.ObservedHours),
+			hours(dt.DrainHours, dt.ObservedHours),
+		})
+	}
+
+	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAlignment(tablewriter.ALIGN_RIGHT)
+	table.Render()
+}
+
+// highlight highlights every line of the table cell `s` with bold yellow text.
+func highlight(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = fmt.Sprintf("\033[1;33m%s\033[0m", l)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// printCapacities prints the resource capacities `caps` in a table to `w`.
+func printCapacities(w io.Writer, caps []node.Capacity) {
+
+	// usage formats the available, allocated and total amount of a resource.
+	usage := func(avail, alloc, total int) string {
+		return fmt.Sprintf("%d/%d/%d", avail, alloc, total)
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{
+		"group",
+		"name",
+		"nodes",
+		"cpus\n(avail/alloc/total)",
+		"gpus\n(avail/alloc/total)",
+		"gpus by model\n(avail/total)",
+		"mem [gb]\n(avail/alloc/total)",
+		"tmp [gb]\n(avail/alloc/total)",
+	})
+	table.SetAutoWrapText(false)
+
+	for _, c := range caps {
+		models := []string{}
+		for _, m := range c.GPUModels {
+			models = append(models, fmt.Sprintf("%s: %d/%d", m.Model, m.Avail, m.Total))
+		}
+		table.Append([]string{
+			c.Group,
+			c.Name,
+			fmt.Sprintf("%d", c.Nodes),
+			usage(c.AvailProcs, c.AllocProcs, c.TotalProcs),
+			usage(c.AvailGPUS, c.AllocGPUS, c.TotalGPUS),
+			strings.Join(models, "\n"),
+			usage(c.AvailMemGB, c.AllocMemGB, c.TotalMemGB),
+			usage(c.AvailDiskGB, c.AllocDiskGB, c.TotalDiskGB),
+		})
+	}
+
+	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAlignment(tablewriter.ALIGN_RIGHT)
+	table.SetRowLine(true)
+	table.Render()
+}
+
+// printNodeReasons prints the state flags, the reasons and the reservations of the `nodes` in
+// a table to `w`.
+func printNodeReasons(w io.Writer, nodes []node.Node) {
+
+	// formatTime prints the zero time as `N/A`.
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return "N/A"
+		}
+		return t.Format("2006-01-02 15:04")
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{
+		"cluster",
+		"hostname",
+		"state",
+		"reason",
+		"reason set\n(by/since)",
+		"last busy",
+		"reservations",
+	})
+	table.SetAutoWrapText(false)
+
+	for _, n := range nodes {
+		setBy := ""
+		if n.ReasonUser != "" {
+			setBy = fmt.Sprintf("%s\n%s", n.ReasonUser, formatTime(n.ReasonTime))
+		}
+
+		resvs := []string{}
+		for _, r := range n.Reservations {
+			name := r.Name
+			if len(r.Flags) > 0 {
+				name = fmt.Sprintf("%s (%s)", name, strings.Join(r.Flags, ","))
+			}
+			resvs = append(resvs, name, fmt.Sprintf("%s - %s", formatTime(r.StartTime), formatTime(r.EndTime)))
+		}
+
+		table.Append([]string{
+			n.Cluster,
+			n.ID,
+			n.FullState(),
+			n.Reason,
+			setBy,
+			formatTime(n.LastBusyTime),
+			strings.Join(resvs, "\n"),
+		})
+	}
+
+	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetRowLine(true)
+	table.Render()
+}
+
+// vncSession defines the output record of a VNC session in the `nodes vnc` command.
+type vncSession struct {
+	User string `json:"user"`
+	// Session is the VNC session in the form of `{host}:{display}`.
+	Session string `json:"session"`
+	Host    string `json:"host"`
+	Display int    `json:"display"`
+}
+
+// getVNCServers returns the VNC servers found by the sources given by the `--source` flag of
+// the `nodes vnc` command, sorted by host and display.  If `hosts` are given, only the VNC
+// servers on the hosts are returned; otherwise the hosts are taken from the machinelist file
+// or Ganglia.  If `owner` is given, only the VNC servers of the owner are returned.
+func getVNCServers(ctx context.Context, hosts []string, owner string) []vnc.Server {
+
+	useHelper := false
+	for _, src := range vncSources {
+		switch src {
+		case vncSourceHelper:
+			useHelper = true
+		case vncSourceProc, vncSourceSlurm:
+		default:
+			log.Fatalf("invalid VNC source: %s", src)
+		}
+	}
+
+	nodes := make(chan string, 4)
+	vncservers := make(chan vnc.Server)
+
+	// worker group
+	wg := new(sync.WaitGroup)
+
+	// discover VNC servers from the sources other than the Torque helper
+	for _, src := range vncSources {
+		if src == vncSourceHelper {
+			continue
+		}
+		wg.Add(1)
+		go func(src string) {
+			defer wg.Done()
+
+			servers, err := discoverVNCServers(ctx, src, hosts)
+			if err != nil {
+				log.Errorf("%s: %s", src, err)
+			}
+
+			for _, s := range servers {
+				if owner == "" || s.Owner == owner {
+					vncservers <- s
 				}
 			}
+		}(src)
+	}
+
+	// the Torque helper source is provided by the schedulers, i.e. Torque
+	var lister scheduler.VNCLister
+	if useHelper {
+		scheds, err := resolveSchedulers()
+		if err != nil {
+			log.Errorf("%s: %s", vncSourceHelper, err)
+		}
+		for _, s := range scheds {
+			if l, ok := s.(scheduler.VNCLister); ok {
+				lister = l
+				break
+			}
+		}
+		if lister == nil {
+			log.Errorf("%s: no scheduler providing the VNC servers: %s", vncSourceHelper, schedulerName)
+			useHelper = false
+		}
+	}
+
+	nworker := 4
+	if !useHelper {
+		nworker = 0
+	}
+	wg.Add(nworker)
+
+	// spin off two gRPC workers as go routines
+	for i := 0; i < nworker; i++ {
+		go func() {
+			for h := range nodes {
+				log.Debugf("work on %s", h)
 
-			// 3. read machinelist from the Gangalia
-			if mcnt == 0 {
-				// TODO: append hostname of all of the access nodes.
-				accs, err := dg.GetAccessNodes()
-				// sort nodes
-				sort.Strings(accs)
+				servers, err := lister.ListVNCServers(ctx, h)
 				if err != nil {
 					log.Errorln(err)
 				}
 
-				for _, n := range accs {
-					nodes <- n
+				for _, s := range servers {
+					if owner == "" || s.Owner == owner {
+						vncservers <- s
+					}
 				}
 			}
 
-			// close the nodes channel
-			close(nodes)
+			log.Debugln("worker is about to leave")
+			wg.Done()
 		}()
+	}
 
-		// reorganise internal data structure for sorting
-		var _vncs []trqhelper.VNCServer
+	// wait for all workers to finish
+	go func() {
+		wg.Wait()
+		close(vncservers)
+	}()
+
+	// filling access node hosts
+	go func() {
 
-		// function for sorting VNC sessions by host.
-		vncSortByHost := func(i, j int) bool {
-			datai := strings.Split(_vncs[i].ID, ":")
-			dataj := strings.Split(_vncs[j].ID, ":")
+		// close the nodes channel
+		defer close(nodes)
 
-			hosti := datai[0]
-			hostj := dataj[0]
+		if !useHelper {
+			return
+		}
+
+		// counter for number of nodes to visit
+		mcnt := 0
 
-			if hosti != hostj {
-				return hosti < hostj
+		// 1. read machinelist from user provided hosts from commandline arguments
+		sort.Strings(hosts)
+		for _, n := range hosts {
+			n = hostlist.FQDN(n, NetDomain)
+			log.Debugf("add node %s\n", n)
+			nodes <- n
+			mcnt++
+		}
+
+		// 2. read machinelist from the machinelist file
+		if mcnt == 0 {
+			// read nodes from user provided machinelist
+
+			if ml, err := machinelist.Load(nodeMachineListFile); err == nil {
+				for _, n := range ml.Names() {
+					nodes <- hostlist.FQDN(n, NetDomain)
+					mcnt++
+				}
+			} else {
+				log.Warnln(err)
 			}
+		}
 
-			idi, _ := strconv.ParseUint(datai[1], 10, 32)
-			idj, _ := strconv.ParseUint(dataj[1], 10, 32)
+		// 3. read machinelist from the Gangalia
+		if mcnt == 0 {
+			// TODO: append hostname of all of the access nodes.
+			accs, err := dg.GetAccessNodes()
+			// sort nodes
+			sort.Strings(accs)
+			if err != nil {
+				log.Errorln(err)
+			}
 
-			return idi < idj
+			for _, n := range accs {
+				nodes <- n
+			}
 		}
+	}()
 
-		for d := range vncservers {
-			_vncs = append(_vncs, d)
-			// perform sorting when a vnc session is added to the list.
-			sort.Slice(_vncs, vncSortByHost)
+	// reorganise internal data structure for sorting
+	var _vncs []vnc.Server
+
+	// function for sorting VNC sessions by host.
+	vncSortByHost := func(i, j int) bool {
+		hosti, idi, _ := vnc.SplitID(_vncs[i].ID)
+		hostj, idj, _ := vnc.SplitID(_vncs[j].ID)
+
+		if hosti != hostj {
+			return hosti < hostj
 		}
 
-		// sort _vncs and make tabluar display on stdout
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader([]string{"Username", "VNC session"})
-		for _, vnc := range _vncs {
-			table.Append([]string{vnc.Owner, vnc.ID})
+		return idi < idj
+	}
+
+	// the same VNC server may be found by multiple sources
+	seen := make(map[string]bool)
+
+	for d := range vncservers {
+		if seen[d.ID] {
+			continue
 		}
-		table.Render()
-	},
+		seen[d.ID] = true
+		_vncs = append(_vncs, d)
+		// perform sorting when a vnc session is added to the list.
+		sort.Slice(_vncs, vncSortByHost)
+	}
+
+	return _vncs
+}
+
+// sources of the VNC servers in the `nodes vnc` command.
+const (
+	// vncSourceHelper is the Torque helper service on the access nodes.
+	vncSourceHelper = "helper"
+	// vncSourceProc is the proc filesystem of the current host.
+	vncSourceProc = "proc"
+	// vncSourceSlurm is the interactive Slurm jobs running a VNC server.
+	vncSourceSlurm = "slurm"
+)
+
+// procDir is the mount point of the proc filesystem in which the VNC servers are looked up.
+var procDir = "/proc"
+
+// discoverVNCServers returns the VNC servers found by the `source` other than the Torque
+// helper service.  If `hosts` are given, only the VNC servers on the hosts are returned.
+func discoverVNCServers(ctx context.Context, source string, hosts []string) ([]vnc.Server, error) {
+
+	var servers []vnc.Server
+	var err error
+
+	switch source {
+	case vncSourceProc:
+		var h string
+		if h, err = os.Hostname(); err != nil {
+			return nil, err
+		}
+		servers, err = vnc.ListProcServers(procDir, hostlist.FQDN(h, NetDomain))
+	case vncSourceSlurm:
+		servers, err = vnc.ListSlurmServers(ctx, NetDomain)
+	default:
+		return nil, fmt.Errorf("unknown VNC source: %s", source)
+	}
+
+	if err != nil || len(hosts) == 0 {
+		return servers, err
+	}
+
+	_hosts := make(map[string]bool)
+	for _, h := range hosts {
+		_hosts[hostlist.Short(h)] = true
+	}
+
+	_servers := []vnc.Server{}
+	for _, s := range servers {
+		if _hosts[hostlist.Short(strings.Split(s.ID, ":")[0])] {
+			_servers = append(_servers, s)
+		}
+	}
+
+	return _servers, nil
+}
+
+// newVNCSession converts the VNC server data object into the `vncSession` record.
+func newVNCSession(srv vnc.Server) vncSession {
+	s := vncSession{User: srv.Owner, Session: srv.ID}
+	s.Host, s.Display, _ = vnc.SplitID(srv.ID)
+	return s
+}
+
+// vncStaleSession defines the output record of a stale VNC session in the `nodes vnc --stale`
+// command.
+type vncStaleSession struct {
+	User    string `json:"user"`
+	Session string `json:"session"`
+	Host    string `json:"host"`
+	Display int    `json:"display"`
+	// JobID is the Slurm job running the VNC server; it is empty if the server is not run by a
+	// Slurm job.
+	JobID string `json:"job_id"`
+	// StartTime and LastActive are zero if unknown.
+	StartTime  time.Time `json:"start_time"`
+	LastActive time.Time `json:"last_active"`
+	IdleHours  float64   `json:"idle_hours"`
+	// IdleUnknown is true if the idle time is unknown; `IdleHours` is then 0.
+	IdleUnknown bool `json:"idle_unknown"`
+	// CPUSeconds is 0 if the CPU time is unknown.
+	CPUSeconds float64 `json:"cpu_seconds"`
+	// Sessions is the number of VNC sessions of the user.
+	Sessions int      `json:"sessions"`
+	Reasons  []string `json:"reasons"`
+}
+
+// newVNCStaleSession converts the staleness report of a VNC server into the `vncStaleSession`
+// record.
+func newVNCStaleSession(r vnc.Report) vncStaleSession {
+	s := vncStaleSession{
+		User:        r.Owner,
+		Session:     r.ID,
+		JobID:       r.JobID,
+		StartTime:   r.StartTime,
+		LastActive:  r.LastActive,
+		IdleHours:   r.Idle.Hours(),
+		IdleUnknown: r.IdleUnknown,
+		CPUSeconds:  r.CPUTime.Seconds(),
+		Sessions:    r.Sessions,
+		Reasons:     r.Reasons,
+	}
+	s.Host, s.Display, _ = vnc.SplitID(r.ID)
+	return s
+}
+
+// vncUserSummary defines the output record of the summary of the VNC sessions of a user in the
+// `nodes vnc --stale --summary` command.
+type vncUserSummary struct {
+	User     string `json:"user"`
+	Sessions int    `json:"sessions"`
+	Stale    int    `json:"stale"`
+	// IdleUnknown is the number of the sessions of which the idle time is unknown.
+	IdleUnknown  int       `json:"idle_unknown"`
+	Hosts        []string  `json:"hosts"`
+	OldestStart  time.Time `json:"oldest_start"`
+	MaxIdleHours float64   `json:"max_idle_hours"`
+	CPUSeconds   float64   `json:"cpu_seconds"`
+}
+
+// newVNCUserSummary converts the VNC summary of a user into the `vncUserSummary` record.
+func newVNCUserSummary(u vnc.UserSummary) vncUserSummary {
+	return vncUserSummary{
+		User:         u.User,
+		Sessions:     u.Sessions,
+		Stale:        u.Stale,
+		IdleUnknown:  u.IdleUnknown,
+		Hosts:        u.Hosts,
+		OldestStart:  u.OldestStart,
+		MaxIdleHours: u.MaxIdle.Hours(),
+		CPUSeconds:   u.CPUTime.Seconds(),
+	}
+}
+
+// formatVNCTime formats the time of a VNC session; an unknown (zero) time is given as
+// `unknown`.
+func formatVNCTime(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// formatVNCDuration formats the duration of a VNC session, e.g. the idle time, in `unit`; an
+// unknown (zero) duration is given as `unknown`.
+func formatVNCDuration(d time.Duration, unit time.Duration) string {
+	if d == 0 {
+		return "unknown"
+	}
+	return d.Round(unit).String()
+}
+
+// printVNCStaleSessions prints the stale VNC `sessions` in a table to `w`.
+func printVNCStaleSessions(w io.Writer, sessions []vncStaleSession) {
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{
+		"Username",
+		"VNC session",
+		"started",
+		"last active",
+		"idle",
+		"cpu time",
+		"reasons",
+	})
+	table.SetAutoWrapText(false)
+
+	for _, s := range sessions {
+		table.Append([]string{
+			s.User,
+			s.Session,
+			formatVNCTime(s.StartTime),
+			formatVNCTime(s.LastActive),
+			formatVNCDuration(time.Duration(s.IdleHours*float64(time.Hour)), time.Minute),
+			formatVNCDuration(time.Duration(s.CPUSeconds*float64(time.Second)), time.Second),
+			strings.Join(s.Reasons, "\n"),
+		})
+	}
+
+	table.SetRowLine(true)
+	table.Render()
+}
+
+// printVNCUserSummaries prints the summaries of the VNC sessions per user in a table to `w`.
+func printVNCUserSummaries(w io.Writer, summaries []vncUserSummary) {
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{
+		"Username",
+		"sessions",
+		"stale",
+		"idle\nunknown",
+		"hosts",
+		"oldest start",
+		"max idle",
+		"cpu time",
+	})
+	table.SetAutoWrapText(false)
+
+	for _, u := range summaries {
+		table.Append([]string{
+			u.User,
+			fmt.Sprintf("%d", u.Sessions),
+			fmt.Sprintf("%d", u.Stale),
+			fmt.Sprintf("%d", u.IdleUnknown),
+			hostlist.Compress(u.Hosts),
+			formatVNCTime(u.OldestStart),
+			formatVNCDuration(time.Duration(u.MaxIdleHours*float64(time.Hour)), time.Minute),
+			formatVNCDuration(time.Duration(u.CPUSeconds*float64(time.Second)), time.Second),
+		})
+	}
+
+	table.Render()
+}
+
+// vncBasePort is the TCP port of the VNC display `:0`; the VNC server of the display `:N`
+// listens on the port `vncBasePort + N`.
+const vncBasePort = 5900
+
+// reGeometry matches the desktop size of the VNC server, e.g. `1920x1080`.
+var reGeometry = regexp.MustCompile(`^[1-9][0-9]*x[1-9][0-9]*$`)
+
+// reHostname matches a hostname, e.g. `mentat001` or `mentat001.dccn.nl`.
+var reHostname = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`)
+
+// reVNCStarted matches the display of the new VNC server in the output of `vncserver`, e.g.
+// `New 'mentat001:5 (honlee)' desktop is mentat001:5` of TigerVNC, or
+// `Desktop 'TurboVNC: mentat001:5 (honlee)' started on display mentat001:5` of TurboVNC.
+var reVNCStarted = regexp.MustCompile(`(?:desktop is|started on display) \S*:(\d+)`)
+
+// vncConnection defines the output record of the `nodes vnc connect` and `nodes vnc start`
+// commands.
+type vncConnection struct {
+	Session string `json:"session"`
+	Host    string `json:"host"`
+	Display int    `json:"display"`
+	// Port is the TCP port the VNC server listens on.
+	Port int `json:"port"`
+	// Tunnel is the SSH command for forwarding the `Port` to the localhost.
+	Tunnel string `json:"tunnel"`
+}
+
+// newVNCConnection returns the connection information of the VNC session `id` in the form of
+// `{host}:{display}`.  The SSH tunnel goes through the gateway given by `--gateway`.
+func newVNCConnection(id string) (vncConnection, error) {
+
+	host, display, err := vnc.SplitID(id)
+	if err != nil {
+		return vncConnection{}, err
+	}
+	host = hostlist.FQDN(host, NetDomain)
+
+	c := vncConnection{
+		Session: fmt.Sprintf("%s:%d", host, display),
+		Host:    host,
+		Display: display,
+		Port:    vncBasePort + display,
+	}
+
+	login := host
+	if me, err := user.Current(); err == nil {
+		login = fmt.Sprintf("%s@%s", me.Username, host)
+	}
+
+	tunnel := []string{"ssh", "-N", "-L", fmt.Sprintf("%d:localhost:%d", c.Port, c.Port)}
+	if vncGateway != "" {
+		tunnel = append(tunnel, "-J", vncGateway)
+	}
+	c.Tunnel = strings.Join(append(tunnel, login), " ")
+
+	return c, nil
+}
+
+// printVNCConnection prints the connection information `c` of a VNC session to `w`.
+func printVNCConnection(w io.Writer, c vncConnection) {
+	fmt.Fprintf(w, "VNC session %s listens on port %d.\n\n", c.Session, c.Port)
+	fmt.Fprintf(w, "Create the SSH tunnel on your computer:\n\n")
+	fmt.Fprintf(w, "    %s\n\n", c.Tunnel)
+	fmt.Fprintf(w, "and connect the VNC viewer to localhost:%d.\n", c.Port)
+}
+
+// execVNCServer runs `vncserver` with `args` on the `host`, i.e. locally if the `host` is the
+// current host, or otherwise via SSH.  It returns the combined stdout and stderr as
+// `vncserver` reports the new desktop on the stderr.
+func execVNCServer(ctx context.Context, host string, args ...string) (string, error) {
+
+	// the shell command; the arguments are validated by the callers
+	sh := strings.Join(append([]string{"vncserver"}, args...), " ") + " 2>&1"
+
+	// the host is never taken as an option of ssh, e.g. `-oProxyCommand=...`
+	if !reHostname.MatchString(host) {
+		return "", fmt.Errorf("invalid host: %s", host)
+	}
+
+	name, cmdArgs := "ssh", []string{"-o", "BatchMode=yes", "--", host, sh}
+	if isLocalHost(host) {
+		name, cmdArgs = "sh", []string{"-c", sh}
+	}
+
+	stdout, err := util.ExecCmdContext(ctx, name, cmdArgs)
+	if err != nil && strings.TrimSpace(stdout.String()) != "" {
+		return stdout.String(), fmt.Errorf("%s: %s", err, strings.TrimSpace(stdout.String()))
+	}
+
+	return stdout.String(), err
+}
+
+// checkVNCHost checks whether a VNC server can be started on the `host`, i.e. the `host` is a
+// valid hostname and, if the machinelist file is available, one of the hosts in it.
+func checkVNCHost(host string) error {
+	if !reHostname.MatchString(host) {
+		return fmt.Errorf("invalid host: %s", host)
+	}
+
+	ml, err := machinelist.Load(nodeMachineListFile)
+	if err != nil {
+		log.Debugf("host %s not checked against the machinelist: %s", host, err)
+		return nil
+	}
+	for _, n := range ml.Names() {
+		if hostlist.Short(n) == hostlist.Short(host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host %s not in the machinelist %s", host, nodeMachineListFile)
+}
+
+// isLocalHost checks whether the `host` refers to the current host.
+func isLocalHost(host string) bool {
+	switch host {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	h, err := os.Hostname()
+	return err == nil && hostlist.Short(h) == hostlist.Short(host)
 }
 
 // matlabLicense defines data structure of matlab license information and usage parsed from the
 // `lmstat -a` command.
 type matlabLicense struct {
-	Package      string
-	Total        int
-	Usages       []matlabLicenseUsageInfo
-	Reservations []matlabLicenseReservationInfo
+	Package string `json:"package"`
+	Total   int    `json:"total"`
+	// InUse is the number of licenses in use reported by lmstat, including the reserved licenses.
+	InUse        int                            `json:"in_use"`
+	Usages       []matlabLicenseUsageInfo       `json:"usages"`
+	Reservations []matlabLicenseReservationInfo `json:"reservations"`
 }
 
 // matlabLicenseUsageInfo defines data structure of a matlab license that is in use.
 type matlabLicenseUsageInfo struct {
-	User    string
-	Host    string
-	Version string
-	Since   string
+	User    string `json:"user"`
+	Host    string `json:"host"`
+	Version string `json:"version"`
+	Since   string `json:"since"`
 }
 
 // matlabLicenseReservationInfo defines data structure of matlab license reservation.
@@ -665,6 +2242,349 @@ type matlabLicenseUsageInfo struct {
 //
 //	is actually being used.
 type matlabLicenseReservationInfo struct {
-	Group           string
-	NumberOfLicense int
+	Group           string `json:"group"`
+	NumberOfLicense int    `json:"licenses"`
+}
+
+// schedulers are the cluster schedulers of the command resolved by `resolveSchedulers`.  They
+// are resolved once per command, as the auto-detection may wait for the connection to the
+// Torque helper service.
+var schedulers struct {
+	resolved bool
+	scheds   []scheduler.Scheduler
+	err      error
+}
+
+// resolveSchedulers returns the cluster schedulers selected by the "--scheduler" flag.  The
+// schedulers are created at the first call of the command and returned by the later calls.
+func resolveSchedulers() ([]scheduler.Scheduler, error) {
+	if !schedulers.resolved {
+		schedulers.scheds, schedulers.err = scheduler.New(schedulerName, schedulerOptions())
+		schedulers.resolved = true
+	}
+	return schedulers.scheds, schedulers.err
+}
+
+// getSchedulers returns the cluster schedulers selected by the "--scheduler" flag.  It exits
+// if no scheduler is available.
+func getSchedulers() []scheduler.Scheduler {
+	scheds, err := resolveSchedulers()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return scheds
+}
+
+// schedulerOptions returns the options of the scheduler backends given by the flags.
+func schedulerOptions() scheduler.Options {
+	return scheduler.Options{
+		TorqueServerHost: TorqueServerHost,
+		TorqueHelperPort: TorqueHelperPort,
+		TorqueHelperCert: TorqueHelperCert,
+	}
+}
+
+// getNodes retrieves resource status of the nodes `hosts` from the schedulers `scheds`; the
+// host `ALL` refers to all nodes of all schedulers.  A node is retrieved from the first
+// scheduler managing it.  The returned nodes are sorted by the hostname.
+func getNodes(ctx context.Context, scheds []scheduler.Scheduler, hosts []string) []node.Node {
+
+	chosts := make(chan string, len(hosts))
+	nodes := make(chan node.Node)
+
+	// worker group
+	wg := new(sync.WaitGroup)
+	nworker := 4
+	wg.Add(nworker)
+
+	for i := 0; i < nworker; i++ {
+		go func() {
+			for h := range chosts {
+				log.Debugf("work on %s", h)
+
+				// get all nodes from all schedulers
+				if h == "ALL" {
+					for _, s := range scheds {
+						ns, err := s.ListNodes(ctx)
+						if err != nil {
+							log.Errorf("fail get node status from %s: %s", s.Name(), err)
+						}
+						for _, n := range ns {
+							nodes <- n
+						}
+					}
+					continue
+				}
+
+				// get the node from the first scheduler managing it
+				var errs []string
+				found := false
+				for _, s := range scheds {
+					ns, err := s.ListNodes(ctx, h)
+					if err != nil {
+						errs = append(errs, fmt.Sprintf("%s: %s", s.Name(), err))
+					}
+					for _, n := range ns {
+						nodes <- n
+						found = true
+					}
+					if found {
+						break
+					}
+				}
+				if !found {
+					log.Errorf("fail get status of %s: %s", h, strings.Join(errs, "; "))
+				}
+			}
+
+			log.Debugln("worker is about to leave")
+			wg.Done()
+		}()
+	}
+
+	for _, h := range hosts {
+		chosts <- h
+	}
+	close(chosts)
+
+	go func() {
+		wg.Wait()
+		close(nodes)
+	}()
+
+	// reorganise internal data structure for sorting
+	var _nodes []node.Node
+	seen := make(map[string]bool)
+	for n := range nodes {
+		// nodes with short hostname are given the default network domain
+		n.ID = hostlist.FQDN(n.ID, NetDomain)
+		// the same node may be given by both the short hostname and the FQDN
+		if seen[n.Cluster+":"+n.ID] {
+			continue
+		}
+		seen[n.Cluster+":"+n.ID] = true
+		_nodes = append(_nodes, n)
+	}
+
+	// sorts by node's hostname
+	sort.Slice(_nodes, func(i, j int) bool {
+		return _nodes[i].ID < _nodes[j].ID
+	})
+
+	return _nodes
+}
+
+// expandHosts expands the hostlist expressions given as the command arguments into hostnames,
+// e.g. `dccn-c[080-082]` into `dccn-c080`, `dccn-c081` and `dccn-c082`.  The hosts of the
+// groups given by the "--group" flag are taken from the machinelist file and appended.
+func expandHosts(args []string) []string {
+	hosts, err := hostlist.Expand(args...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if len(nodeGroups) == 0 {
+		return hosts
+	}
+
+	ml, err := machinelist.Load(nodeMachineListFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// the hosts given by the arguments and in multiple groups are only added once
+	seen := make(map[string]bool)
+	for _, h := range hosts {
+		seen[hostlist.Short(h)] = true
+	}
+
+	for _, g := range nodeGroups {
+		_hosts := ml.Select(g)
+		// an empty group would otherwise select all nodes
+		if len(_hosts) == 0 {
+			log.Fatalf("no hosts of group %s in %s", g, nodeMachineListFile)
+		}
+		for _, h := range _hosts {
+			if !seen[hostlist.Short(h.Name)] {
+				seen[hostlist.Short(h.Name)] = true
+				// the machinelist may mix short hostnames and FQDNs, while the schedulers
+				// report the nodes by FQDN
+				hosts = append(hosts, hostlist.FQDN(h.Name, NetDomain))
+			}
+		}
+	}
+
+	return hosts
+}
+
+// formatNodeGPUs formats the GPU status of the node `n` for the table cell: the available
+// and total GPUs, followed by the GPU models each with the indices of the GPUs in use.  The
+// per-model availability is omitted for a node with GPUs of a single model.
+func formatNodeGPUs(n node.Node) string {
+
+	lines := []string{fmt.Sprintf("%d/%d", n.AvailGPUS, n.TotalGPUS)}
+
+	if len(n.GPUs) == 0 {
+		if n.GPUModel != "" {
+			lines = append(lines, n.GPUModel)
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	for _, g := range n.GPUs {
+		l := g.Model
+		if l == "" {
+			l = "gpu"
+		}
+		if len(n.GPUs) > 1 {
+			l = fmt.Sprintf("%s %d/%d", l, g.Avail, g.Total)
+		}
+		if len(g.UsedIndices) > 0 {
+			idx := make([]string, len(g.UsedIndices))
+			for i, x := range g.UsedIndices {
+				idx[i] = strconv.Itoa(x)
+			}
+			l = fmt.Sprintf("%s\nused: %s", l, strings.Join(idx, ","))
+		}
+		lines = append(lines, l)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// newNodeFilter returns the node filter composed from the node selection flags.
+func newNodeFilter() (node.Filter, error) {
+
+	memBytes, _, err := slurm.ParseMemBytes(strings.ToUpper(nodeFilterMinFreeMem), "G")
+	if err != nil {
+		return node.Filter{}, fmt.Errorf("invalid --min-free-mem: %s", err)
+	}
+
+	return node.Filter{
+		Clusters:        nodeFilterClusters,
+		States:          nodeFilterStates,
+		Partitions:      nodeFilterPartitions,
+		MinFreeProcs:    nodeFilterMinFreeProcs,
+		MinFreeMemBytes: memBytes,
+		GPUModel:        nodeFilterGPUModel,
+		CPUVendor:       nodeFilterVendor,
+	}, nil
+}
+
+// newNodeRequest returns the resource requirements of a job composed from the flags of the
+// `nodes fit` command.
+func newNodeRequest() (node.Request, error) {
+
+	memBytes, _, err := slurm.ParseMemBytes(strings.ToUpper(nodeFitMem), "G")
+	if err != nil {
+		return node.Request{}, fmt.Errorf("invalid --mem: %s", err)
+	}
+
+	tmpBytes, _, err := slurm.ParseMemBytes(strings.ToUpper(nodeFitTmp), "G")
+	if err != nil {
+		return node.Request{}, fmt.Errorf("invalid --tmp: %s", err)
+	}
+
+	return node.Request{
+		Procs:     nodeFitProcs,
+		MemBytes:  memBytes,
+		GPUs:      nodeFitGPUs,
+		GPUModel:  nodeFitGPUModel,
+		TmpBytes:  tmpBytes,
+		Partition: nodeFitPartition,
+	}, nil
+}
+
+// runOnJobScheduler calls `f` on the schedulers in order until the one managing the job is
+// found, i.e. `f` does not return `scheduler.ErrUnknownJob`.  It returns the scheduler on
+// which `f` succeeds.
+func runOnJobScheduler(scheds []scheduler.Scheduler, f func(s scheduler.Scheduler) error) (scheduler.Scheduler, error) {
+	for _, s := range scheds {
+		err := f(s)
+		if err == nil {
+			return s, nil
+		}
+		if !errors.Is(err, scheduler.ErrUnknownJob) {
+			return nil, fmt.Errorf("%s: %w", s.Name(), err)
+		}
+		log.Debugf("%s: %s", s.Name(), err)
+	}
+	return nil, scheduler.ErrUnknownJob
+}
+
+// parseLmstat converts the output of `lmstat -a` into array of `matlabLicense`.
+//
+// The expected `stdout` looks like the one below:
+//
+// ```
+// Users of MATLAB:  (Total of 2 licenses issued;  Total of 2 licenses in use)
+//
+//	"MATLAB" v44, vendor: MLM, expiry: 31-dec-2025
+//	floating license
+//
+//	  honlee dccn-c083.dccn.nl /dev/pts/1 (v44) (lic-srv.ru.nl/27000 2001), start Mon 11/18 9:21
+//	  1 RESERVATION for GROUP DCCN (lic-srv.ru.nl/27000)
+//
+// ```
+func parseLmstat(stdout bytes.Buffer) ([]matlabLicense, error) {
+
+	rePkg := regexp.MustCompile(`^Users of (\S+):  \(Total of (\d+) licenses issued;  Total of (\d+) licenses in use\)$`)
+	reUse := regexp.MustCompile(`^\s+(\S+) (\S+).*\((v[0-9]+)\).*, start (.*)$`)
+	reRsv := regexp.MustCompile(`^\s+([0-9]+) RESERVATION[s]{0,1} for (HOST_GROUP|GROUP) (\S+)\s+.*$`)
+
+	var lic matlabLicense
+	var lics []matlabLicense
+	for {
+		line, err := stdout.ReadString('\n')
+		if err == io.EOF && line == "" {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return lics, fmt.Errorf("fail parsing lmstat data: %s", err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if d := rePkg.FindAllStringSubmatch(line, -1); d != nil {
+
+			log.Debugf("find license package: %s\n", line)
+
+			// new license package found, put current lic into lics if the current lic is not nil
+			if lic.Package != "" {
+				lics = append(lics, lic)
+			}
+
+			// create a new matlabLicense with the parsed data; the licenses in use are taken
+			// as counted by lmstat, including the reserved ones.
+			n := d[0][1]
+			t, _ := strconv.Atoi(d[0][2])
+			u, _ := strconv.Atoi(d[0][3])
+			lic = matlabLicense{Package: n, Total: t, InUse: u}
+
+			continue
+		}
+
+		if d := reUse.FindAllStringSubmatch(line, -1); d != nil {
+			log.Debugf("find package usage: %s\n", line)
+			// new license usage found, parse it and add it to the license package's usage attribute.
+			usage := matlabLicenseUsageInfo{User: d[0][1], Host: d[0][2], Version: d[0][3], Since: d[0][4]}
+			lic.Usages = append(lic.Usages, usage)
+			continue
+		}
+
+		if d := reRsv.FindAllStringSubmatch(line, -1); d != nil {
+			log.Debugf("find package reservation: %s\n", line)
+			if nlics, err := strconv.ParseInt(d[0][1], 10, 0); err == nil {
+				rsv := matlabLicenseReservationInfo{Group: d[0][3], NumberOfLicense: int(nlics)}
+				lic.Reservations = append(lic.Reservations, rsv)
+			}
+			continue
+		}
+	}
+
+	// the last license package
+	if lic.Package != "" {
+		lics = append(lics, lic)
+	}
+
+	return lics, nil
 }
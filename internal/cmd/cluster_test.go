@@ -0,0 +1,649 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Donders-Institute/hpc-utility/internal/history"
+	"github.com/Donders-Institute/hpc-utility/internal/node"
+	"github.com/Donders-Institute/hpc-utility/internal/scheduler"
+	"github.com/Donders-Institute/hpc-utility/internal/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// goldenDir is the directory of the recorded outputs of the system calls.
+const goldenDir = "../../testdata/exec"
+
+var (
+	lmstatout = `lmstat - Copyright (c) 1989-2019 Flexera. All Rights Reserved.
+Flexible License Manager status on Mon 11/18/2024 10:12
+
+Users of MATLAB:  (Total of 10 licenses issued;  Total of 4 licenses in use)
+
+  "MATLAB" v44, vendor: MLM, expiry: 31-dec-2025
+  floating license
+
+    honlee dccn-c083.dccn.nl /dev/pts/1 (v44) (lic-srv.ru.nl/27000 2001), start Mon 11/18 9:21
+    student pc-1234.science.ru.nl MATLAB (v44) (lic-srv.ru.nl/27000 1101), start Mon 11/18 10:01
+    2 RESERVATIONs for HOST_GROUP DCCN (lic-srv.ru.nl/27000)
+
+Users of SIMULINK:  (Total of 5 licenses issued;  Total of 0 licenses in use)
+
+Users of Signal_Toolbox:  (Total of 5 licenses issued;  Total of 2 licenses in use)
+
+  "Signal_Toolbox" v44, vendor: MLM, expiry: 31-dec-2025
+  floating license
+
+    honlee dccn-c083.dccn.nl /dev/pts/1 (v44) (lic-srv.ru.nl/27000 3101), start Mon 11/18 9:22`
+
+	// machinelistfile is the machine list file with the host groups and attributes.
+	machinelistfile = `# GPU nodes
+[gpu]
+dccn-c[083-084] role=gpu
+
+[interactive]
+dccn-c084   # the interactive VNC node
+
+[batch]
+dccn-c075.dccn.nl role=batch
+`
+)
+
+func TestMain(m *testing.M) {
+	// isolate the tests from the user configuration
+	dir, err := os.MkdirTemp("", "hpcutil-test")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_CONFIG_HOME", dir)
+
+	ec := m.Run()
+
+	os.RemoveAll(dir)
+	os.Exit(ec)
+}
+
+// resetFlags resets the flags given in the previous execution of the commands.
+func resetFlags(c *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+		if v, ok := f.Value.(pflag.SliceValue); ok {
+			// the default value of a slice is given in the form of `[a,b]`
+			def := []string{}
+			if d := strings.Trim(f.DefValue, "[]"); d != "" {
+				def = strings.Split(d, ",")
+			}
+			v.Replace(def)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	c.PersistentFlags().VisitAll(reset)
+	c.Flags().VisitAll(reset)
+	for _, sub := range c.Commands() {
+		resetFlags(sub)
+	}
+}
+
+// execute runs the hpcutil command with `args` on the replayed outputs of the system calls,
+// and returns the stdout.
+func execute(t *testing.T, args ...string) string {
+
+	defer func(r util.Runner) { util.DefaultRunner = r }(util.DefaultRunner)
+
+	resetFlags(rootCmd)
+	rootCmd.SetArgs(append([]string{"--exec-replay", goldenDir}, args...))
+
+	var stdout bytes.Buffer
+	rootCmd.SetOut(&stdout)
+	defer rootCmd.SetOut(nil)
+
+	if err := rootCmd.ExecuteContext(context.Background()); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	t.Logf("hpcutil %s\n%s", strings.Join(args, " "), stdout.String())
+
+	return stdout.String()
+}
+
+func TestParseLmstat(t *testing.T) {
+
+	lics, err := parseLmstat(*bytes.NewBufferString(lmstatout))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for _, lic := range lics {
+		t.Logf("license: %+v", lic)
+	}
+
+	if len(lics) != 3 {
+		t.Fatalf("expect 3 license packages, got %d", len(lics))
+	}
+
+	if lic := lics[0]; lic.Package != "MATLAB" || lic.Total != 10 || lic.InUse != 4 || len(lic.Usages) != 2 {
+		t.Errorf("unexpected MATLAB license: %+v", lic)
+	}
+
+	if u := lics[0].Usages[1]; u.User != "student" || u.Host != "pc-1234.science.ru.nl" || u.Version != "v44" || u.Since != "Mon 11/18 10:01" {
+		t.Errorf("unexpected MATLAB license usage: %+v", u)
+	}
+
+	if r := lics[0].Reservations; len(r) != 1 || r[0].Group != "DCCN" || r[0].NumberOfLicense != 2 {
+		t.Errorf("unexpected MATLAB license reservation: %+v", r)
+	}
+
+	// the last package without a trailing newline; the licenses in use are taken from lmstat
+	// rather than counted from the listed usages
+	if lic := lics[2]; lic.Package != "Signal_Toolbox" || lic.InUse != 2 || len(lic.Usages) != 1 {
+		t.Errorf("unexpected Signal_Toolbox license: %+v", lic)
+	}
+}
+
+func TestClusterNodesStatus(t *testing.T) {
+
+	var nodes []node.Node
+	out := execute(t, "cluster", "--scheduler", "slurm", "nodes", "status", "-o", "json")
+	if err := json.Unmarshal([]byte(out), &nodes); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(nodes) != 3 {
+		t.Fatalf("expect 3 nodes, got %d", len(nodes))
+	}
+	if n := nodes[0]; n.ID != "dccn-c075.dccn.nl" || n.CPUVendor != "INTEL" || !strings.HasPrefix(n.Reason, "memory test") {
+		t.Errorf("unexpected node: %+v", n)
+	}
+	if n := nodes[1]; n.ID != "dccn-c083.dccn.nl" || n.AvailGPUS != 3 || n.TotalGPUS != 4 {
+		t.Errorf("unexpected node: %+v", n)
+	}
+	if g := nodes[1].GPUs; len(g) != 1 || g[0].Avail != 3 || len(g[0].UsedIndices) != 1 || g[0].UsedIndices[0] != 2 {
+		t.Errorf("unexpected GPUs by model: %+v", g)
+	}
+
+	out = execute(t, "cluster", "--scheduler", "slurm", "nodes", "status", "--gpus", "dccn-c083")
+	if !strings.Contains(out, "dccn-c083.dccn.nl") || !strings.Contains(out, "nvidia_a100-sxm4-40gb") || !strings.Contains(out, "used: 2") || strings.Contains(out, "dccn-c084") {
+		t.Errorf("unexpected output of a single node")
+	}
+
+	// hostlist expression with duplicated nodes
+	out = execute(t, "cluster", "--scheduler", "slurm", "nodes", "status", "-o", "json", "dccn-c[083]", "dccn-c083.dccn.nl")
+	if err := json.Unmarshal([]byte(out), &nodes); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(nodes) != 1 || nodes[0].ID != "dccn-c083.dccn.nl" {
+		t.Errorf("unexpected nodes of hostlist expression: %+v", nodes)
+	}
+}
+
+func TestClusterNodesStatusFilter(t *testing.T) {
+
+	cases := []struct {
+		args []string
+		ids  []string
+	}{
+		{[]string{"--state", "mixed"}, []string{"dccn-c083.dccn.nl"}},
+		{[]string{"--partition", "gpu", "--min-free-cpus", "60"}, []string{"dccn-c083.dccn.nl"}},
+		{[]string{"--min-free-mem", "380g"}, []string{"dccn-c083.dccn.nl"}},
+		{[]string{"--vendor", "intel"}, []string{"dccn-c075.dccn.nl"}},
+		{[]string{"--gpu-model", "a100", "--state", "idle"}, []string{"dccn-c084.dccn.nl"}},
+		{[]string{"--cluster", "torque"}, []string{}},
+	}
+
+	for _, c := range cases {
+		var nodes []node.Node
+		args := append([]string{"cluster", "--scheduler", "slurm", "nodes", "status", "-o", "json"}, c.args...)
+		if err := json.Unmarshal([]byte(execute(t, args...)), &nodes); err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		ids := []string{}
+		for _, n := range nodes {
+			ids = append(ids, n.ID)
+		}
+		if strings.Join(ids, ",") != strings.Join(c.ids, ",") {
+			t.Errorf("%s: expect %+v, got %+v", strings.Join(c.args, " "), c.ids, ids)
+		}
+	}
+}
+
+func TestClusterNodesSummary(t *testing.T) {
+
+	var caps []node.Capacity
+	out := execute(t, "cluster", "--scheduler", "slurm", "nodes", "status", "--summary", "-o", "json")
+	if err := json.Unmarshal([]byte(out), &caps); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(caps) != 6 {
+		t.Fatalf("expect 6 capacities, got %d", len(caps))
+	}
+	if c := caps[0]; c.Group != node.SummaryTotal || c.Nodes != 3 || c.TotalProcs != 157 || c.AllocGPUS != 1 {
+		t.Errorf("unexpected total capacity: %+v", c)
+	}
+	if c := caps[3]; c.Group != node.SummaryPartition || c.Name != "gpu" || c.Nodes != 2 {
+		t.Errorf("unexpected partition capacity: %+v", c)
+	}
+
+	// the footer of the node table
+	out = execute(t, "cluster", "--scheduler", "slurm", "nodes", "status", "--procs", "--state", "idle,drain")
+	if !strings.Contains(out, "2 NODES") || !strings.Contains(out, "53/94") {
+		t.Errorf("unexpected footer of the node table")
+	}
+}
+
+func TestPrintNodesHighlight(t *testing.T) {
+
+	defer func(procs bool) { nodeResourceShowProcs = procs }(nodeResourceShowProcs)
+	nodeResourceShowProcs = true
+
+	nodes := []node.Node{
+		{ID: "dccn-c075", Cluster: node.ClusterSlurm, State: "IDLE", TotalProcs: 32, AvailProcs: 32},
+		{ID: "dccn-c083", Cluster: node.ClusterSlurm, State: "MIXED", TotalProcs: 64, AvailProcs: 8},
+	}
+
+	var out bytes.Buffer
+	rows := printNodes(&out, nodes, nil)
+	if len(rows) != 2 || strings.Contains(out.String(), "\033[") {
+		t.Errorf("unexpected rows without previous rows: %+v\n%s", rows, out.String())
+	}
+
+	// node state flips and resource counts change
+	nodes[0].State = "MIXED"
+	nodes[0].AvailProcs = 16
+
+	out.Reset()
+	rows = printNodes(&out, nodes, rows)
+	t.Logf("\n%s", out.String())
+
+	if !strings.Contains(out.String(), highlight("MIXED")) || !strings.Contains(out.String(), highlight("16/32")) {
+		t.Errorf("expect changed cells to be highlighted")
+	}
+	if strings.Count(out.String(), "\033[1;33m") != 2 {
+		t.Errorf("expect 2 highlighted cells")
+	}
+	if rows["slurm:dccn-c075"][3] != "MIXED" {
+		t.Errorf("unexpected rows: %+v", rows)
+	}
+}
+
+func TestClusterNodesReasons(t *testing.T) {
+
+	var nodes []node.Node
+	out := execute(t, "cluster", "--scheduler", "slurm", "nodes", "status", "--reasons", "-o", "json")
+	if err := json.Unmarshal([]byte(out), &nodes); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// all nodes are drained or have a reservation not yet ended
+	if len(nodes) != 3 {
+		t.Fatalf("expect 3 nodes, got %d", len(nodes))
+	}
+	if n := nodes[0]; n.FullState() != "IDLE+DRAIN" || n.Reason != "memory test" || n.ReasonUser != "root" || n.ReasonTime.IsZero() {
+		t.Errorf("unexpected state and reason: %+v", n)
+	}
+	if r := nodes[1].Reservations; len(r) != 1 || r[0].Name != "maint" || r[0].Flags[0] != "MAINT" {
+		t.Errorf("unexpected reservations: %+v", r)
+	}
+	if r := nodes[2].Reservations; len(r) != 1 || r[0].Name != "gpu_project" {
+		t.Errorf("unexpected reservations: %+v", r)
+	}
+
+	out = execute(t, "cluster", "--scheduler", "slurm", "nodes", "status", "--reasons", "--state", "drain")
+	if !strings.Contains(out, "IDLE+DRAIN") || !strings.Contains(out, "memory test") || strings.Contains(out, "dccn-c083") {
+		t.Errorf("unexpected output of drained nodes")
+	}
+}
+
+func TestClusterNodesHistory(t *testing.T) {
+
+	dir := t.TempDir()
+
+	execute(t, "cluster", "--scheduler", "slurm", "nodes", "status", "--record", dir)
+	time.Sleep(time.Second)
+	// all nodes are recorded regardless of the selection flags
+	execute(t, "cluster", "--scheduler", "slurm", "nodes", "status", "--state", "mixed", "--record", dir)
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expect 2 snapshots, got %d", len(files))
+	}
+
+	var usages []history.Usage
+	out := execute(t, "cluster", "nodes", "history", "--from", dir, "--by", "node", "--step", "24h", "-o", "json", "dccn-c083")
+	if err := json.Unmarshal([]byte(out), &usages); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(usages) == 0 {
+		t.Fatalf("expect usages of dccn-c083")
+	}
+	for _, u := range usages {
+		if !strings.HasPrefix(u.Name, "dccn-c083") || u.TotalGPUS == 0 {
+			t.Errorf("unexpected usage: %+v", u)
+		}
+	}
+
+	var dts []history.Downtime
+	out = execute(t, "cluster", "nodes", "history", "--from", dir, "--downtime", "-o", "json")
+	if err := json.Unmarshal([]byte(out), &dts); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(dts) != 3 {
+		t.Fatalf("expect 3 nodes, got %d", len(dts))
+	}
+	// dccn-c075 is drained
+	for _, dt := range dts {
+		if strings.HasPrefix(dt.ID, "dccn-c075") && (dt.DrainHours == 0 || dt.DrainHours != dt.ObservedHours) {
+			t.Errorf("unexpected downtime: %+v", dt)
+		}
+	}
+
+	out = execute(t, "cluster", "nodes", "history", "--from", dir, "--by", "partition")
+	if !strings.Contains(out, "partition") || !strings.Contains(out, "gpu") {
+		t.Errorf("unexpected output of history by partition")
+	}
+
+	// the snapshots in a SQLite database
+	db := filepath.Join(t.TempDir(), "nodes.db")
+	execute(t, "cluster", "--scheduler", "slurm", "nodes", "status", "--record", db)
+
+	out = execute(t, "cluster", "nodes", "history", "--from", db, "--by", "node", "-o", "json")
+	if err := json.Unmarshal([]byte(out), &usages); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(usages) != 3 {
+		t.Errorf("expect usages of 3 nodes in SQLite store, got %d", len(usages))
+	}
+}
+
+func TestClusterNodesFit(t *testing.T) {
+
+	var fits []nodeFit
+	out := execute(t, "cluster", "--scheduler", "slurm", "nodes", "fit", "--cpus", "54", "--gpus", "1", "--mem", "300G", "-o", "json")
+	if err := json.Unmarshal([]byte(out), &fits); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(fits) != 3 {
+		t.Fatalf("expect 3 nodes, got %d", len(fits))
+	}
+	for i, expect := range []string{"dccn-c083.dccn.nl:now", "dccn-c084.dccn.nl:later", "dccn-c075.dccn.nl:never"} {
+		if f := fits[i]; fmt.Sprintf("%s:%s", f.ID, f.Fit) != expect {
+			t.Errorf("expect %s, got %+v", expect, f)
+		}
+	}
+	if r := fits[2].Reasons; len(r) != 3 {
+		t.Errorf("unexpected reasons: %+v", r)
+	}
+
+	out = execute(t, "cluster", "--scheduler", "slurm", "nodes", "fit", "--gpu-type", "a100", "--partition", "gpu")
+	if !strings.Contains(out, "not in partition gpu") || !strings.Contains(out, "Summary: 2 now, 0 later, 1 never") {
+		t.Errorf("unexpected node fit")
+	}
+}
+
+func TestClusterNodesGroup(t *testing.T) {
+
+	ml := filepath.Join(t.TempDir(), "machines")
+	if err := os.WriteFile(ml, []byte(machinelistfile), 0644); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	cases := []struct {
+		args []string
+		ids  []string
+	}{
+		{[]string{"--group", "gpu"}, []string{"dccn-c083.dccn.nl", "dccn-c084.dccn.nl"}},
+		{[]string{"--group", "role=batch"}, []string{"dccn-c075.dccn.nl"}},
+		{[]string{"--group", "gpu", "dccn-c075"}, []string{"dccn-c075.dccn.nl", "dccn-c083.dccn.nl", "dccn-c084.dccn.nl"}},
+	}
+
+	for _, c := range cases {
+		var nodes []node.Node
+		args := append([]string{"cluster", "--scheduler", "slurm", "nodes", "status", "-o", "json", "--machine-list", ml}, c.args...)
+		if err := json.Unmarshal([]byte(execute(t, args...)), &nodes); err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		ids := []string{}
+		for _, n := range nodes {
+			ids = append(ids, n.ID)
+		}
+		if strings.Join(ids, ",") != strings.Join(c.ids, ",") {
+			t.Errorf("%s: expect %+v, got %+v", strings.Join(c.args, " "), c.ids, ids)
+		}
+	}
+
+	// the VNC sessions on the hosts of the group
+	var vncs []vncSession
+	out := execute(t, "cluster", "nodes", "vnc", "--source", "slurm", "-o", "json", "-l", ml, "--group", "interactive")
+	if err := json.Unmarshal([]byte(out), &vncs); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(vncs) != 1 || vncs[0].Host != "dccn-c084.dccn.nl" {
+		t.Errorf("unexpected VNC sessions of group interactive: %+v", vncs)
+	}
+}
+
+func TestClusterNodesVncSlurm(t *testing.T) {
+
+	var vncs []vncSession
+	out := execute(t, "cluster", "nodes", "vnc", "--source", "slurm", "-o", "json")
+	if err := json.Unmarshal([]byte(out), &vncs); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(vncs) != 2 {
+		t.Fatalf("expect 2 VNC sessions, got %d", len(vncs))
+	}
+	if v := vncs[1]; v.User != "user2" || v.Host != "dccn-c084.dccn.nl" || v.Display != 12 {
+		t.Errorf("unexpected VNC session: %+v", v)
+	}
+
+	// filter by the user and the host
+	out = execute(t, "cluster", "nodes", "vnc", "--source", "slurm", "-u", "user1", "dccn-c08[3-4]")
+	if !strings.Contains(out, "dccn-c083.dccn.nl:5") || strings.Contains(out, "user2") {
+		t.Errorf("unexpected VNC sessions of user1")
+	}
+
+	out = execute(t, "cluster", "nodes", "vnc", "--source", "slurm", "-o", "json", "dccn-c075")
+	if strings.TrimSpace(out) != "[]" {
+		t.Errorf("expect no VNC session on dccn-c075, got %s", out)
+	}
+}
+
+func TestClusterNodesVncStale(t *testing.T) {
+
+	// the VNC jobs are started in 2024, i.e. idle for longer than the default --max-idle
+	var stale []vncStaleSession
+	out := execute(t, "cluster", "nodes", "vnc", "--source", "slurm", "--stale", "-o", "json")
+	if err := json.Unmarshal([]byte(out), &stale); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(stale) != 2 {
+		t.Fatalf("expect 2 stale VNC sessions, got %d", len(stale))
+	}
+	if s := stale[0]; s.Session != "dccn-c083.dccn.nl:5" || s.JobID != "4330" || s.StartTime.IsZero() || len(s.Reasons) != 1 || !strings.HasPrefix(s.Reasons[0], "idle for") {
+		t.Errorf("unexpected stale VNC session: %+v", s)
+	}
+
+	// no check on the idle time, and at most one session per user
+	out = execute(t, "cluster", "nodes", "vnc", "--source", "slurm", "--stale", "--max-idle", "0", "--max-sessions", "1", "-o", "json")
+	if strings.TrimSpace(out) != "[]" {
+		t.Errorf("expect no stale VNC session, got %s", out)
+	}
+
+	var summaries []vncUserSummary
+	out = execute(t, "cluster", "nodes", "vnc", "--source", "slurm", "--stale", "--summary", "-u", "user2", "-o", "json")
+	if err := json.Unmarshal([]byte(out), &summaries); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(summaries) != 1 {
+		t.Fatalf("expect 1 user, got %d", len(summaries))
+	}
+	if u := summaries[0]; u.User != "user2" || u.Sessions != 1 || u.Stale != 1 || len(u.Hosts) != 1 || u.Hosts[0] != "dccn-c084.dccn.nl" {
+		t.Errorf("unexpected user summary: %+v", u)
+	}
+}
+
+func TestClusterNodesVncStartConnect(t *testing.T) {
+
+	var conns []vncConnection
+	out := execute(t, "cluster", "nodes", "vnc", "start", "--host", "dccn-c083", "--geometry", "2560x1440", "-o", "json")
+	if err := json.Unmarshal([]byte(out), &conns); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(conns) != 1 {
+		t.Fatalf("expect 1 VNC connection, got %d", len(conns))
+	}
+	if c := conns[0]; c.Session != "dccn-c083.dccn.nl:3" || c.Port != 5903 || !strings.HasPrefix(c.Tunnel, "ssh -N -L 5903:localhost:5903 ") {
+		t.Errorf("unexpected VNC connection: %+v", c)
+	}
+
+	out = execute(t, "cluster", "nodes", "vnc", "connect", "--gateway", "ssh.dccn.nl", "mentat001:12")
+	if !strings.Contains(out, "mentat001.dccn.nl:12 listens on port 5912") || !strings.Contains(out, "-L 5912:localhost:5912 -J ssh.dccn.nl ") {
+		t.Errorf("unexpected VNC connection info")
+	}
+}
+
+func TestCheckVNCHost(t *testing.T) {
+
+	ml := filepath.Join(t.TempDir(), "machines")
+	if err := os.WriteFile(ml, []byte(machinelistfile), 0644); err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer func(f string) { nodeMachineListFile = f }(nodeMachineListFile)
+	nodeMachineListFile = ml
+
+	cases := map[string]bool{
+		"dccn-c083":                       true,
+		"dccn-c084.dccn.nl":               true,
+		"dccn-c099":                       false,
+		"-oProxyCommand=touch /tmp/owned": false,
+		"dccn-c083 -v":                    false,
+	}
+	for host, ok := range cases {
+		if err := checkVNCHost(host); (err == nil) != ok {
+			t.Errorf("host %q: expect valid %t, got %v", host, ok, err)
+		}
+	}
+}
+
+func TestClusterJobs(t *testing.T) {
+
+	var jobs []scheduler.Job
+	out := execute(t, "cluster", "--scheduler", "slurm", "jobs", "-o", "json")
+	if err := json.Unmarshal([]byte(out), &jobs); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(jobs) != 2 {
+		t.Fatalf("expect 2 jobs, got %d", len(jobs))
+	}
+	if j := jobs[1]; j.ID != "4325" || j.State != "PENDING" || j.Reason != "Resources" || j.Name != "train|model" {
+		t.Errorf("unexpected job: %+v", j)
+	}
+
+	out = execute(t, "cluster", "--scheduler", "slurm", "jobs")
+	if !strings.Contains(out, "(Resources)") {
+		t.Errorf("pending reason is not shown")
+	}
+}
+
+func TestClusterPartitions(t *testing.T) {
+	out := execute(t, "cluster", "partitions", "gpu")
+	if !strings.Contains(out, "124/126") || strings.Contains(out, "batch") {
+		t.Errorf("unexpected partition output")
+	}
+
+	// the memory limit of 16384 MB per CPU
+	if !strings.Contains(out, "16.0") {
+		t.Errorf("unexpected max memory per CPU")
+	}
+
+	var partitions []partitionRecord
+	out = execute(t, "cluster", "partitions", "-o", "json")
+	if err := json.Unmarshal([]byte(out), &partitions); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(partitions) != 2 {
+		t.Fatalf("expect 2 partitions, got %d", len(partitions))
+	}
+	if p := partitions[1]; p.Name != "gpu" || p.MaxMemPerCPU != 16384 || p.TotalCPUs != 126 || p.MaxNodes != 1 {
+		t.Errorf("unexpected partition: %+v", p)
+	}
+}
+
+func TestClusterJob(t *testing.T) {
+
+	out := execute(t, "cluster", "--scheduler", "slurm", "job", "info", "4322")
+	if !strings.Contains(out, "4321_7") || !strings.Contains(out, "gres/gpu=1") {
+		t.Errorf("unexpected job info")
+	}
+
+	out = execute(t, "cluster", "--scheduler", "slurm", "job", "trace", "4300")
+	if !strings.Contains(out, "REQUEUE") || !strings.Contains(out, "STEP END") {
+		t.Errorf("unexpected job trace")
+	}
+
+	out = execute(t, "cluster", "job", "efficiency", "4300")
+	if !strings.Contains(out, "75.0%") || !strings.Contains(out, "62.5%") {
+		t.Errorf("unexpected job efficiency")
+	}
+
+	var effs []jobEfficiency
+	out = execute(t, "cluster", "job", "efficiency", "-o", "json", "4300")
+	if err := json.Unmarshal([]byte(out), &effs); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(effs) != 1 || effs[0].CPUEfficiency != 0.75 || effs[0].ReqMemGB != 32 || len(effs[0].Steps) != 2 {
+		t.Errorf("unexpected job efficiency: %+v", effs)
+	}
+
+	out = execute(t, "cluster", "--scheduler", "slurm", "job", "meminfo", "4322")
+	if !strings.Contains(out, "4322.batch") || !strings.Contains(out, "7.00") {
+		t.Errorf("unexpected job memory usage")
+	}
+}
+
+func TestClusterMatlablic(t *testing.T) {
+
+	var lics []matlabLicense
+	out := execute(t, "cluster", "matlablic", "-o", "json")
+	if err := json.Unmarshal([]byte(out), &lics); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(lics) != 3 || lics[0].InUse != 4 || len(lics[0].Usages) != 3 {
+		t.Errorf("unexpected licenses: %+v", lics)
+	}
+
+	out = execute(t, "cluster", "matlablic")
+	if !strings.Contains(out, "package MATLAB: 4 of 10 in use (2 by dccn users)") {
+		t.Errorf("unexpected license summary")
+	}
+}
+
+func TestClusterConfig(t *testing.T) {
+	out := execute(t, "cluster", "--scheduler", "slurm", "config")
+	if !strings.Contains(out, "ClusterName             = dccn") {
+		t.Errorf("unexpected cluster config")
+	}
+}
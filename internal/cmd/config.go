@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Donders-Institute/hpc-utility/internal/config"
+	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Profile is the name of the configuration profile.
+var Profile string
+
+// cfg is the layered configuration loaded at the start of the command.
+var cfg *config.Config
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&Profile, "profile", "", "", "configuration profile, e.g. a cluster defined in the configuration file")
+
+	cfgCmd.AddCommand(cfgShowCmd)
+	rootCmd.AddCommand(cfgCmd)
+}
+
+var cfgCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the hpcutil configuration.",
+	Long: fmt.Sprintf(`Manage the hpcutil configuration.
+
+Flag values not given on the command line are taken from the following configuration
+layers, a later layer overrides an earlier one:
+
+  1. the system configuration file: %s
+  2. the user configuration file: %s
+  3. the environment variables, e.g. HPCUTIL_CLUSTER_SERVER for "cluster.server"
+
+The configuration key of a flag is made of the command path and the flag name, e.g. the
+key of the "--server" flag of the "cluster" command is "cluster.server".  In the
+configuration files, values of the profile selected by the "--profile" flag override the
+top-level values.`, config.SystemFile, config.UserFile()),
+}
+
+var cfgShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration values and their origin.",
+	Long:  ``,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		settings := []setting{}
+		walkFlags(rootCmd, func(key string, c *cobra.Command, f *pflag.Flag) {
+			s := setting{Key: key, Value: f.DefValue, Origin: "default"}
+
+			// `cmd.Flags()` contains the flags of this command and the inherited ones.
+			if cf := cmd.Flags().Lookup(f.Name); cf == f && f.Changed {
+				s.Value, s.Origin = f.Value.String(), "flag"
+			} else if v, o, ok := cfg.Lookup(key); ok {
+				s.Value, s.Origin = v, o
+			}
+
+			settings = append(settings, s)
+		})
+
+		renderOutput(settings, func(w io.Writer) {
+			if Profile != "" {
+				fmt.Fprintf(w, "Profile: %s\n", Profile)
+			}
+			table := tablewriter.NewWriter(w)
+			table.SetHeader([]string{"key", "value", "origin"})
+			table.SetAutoWrapText(false)
+			table.SetAlignment(tablewriter.ALIGN_LEFT)
+			for _, s := range settings {
+				table.Append([]string{s.Key, s.Value, s.Origin})
+			}
+			table.Render()
+		})
+	},
+}
+
+// setting defines the output record of a configuration value in the `config show` command.
+type setting struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// Origin is the layer the value is taken from, i.e. `default`, `flag`, the path of the
+	// configuration file, or the environment variable.
+	Origin string `json:"origin"`
+}
+
+// flagKey returns the configuration key of the flag `name` defined on the command `c`.
+func flagKey(c *cobra.Command, name string) string {
+	path := strings.Fields(c.CommandPath())[1:]
+	return strings.Join(append(path, name), ".")
+}
+
+// walkFlags calls `f` recursively on the flags defined on the command `c` and the
+// sub-commands, with the configuration key of the flag.  The `help` flags are skipped.
+func walkFlags(c *cobra.Command, f func(key string, c *cobra.Command, flag *pflag.Flag)) {
+	c.LocalFlags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Name == "help" {
+			return
+		}
+		f(flagKey(c, flag.Name), c, flag)
+	})
+	for _, sub := range c.Commands() {
+		walkFlags(sub, f)
+	}
+}
+
+// definingCommand returns the command on which the flag `name` of the command `c` is
+// defined, i.e. `c` itself or the nearest ancestor with the persistent flag.
+func definingCommand(c *cobra.Command, name string) *cobra.Command {
+	for p := c; p != nil; p = p.Parent() {
+		if p.LocalNonPersistentFlags().Lookup(name) != nil || p.PersistentFlags().Lookup(name) != nil {
+			return p
+		}
+	}
+	return c
+}
+
+// applyConfig loads the configuration layers and sets the values of the flags of the
+// command `c` that are not given on the command line.
+func applyConfig(c *cobra.Command) error {
+
+	var err error
+	if cfg, err = config.Load(config.SystemFile, config.UserFile()); err != nil {
+		return err
+	}
+
+	// set value of a flag not given on the command line
+	apply := func(f *pflag.Flag) error {
+		if f.Changed || f.Name == "help" {
+			return nil
+		}
+		key := flagKey(definingCommand(c, f.Name), f.Name)
+		v, origin, ok := cfg.Lookup(key)
+		if !ok {
+			return nil
+		}
+		if strings.Contains(origin, "deprecated key") {
+			log.Warnf("%s is set by a deprecated key, use %s instead: %s", key, key, origin)
+		}
+		log.Debugf("set %s from %s: %s", key, origin, v)
+		if err := f.Value.Set(v); err != nil {
+			return fmt.Errorf("invalid value of %s from %s: %s", key, origin, err)
+		}
+		return nil
+	}
+
+	// the profile should be resolved before other flags
+	if f := c.Flags().Lookup("profile"); f != nil {
+		if err := apply(f); err != nil {
+			return err
+		}
+	}
+	if err := cfg.UseProfile(Profile); err != nil {
+		return err
+	}
+
+	var errs []string
+	c.Flags().VisitAll(func(f *pflag.Flag) {
+		if f.Name == "profile" {
+			return
+		}
+		if err := apply(f); err != nil {
+			errs = append(errs, err.Error())
+		}
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
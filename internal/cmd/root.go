@@ -1,9 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/Donders-Institute/hpc-utility/internal/output"
+	"github.com/Donders-Institute/hpc-utility/internal/util"
 	"github.com/olekukonko/tablewriter"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -29,6 +37,25 @@ var TorqueHelperCert string
 // It allows commands to accept short hostname specification in arguments.
 var NetDomain string
 
+// OutputFormat is the output format of the commands, i.e. one of the `output.Formats`.
+var OutputFormat string
+
+// exitGracePeriod is the time given to the command to return after the interrupt signal.
+const exitGracePeriod = 3 * time.Second
+
+// ExecTimeout is the default timeout of the system calls, e.g. `scontrol` or `lmstat`.
+var ExecTimeout time.Duration
+
+// ExecTimeouts are the timeouts of the system calls by the command name, e.g. `lmstat=10s`.
+var ExecTimeouts map[string]string
+
+// ExecRecordDir is the directory in which outputs of the system calls are recorded.
+var ExecRecordDir string
+
+// ExecReplayDir is the directory from which the recorded outputs of the system calls are
+// replayed instead of making the system calls.
+var ExecReplayDir string
+
 // NewHpcutilCmd returns the root command.
 func NewHpcutilCmd() *cobra.Command {
 	return rootCmd
@@ -62,6 +89,16 @@ __custom_func() {
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&Verbose, "verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().StringVarP(&NetDomain, "domain", "d", "dccn.nl", "default network domain")
+	rootCmd.PersistentFlags().StringVarP(&OutputFormat, "output", "o", output.FormatTable,
+		fmt.Sprintf("output format: %s", strings.Join(output.Formats, ", ")))
+	rootCmd.PersistentFlags().DurationVarP(&ExecTimeout, "timeout", "", util.DefaultExecutor.Timeout,
+		"timeout of the system calls, e.g. scontrol, and of the Torque helper calls; 0 for no timeout")
+	rootCmd.PersistentFlags().StringToStringVarP(&ExecTimeouts, "timeouts", "", map[string]string{},
+		"timeouts of specific system calls, e.g. scontrol=30s,lmstat=10s,trqhelper=30s")
+	rootCmd.PersistentFlags().StringVarP(&ExecRecordDir, "exec-record", "", "", "record outputs of the system calls into the directory")
+	rootCmd.PersistentFlags().StringVarP(&ExecReplayDir, "exec-replay", "", "", "replay outputs of the system calls recorded in the directory")
+	rootCmd.PersistentFlags().MarkHidden("exec-record")
+	rootCmd.PersistentFlags().MarkHidden("exec-replay")
 	rootCmd.AddCommand(versionCmd, availCmd)
 }
 
@@ -70,9 +107,35 @@ var rootCmd = &cobra.Command{
 	Short: "Unified CLI for various HPC cluster utilities.",
 	Long:  `A unified command-line interface for different HPC cluster utilities.`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		if cmd.Flags().Changed("verbose") {
+		if err := applyConfig(cmd); err != nil {
+			log.Fatalln(err)
+		}
+		// the schedulers are resolved again for the command
+		schedulers.resolved = false
+		if Verbose {
 			log.SetLevel(log.DebugLevel)
 		}
+		if err := output.CheckFormat(OutputFormat); err != nil {
+			log.Fatalln(err)
+		}
+
+		// timeouts of the system calls
+		util.DefaultExecutor.Timeout = ExecTimeout
+		for c, v := range ExecTimeouts {
+			t, err := time.ParseDuration(v)
+			if err != nil {
+				log.Fatalf("invalid timeout of %s: %s", c, err)
+			}
+			util.DefaultExecutor.Timeouts[c] = t
+		}
+
+		// record or replay outputs of the system calls, e.g. for offline testing
+		switch {
+		case ExecReplayDir != "":
+			util.DefaultRunner = &util.ReplayRunner{Dir: ExecReplayDir}
+		case ExecRecordDir != "":
+			util.DefaultRunner = &util.RecordRunner{Runner: util.DefaultExecutor, Dir: ExecRecordDir}
+		}
 	},
 	BashCompletionFunction: funcBashCompletion,
 }
@@ -120,9 +183,36 @@ func addCommandUseToTable(cmd *cobra.Command, parentUse string, table *tablewrit
 	}
 }
 
-// Execute is the main entry point of the cluster command.
+// renderOutput writes the typed `records` to the output of the root command, i.e. the stdout,
+// in the format given by the `--output` flag.  The function `table` renders the records in
+// the human-readable table.
+func renderOutput(records interface{}, table func(w io.Writer)) {
+	if err := output.Render(rootCmd.OutOrStdout(), OutputFormat, records, table); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// Execute is the main entry point of the cluster command.  The command context is cancelled
+// on SIGINT (i.e. Ctrl-C) or SIGTERM, which terminates the ongoing system calls.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// force exit if the command does not return shortly after the cancellation, e.g. when
+	// it is blocked by a remote call not bound to the context.
+	go func() {
+		<-ctx.Done()
+		stop()
+		time.Sleep(exitGracePeriod)
+		log.Errorln("interrupted")
+		os.Exit(130)
+	}()
+
+	// the output is bound to the stdout at the start, so that it is not affected by capturing
+	// the stdout of the Torque helper client, see `util.CaptureStdout`.
+	rootCmd.SetOut(os.Stdout)
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		log.Errorln(err)
 		os.Exit(1)
 	}
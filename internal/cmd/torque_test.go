@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Donders-Institute/hpc-utility/internal/node"
+	"github.com/Donders-Institute/hpc-utility/internal/torque/torquetest"
+)
+
+var (
+	checknodeXML = `<Data>
+<node NODEID="dccn-c005" NODESTATE="Busy" FEATURES="matlab,network10GigE,intel,ram128gb" RCPROC="16" RAPROC="0" RCDISK="409600" RADISK="204800" RAMEM="16384" GRES="" AGRES=""></node>
+<node NODEID="dccn-c006" NODESTATE="Idle" FEATURES="cuda,network10GigE,amd,ram256gb" RCPROC="32" RAPROC="24" RCDISK="409600" RADISK="409600" RAMEM="196608" GRES="gpus=2" AGRES="gpus=1"></node>
+<node NODEID="GLOBAL" NODESTATE="Idle"></node>
+</Data>`
+
+	qstatJobXML = `<Data><Job><Job_Id>123.dccn-l029.dccn.nl</Job_Id><req_information><hostlist.0>localhost:ppn=1</hostlist.0></req_information></Job></Data>`
+)
+
+// newTorqueServer starts the fake Torque helper server, and returns the flags of the cluster
+// command for connecting to it.  The hosts of the fake cluster, i.e. `localhost`, are not in a
+// network domain.
+func newTorqueServer(t *testing.T) (*torquetest.Server, []string) {
+	srv, err := torquetest.NewServer()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	t.Cleanup(srv.Close)
+
+	return srv, []string{
+		"--domain", "",
+		"--scheduler", "torque",
+		"--server", srv.Host,
+		"--port", strconv.Itoa(srv.Port),
+		"--cert", srv.CertFile,
+	}
+}
+
+// cluster runs the cluster command with `flags` and `args`, and returns the stdout.
+func cluster(t *testing.T, flags []string, args ...string) string {
+	return execute(t, append(append([]string{"cluster"}, flags...), args...)...)
+}
+
+func TestTorqueQstat(t *testing.T) {
+
+	srv, flags := newTorqueServer(t)
+	srv.Reply("Qstat", "123.dccn-l029  job1  user1  00:10:00 R batch")
+	srv.Reply("Qstatx", qstatJobXML)
+
+	if out := cluster(t, flags, "qstat"); !strings.Contains(out, "job1  user1") {
+		t.Errorf("unexpected qstat output")
+	}
+
+	if out := cluster(t, flags, "qstat", "--xml"); !strings.Contains(out, "<Job_Id>123.dccn-l029.dccn.nl</Job_Id>") {
+		t.Errorf("unexpected qstat XML output")
+	}
+}
+
+func TestTorqueConfig(t *testing.T) {
+
+	srv, flags := newTorqueServer(t)
+	srv.Reply("TorqueConfig", "set server scheduling = True")
+	srv.Reply("MoabConfig", "SCHEDCFG[dccn] SERVER=dccn-l029:42559")
+
+	out := cluster(t, flags, "config")
+	if !strings.Contains(out, "set server scheduling = True") || !strings.Contains(out, "SCHEDCFG[dccn]") {
+		t.Errorf("unexpected cluster config")
+	}
+
+	// the Moab config is not retrieved if the Torque config fails
+	srv.Handle("TorqueConfig", func(arg string) torquetest.Response {
+		return torquetest.Response{ExitCode: 1, Error: "permission denied"}
+	})
+	if out := cluster(t, flags, "config"); strings.Contains(out, "SCHEDCFG[dccn]") {
+		t.Errorf("unexpected Moab config after the Torque config failure")
+	}
+}
+
+func TestTorqueJobTrace(t *testing.T) {
+
+	srv, flags := newTorqueServer(t)
+	srv.Handle("TraceJob", func(arg string) torquetest.Response {
+		return torquetest.Response{Data: fmt.Sprintf("Job: %s\n\n11/18/2024 09:21:00  S    enqueuing into batch", arg)}
+	})
+
+	out := cluster(t, flags, "job", "trace", "123")
+	if !strings.Contains(out, "Job: 123") || !strings.Contains(out, "enqueuing into batch") {
+		t.Errorf("unexpected job trace")
+	}
+}
+
+func TestTorqueJobMeminfo(t *testing.T) {
+
+	// the Torque helper client resolves the execution host of the job with a local `qstat -x`.
+	bin := t.TempDir()
+	script := fmt.Sprintf("#!/bin/sh\ncat <<EOF\n%s\nEOF\n", qstatJobXML)
+	if err := os.WriteFile(filepath.Join(bin, "qstat"), []byte(script), 0755); err != nil {
+		t.Fatalf("%s", err)
+	}
+	t.Setenv("PATH", fmt.Sprintf("%s%c%s", bin, os.PathListSeparator, os.Getenv("PATH")))
+
+	srv, flags := newTorqueServer(t)
+	srv.Handle("JobMemInfo", func(arg string) torquetest.Response {
+		return torquetest.Response{Data: fmt.Sprintf("job %s: rss 1.2 GB", arg)}
+	})
+
+	out := cluster(t, flags, "job", "meminfo", "123")
+	if !strings.Contains(out, "job 123: rss 1.2 GB") {
+		t.Errorf("unexpected job memory usage")
+	}
+}
+
+func TestTorqueNodesStatus(t *testing.T) {
+
+	srv, flags := newTorqueServer(t)
+	srv.Handle("Checknode", func(arg string) torquetest.Response {
+		if arg != "ALL" {
+			return torquetest.Response{ExitCode: 1, Error: fmt.Sprintf("cannot locate node %s", arg)}
+		}
+		return torquetest.Response{Data: checknodeXML}
+	})
+
+	var nodes []node.Node
+	out := cluster(t, flags, "nodes", "status", "-o", "json")
+	if err := json.Unmarshal([]byte(out), &nodes); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(nodes) != 2 {
+		t.Fatalf("expect 2 nodes, got %d", len(nodes))
+	}
+	if n := nodes[0]; n.ID != "dccn-c005" || n.Cluster != node.ClusterTorque || n.CPUVendor != "INTEL" || n.TotalMemGB != 128 || n.AvailMemGB != 16 {
+		t.Errorf("unexpected node: %+v", n)
+	}
+	if n := nodes[1]; n.ID != "dccn-c006" || n.AvailProcs != 24 || n.AvailGPUS != 1 || n.TotalGPUS != 2 {
+		t.Errorf("unexpected node: %+v", n)
+	}
+
+	out = cluster(t, flags, "nodes", "status", "--procs", "--features", "cuda")
+	if !strings.Contains(out, "24/32") || !strings.Contains(out, "cuda") {
+		t.Errorf("unexpected node status table")
+	}
+
+	// unknown node
+	if out = cluster(t, flags, "nodes", "status", "-o", "json", "dccn-c999"); strings.TrimSpace(out) != "[]" {
+		t.Errorf("unexpected status of unknown node: %s", out)
+	}
+	if calls := srv.Calls(); calls[len(calls)-1].Arg != "dccn-c999" {
+		t.Errorf("unexpected node id in the request: %+v", calls[len(calls)-1])
+	}
+}
+
+func TestTorqueNodesVnc(t *testing.T) {
+
+	srv, flags := newTorqueServer(t)
+	srv.Reply("GetVNCServers", `user1                 1552 /usr/bin/Xvnc :51 -auth /home/user1/.Xauthority
+user2                 2050 /usr/bin/Xvnc :9 -auth /home/user2/.Xauthority`)
+
+	var vncs []vncSession
+	out := cluster(t, flags, "nodes", "vnc", "-o", "json", srv.Host)
+	if err := json.Unmarshal([]byte(out), &vncs); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(vncs) != 2 {
+		t.Fatalf("expect 2 VNC sessions, got %d", len(vncs))
+	}
+	// sorted by the display number
+	if v := vncs[0]; v.User != "user2" || v.Host != srv.Host || v.Display != 9 {
+		t.Errorf("unexpected VNC session: %+v", v)
+	}
+
+	out = cluster(t, flags, "nodes", "vnc", "-u", "user1", srv.Host)
+	if !strings.Contains(out, fmt.Sprintf("%s:51", srv.Host)) || strings.Contains(out, "user2") {
+		t.Errorf("unexpected VNC sessions of user1")
+	}
+
+	// the helper gives no activity, so the sessions are not reported as idle
+	var stale []vncStaleSession
+	out = cluster(t, flags, "nodes", "vnc", "--stale", "-o", "json", srv.Host)
+	if err := json.Unmarshal([]byte(out), &stale); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(stale) != 0 {
+		t.Errorf("expect no stale VNC sessions, got %+v", stale)
+	}
+
+	var summaries []vncUserSummary
+	out = cluster(t, flags, "nodes", "vnc", "--stale", "--summary", "-o", "json", srv.Host)
+	if err := json.Unmarshal([]byte(out), &summaries); err != nil {
+		t.Fatalf("%s", err)
+	}
+	for _, u := range summaries {
+		if u.IdleUnknown != 1 || u.Stale != 0 {
+			t.Errorf("expect 1 session with unknown idle time of %s, got %+v", u.User, u)
+		}
+	}
+}
+
+func TestTorqueNodesVncStop(t *testing.T) {
+
+	me, err := user.Current()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	srv, flags := newTorqueServer(t)
+	srv.Reply("GetVNCServers", fmt.Sprintf(`%s                 1552 /usr/bin/Xvnc :51 -auth /home/user1/.Xauthority
+user2                 2050 /usr/bin/Xvnc :9 -auth /home/user2/.Xauthority`, me.Username))
+
+	// the VNC server is killed on the local host
+	out := cluster(t, flags, "nodes", "vnc", "stop", fmt.Sprintf("%s:51", srv.Host))
+	if !strings.Contains(out, fmt.Sprintf("VNC session %s:51 stopped", srv.Host)) {
+		t.Errorf("unexpected output of stopping VNC session")
+	}
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -82,9 +83,13 @@ var listCmd = &cobra.Command{
 			log.Errorf("fail retriving list of webhooks: %+v\n", err)
 			return
 		}
+		var infoList []webhookInfo
 		for w := range ws {
-			printWebhookConfigInfo(w)
+			infoList = append(infoList, newWebhookInfo(w))
 		}
+		renderOutput(infoList, func(w io.Writer) {
+			printWebhookInfo(w, infoList...)
+		})
 	},
 }
 
@@ -120,14 +125,18 @@ var infoCmd = &cobra.Command{
 			HPCWebhookPort:     webhookPort,
 			HPCWebhookCertFile: webhookCertFile,
 		}
+		var infoList []webhookInfo
 		for _, id := range args {
 			if info, err := webhook.GetInfo(id); err != nil {
 				log.Errorf("%s: %s\n", err, id)
 				continue
 			} else {
-				printWebhookConfigInfo(info)
+				infoList = append(infoList, newWebhookInfo(info))
 			}
 		}
+		renderOutput(infoList, func(w io.Writer) {
+			printWebhookInfo(w, infoList...)
+		})
 	},
 }
 
@@ -189,14 +198,35 @@ var triggerCmd = &cobra.Command{
 	},
 }
 
-// printWebhookConfigInfo writes one or multiple WebhookConfigInfo data objects to the stdout.
-func printWebhookConfigInfo(infoList ...whc.WebhookConfigInfo) {
+// webhookInfo defines the output record of a webhook in the `webhook list` and `webhook info`
+// commands.
+type webhookInfo struct {
+	ID           string `json:"id"`
+	Description  string `json:"description"`
+	CreationTime string `json:"creation_time"`
+	Script       string `json:"script"`
+	WebhookURL   string `json:"url"`
+}
+
+// newWebhookInfo converts the WebhookConfigInfo data object into the `webhookInfo` record.
+func newWebhookInfo(info whc.WebhookConfigInfo) webhookInfo {
+	return webhookInfo{
+		ID:           info.ID,
+		Description:  info.Description,
+		CreationTime: info.CreationTime,
+		Script:       info.Script,
+		WebhookURL:   info.WebhookURL,
+	}
+}
+
+// printWebhookInfo writes one or multiple `webhookInfo` records to `w`.
+func printWebhookInfo(w io.Writer, infoList ...webhookInfo) {
 	for _, info := range infoList {
-		fmt.Printf("\n%-s", info.ID)
-		fmt.Printf("\n\t%-16s: %-s", "Description", info.Description)
-		fmt.Printf("\n\t%-16s: %-s", "Creation time", info.CreationTime)
-		fmt.Printf("\n\t%-16s: %-s", "Script path", info.Script)
-		fmt.Printf("\n\t%-16s: %-s", "Webhook URL", info.WebhookURL)
-		fmt.Println()
+		fmt.Fprintf(w, "\n%-s", info.ID)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Description", info.Description)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Creation time", info.CreationTime)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Script path", info.Script)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Webhook URL", info.WebhookURL)
+		fmt.Fprintln(w)
 	}
 }
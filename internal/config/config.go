@@ -0,0 +1,277 @@
+// Package config implements the layered configuration of the CLI.  Configuration values are
+// resolved from the following layers, a later layer overrides an earlier one:
+//
+//  1. the system configuration file, i.e. `SystemFile`,
+//  2. the user configuration file, i.e. `UserFile()`,
+//  3. the environment variables, i.e. `EnvName(key)`.
+//
+// Values given by the command-line flags override all the layers; it is up to the caller to
+// skip the lookup for those values.
+//
+// A configuration file is in YAML.  The keys are organised in the same hierarchy as the
+// commands; values of the profiles, selected by name, override the values at the top level
+// of the same file.  For example:
+//
+// ```
+// domain: dccn.nl
+// cluster:
+//
+//	server: torque.dccn.nl
+//	nodes:
+//	  machine-list: /opt/cluster/etc/machinelist
+//
+// profiles:
+//
+//	test:
+//	  cluster:
+//	    server: torque-test.dccn.nl
+//
+// ```
+//
+// The value of the key `cluster.nodes.machine-list` is `/opt/cluster/etc/machinelist`, and
+// the value of `cluster.server` is `torque-test.dccn.nl` when the profile `test` is used.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// SystemFile is the path of the system configuration file.
+	SystemFile = "/etc/hpcutil/config.yaml"
+
+	// envPrefix is the prefix of the environment variables.
+	envPrefix = "HPCUTIL_"
+
+	// profilesKey is the top-level key of the profiles in the configuration file.
+	profilesKey = "profiles"
+)
+
+// UserFile returns the path of the user configuration file, i.e.
+// `$XDG_CONFIG_HOME/hpcutil/config.yaml` or `~/.config/hpcutil/config.yaml`.
+func UserFile() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "hpcutil", "config.yaml")
+}
+
+// EnvName returns the name of the environment variable of the configuration `key`, e.g.
+// `HPCUTIL_CLUSTER_NODES_MACHINE_LIST` for `cluster.nodes.machine-list`.
+func EnvName(key string) string {
+	return envPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// file is a loaded configuration file.
+type file struct {
+	path string
+	// values are the top-level values by the dotted key.
+	values map[string]string
+	// profiles are the values of the profiles by the profile name and the dotted key.
+	profiles map[string]map[string]string
+}
+
+// Config is the layered configuration.
+type Config struct {
+	files   []file
+	profile string
+	// getenv returns the value of an environment variable; it is `os.Getenv` by default.
+	getenv func(string) string
+}
+
+// Load loads the configuration `files` in the order of precedence from low to high.
+// Files that do not exist are skipped.
+func Load(paths ...string) (*Config, error) {
+
+	c := &Config{getenv: os.Getenv}
+
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+
+		data, err := os.ReadFile(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		f, err := parseFile(p, data)
+		if err != nil {
+			return nil, err
+		}
+		c.files = append(c.files, f)
+	}
+
+	return c, nil
+}
+
+// parseFile converts the YAML content of the configuration file `path` into `file`.
+func parseFile(path string, data []byte) (file, error) {
+
+	f := file{
+		path:     path,
+		values:   make(map[string]string),
+		profiles: make(map[string]map[string]string),
+	}
+
+	var doc map[interface{}]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return f, fmt.Errorf("invalid configuration file %s: %s", path, err)
+	}
+
+	for k, v := range doc {
+		if fmt.Sprint(k) != profilesKey {
+			if err := flatten(fmt.Sprint(k), v, f.values); err != nil {
+				return f, fmt.Errorf("invalid configuration file %s: %s", path, err)
+			}
+			continue
+		}
+
+		profiles, ok := v.(map[interface{}]interface{})
+		if !ok {
+			return f, fmt.Errorf("invalid configuration file %s: %s is not a mapping", path, profilesKey)
+		}
+		for name, pv := range profiles {
+			values := make(map[string]string)
+			pm, ok := pv.(map[interface{}]interface{})
+			if !ok {
+				return f, fmt.Errorf("invalid configuration file %s: profile %v is not a mapping", path, name)
+			}
+			for k, v := range pm {
+				if err := flatten(fmt.Sprint(k), v, values); err != nil {
+					return f, fmt.Errorf("invalid configuration file %s: profile %v: %s", path, name, err)
+				}
+			}
+			f.profiles[fmt.Sprint(name)] = values
+		}
+	}
+
+	return f, nil
+}
+
+// flatten adds the value `v` of `key` into `values`.  Nested mappings result in dotted keys,
+// and a list of scalars is joined with `,`.
+func flatten(key string, v interface{}, values map[string]string) error {
+
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		for k, e := range v {
+			if err := flatten(fmt.Sprintf("%s.%v", key, k), e, values); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		elems := make([]string, 0, len(v))
+		for _, e := range v {
+			switch e.(type) {
+			case map[interface{}]interface{}, []interface{}:
+				return fmt.Errorf("%s: list of non-scalar values", key)
+			}
+			elems = append(elems, fmt.Sprint(e))
+		}
+		values[key] = strings.Join(elems, ",")
+	case nil:
+		values[key] = ""
+	default:
+		values[key] = fmt.Sprint(v)
+	}
+
+	return nil
+}
+
+// Profiles returns names of the profiles defined in the configuration files.
+func (c *Config) Profiles() []string {
+	names := []string{}
+	seen := make(map[string]bool)
+	for _, f := range c.files {
+		for n := range f.profiles {
+			if !seen[n] {
+				seen[n] = true
+				names = append(names, n)
+			}
+		}
+	}
+	return names
+}
+
+// UseProfile selects the profile `name` for the lookup of values.  An empty `name` means no
+// profile.
+func (c *Config) UseProfile(name string) error {
+	if name != "" {
+		found := false
+		for _, f := range c.files {
+			if _, ok := f.profiles[name]; ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown profile: %s", name)
+		}
+	}
+	c.profile = name
+	return nil
+}
+
+// deprecatedKeys maps a configuration key to its former name.  The former name is still
+// looked up, with a lower precedence than the current name within the same layer.
+var deprecatedKeys = map[string]string{
+	// the machinelist is used by all `nodes` subcommands, not only by `nodes vnc`.
+	"cluster.nodes.machine-list": "cluster.nodes.vnc.machine-list",
+}
+
+// Lookup returns the value of the configuration `key` from the layer with the highest
+// precedence, together with the origin of the value.  The last return value is false if
+// the key is not configured in any layer.  A value configured with the deprecated name of
+// the key is returned with the deprecated name mentioned in the origin.
+func (c *Config) Lookup(key string) (value, origin string, ok bool) {
+
+	keys := []string{key}
+	if old, ok := deprecatedKeys[key]; ok {
+		keys = append(keys, old)
+	}
+
+	// deprecated adds a note on the deprecated name `k` to the origin `o`.
+	deprecated := func(o, k string) string {
+		if k == key {
+			return o
+		}
+		return fmt.Sprintf("%s (deprecated key %s)", o, k)
+	}
+
+	for _, k := range keys {
+		env := EnvName(k)
+		if v := c.getenv(env); v != "" {
+			return v, deprecated(fmt.Sprintf("env %s", env), k), true
+		}
+	}
+
+	for i := len(c.files) - 1; i >= 0; i-- {
+		f := c.files[i]
+		for _, k := range keys {
+			if v, ok := f.profiles[c.profile][k]; ok && c.profile != "" {
+				return v, deprecated(fmt.Sprintf("%s (profile %s)", f.path, c.profile), k), true
+			}
+		}
+		for _, k := range keys {
+			if v, ok := f.values[k]; ok {
+				return v, deprecated(f.path, k), true
+			}
+		}
+	}
+
+	return "", "", false
+}
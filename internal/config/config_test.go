@@ -0,0 +1,141 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var systemConfig = `
+domain: dccn.nl
+cluster:
+  server: torque.dccn.nl
+  port: 60209
+profiles:
+  test:
+    cluster:
+      server: torque-test.dccn.nl
+`
+
+var userConfig = `
+cluster:
+  port: 60210
+  nodes:
+    status:
+      features: [matlab, cuda]
+profiles:
+  test:
+    cluster:
+      port: 60211
+  prod: {}
+`
+
+func writeConfig(t *testing.T, name, content string) string {
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatalf("%s", err)
+	}
+	return p
+}
+
+func TestLookup(t *testing.T) {
+
+	sys := writeConfig(t, "system.yaml", systemConfig)
+	usr := writeConfig(t, "user.yaml", userConfig)
+
+	c, err := Load(sys, usr, filepath.Join(t.TempDir(), "notexist.yaml"))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	env := map[string]string{"HPCUTIL_DOMAIN": "example.org"}
+	c.getenv = func(k string) string { return env[k] }
+
+	cases := []struct {
+		profile string
+		key     string
+		value   string
+		origin  string
+	}{
+		{"", "domain", "example.org", "env HPCUTIL_DOMAIN"},
+		{"", "cluster.server", "torque.dccn.nl", sys},
+		{"", "cluster.port", "60210", usr},
+		{"", "cluster.nodes.status.features", "matlab,cuda", usr},
+		{"test", "cluster.server", "torque-test.dccn.nl", sys + " (profile test)"},
+		{"test", "cluster.port", "60211", usr + " (profile test)"},
+		{"prod", "cluster.port", "60210", usr},
+	}
+
+	for _, tc := range cases {
+		if err := c.UseProfile(tc.profile); err != nil {
+			t.Fatalf("%s", err)
+		}
+		v, o, ok := c.Lookup(tc.key)
+		t.Logf("[%s] %s = %s (%s)", tc.profile, tc.key, v, o)
+		if !ok || v != tc.value || o != tc.origin {
+			t.Errorf("[%s] %s: expect %s (%s), got %s (%s)", tc.profile, tc.key, tc.value, tc.origin, v, o)
+		}
+	}
+
+	if _, _, ok := c.Lookup("webhook.server"); ok {
+		t.Errorf("unexpected value of webhook.server")
+	}
+
+	if err := c.UseProfile("unknown"); err == nil {
+		t.Errorf("expect error for unknown profile")
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if n := EnvName("cluster.nodes.machine-list"); n != "HPCUTIL_CLUSTER_NODES_MACHINE_LIST" {
+		t.Errorf("unexpected environment variable name: %s", n)
+	}
+}
+
+func TestLookupDeprecated(t *testing.T) {
+
+	sys := writeConfig(t, "system.yaml", `
+cluster:
+  nodes:
+    vnc:
+      machine-list: /etc/machinelist.old
+`)
+	usr := writeConfig(t, "user.yaml", `
+cluster:
+  nodes:
+    machine-list: /home/user/machinelist
+    vnc:
+      machine-list: /home/user/machinelist.old
+`)
+
+	c, err := Load(sys)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	c.getenv = func(k string) string { return "" }
+
+	v, o, ok := c.Lookup("cluster.nodes.machine-list")
+	t.Logf("%s (%s)", v, o)
+	if exp := sys + " (deprecated key cluster.nodes.vnc.machine-list)"; !ok || v != "/etc/machinelist.old" || o != exp {
+		t.Errorf("expect /etc/machinelist.old (%s), got %s (%s)", exp, v, o)
+	}
+
+	// the deprecated key in a layer with higher precedence wins
+	if c, err = Load(sys, usr); err != nil {
+		t.Fatalf("%s", err)
+	}
+	env := map[string]string{"HPCUTIL_CLUSTER_NODES_VNC_MACHINE_LIST": "/tmp/machinelist.old"}
+	c.getenv = func(k string) string { return env[k] }
+
+	v, o, ok = c.Lookup("cluster.nodes.machine-list")
+	t.Logf("%s (%s)", v, o)
+	if exp := "env HPCUTIL_CLUSTER_NODES_VNC_MACHINE_LIST (deprecated key cluster.nodes.vnc.machine-list)"; !ok || v != "/tmp/machinelist.old" || o != exp {
+		t.Errorf("expect /tmp/machinelist.old (%s), got %s (%s)", exp, v, o)
+	}
+
+	// the current key takes precedence over the deprecated key in the same layer
+	delete(env, "HPCUTIL_CLUSTER_NODES_VNC_MACHINE_LIST")
+	if v, o, ok = c.Lookup("cluster.nodes.machine-list"); !ok || v != "/home/user/machinelist" || o != usr {
+		t.Errorf("expect /home/user/machinelist (%s), got %s (%s)", usr, v, o)
+	}
+}
@@ -0,0 +1,197 @@
+// Package history implements the recording of node-status snapshots and the queries of the
+// utilisation trends and the node downtime over the recorded snapshots.
+//
+// The snapshots are stored in a directory, one JSON file per snapshot named after the UTC
+// time of the snapshot, e.g.
+//
+// ```
+// /data/hpcutil/nodes/20241120T130000Z.json
+// /data/hpcutil/nodes/20241120T131000Z.json
+// ```
+//
+// The files can be removed or archived with the usual tools to limit the retention.
+//
+// Alternatively, the snapshots are stored in a SQLite database, one row per snapshot in the
+// table `snapshots`, e.g. `/data/hpcutil/nodes.db`.
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Donders-Institute/hpc-utility/internal/node"
+)
+
+// fileTimeLayout is the time layout of the snapshot file names.
+const fileTimeLayout = "20060102T150405Z"
+
+// Snapshot is the resource status of the nodes at a point of time.
+type Snapshot struct {
+	Time  time.Time   `json:"time"`
+	Nodes []node.Node `json:"nodes"`
+}
+
+// Store is a store of snapshots.
+type Store interface {
+	// Save writes the snapshot `snap` into the store.  A snapshot taken at the same second as
+	// an existing one replaces it.
+	Save(snap Snapshot) error
+	// Load reads the snapshots taken in the time window between `since` and `until` from the
+	// store.  A zero `since` or `until` leaves the window open at that side.  The snapshots
+	// are ordered by time.
+	Load(since, until time.Time) ([]Snapshot, error)
+	// Close releases the resources of the store.
+	Close() error
+}
+
+// Open returns the snapshot store at `target`, either the path of a directory or a SQLite
+// database given as a `sqlite:` target (e.g. `sqlite:/data/hpcutil/nodes`) or a file with the
+// extension `.db`, `.sqlite` or `.sqlite3`.  The directory or the database is created if it
+// does not exist.
+func Open(target string) (Store, error) {
+
+	if target == "" {
+		return nil, fmt.Errorf("empty snapshot store")
+	}
+
+	if strings.HasPrefix(target, "sqlite:") {
+		return openSQLite(strings.TrimPrefix(target, "sqlite:"))
+	}
+	switch strings.ToLower(filepath.Ext(target)) {
+	case ".db", ".sqlite", ".sqlite3":
+		return openSQLite(target)
+	}
+
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create snapshot store %s: %s", target, err)
+	}
+
+	return &DirStore{Dir: target}, nil
+}
+
+// DirStore is a directory of snapshots, one JSON file per snapshot.
+type DirStore struct {
+	Dir string
+}
+
+// Save writes the snapshot `s` into the store.  A snapshot taken at the same second as an
+// existing one replaces it.
+func (s *DirStore) Save(snap Snapshot) error {
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Join(s.Dir, snap.Time.UTC().Format(fileTimeLayout)+".json")
+
+	// write to a temporary file first, so that a reader never sees a partial snapshot.
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// Load reads the snapshots taken in the time window between `since` and `until` from the
+// store.  A zero `since` or `until` leaves the window open at that side.  The snapshots are
+// ordered by time.
+func (s *DirStore) Load(since, until time.Time) ([]Snapshot, error) {
+
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	snaps := []Snapshot{}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+
+		// select the snapshots by the time in the file name before reading the files
+		t, err := time.Parse(fileTimeLayout, strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		if (!since.IsZero() && t.Before(since.Truncate(time.Second))) || (!until.IsZero() && t.After(until)) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.Dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var snap Snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return nil, fmt.Errorf("invalid snapshot %s: %s", e.Name(), err)
+		}
+		snaps = append(snaps, snap)
+	}
+
+	sort.Slice(snaps, func(i, j int) bool {
+		return snaps[i].Time.Before(snaps[j].Time)
+	})
+
+	return snaps, nil
+}
+
+// Close does nothing for the directory store.
+func (s *DirStore) Close() error {
+	return nil
+}
+
+// timeUnits are the units of the relative time in `ParseTime`, in the same way as the
+// `--starttime` option of `sacct`.
+var timeUnits = map[string]time.Duration{
+	"seconds": time.Second,
+	"minutes": time.Minute,
+	"hours":   time.Hour,
+	"days":    24 * time.Hour,
+	"weeks":   7 * 24 * time.Hour,
+}
+
+// ParseTime parses the time `s` given as a date (e.g. `2024-11-01`), a date and time (e.g.
+// `2024-11-01T12:00:00`) in the local time zone, or a time relative to `now` (e.g. `now`,
+// `now-14days` or `now-2hours`).  An empty `s` results in the zero time.
+func ParseTime(s string, now time.Time) (time.Time, error) {
+
+	switch {
+	case s == "":
+		return time.Time{}, nil
+	case s == "now":
+		return now, nil
+	case strings.HasPrefix(s, "now-"):
+		rel := strings.TrimPrefix(s, "now-")
+		i := strings.IndexFunc(rel, func(r rune) bool { return r < '0' || r > '9' })
+		if i <= 0 {
+			return time.Time{}, fmt.Errorf("invalid relative time: %s", s)
+		}
+		n, err := strconv.Atoi(rel[:i])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid relative time: %s", s)
+		}
+		unit, ok := timeUnits[rel[i:]]
+		if !ok {
+			return time.Time{}, fmt.Errorf("invalid unit of relative time: %s", s)
+		}
+		return now.Add(-time.Duration(n) * unit), nil
+	}
+
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time: %s", s)
+}
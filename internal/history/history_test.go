@@ -0,0 +1,134 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Donders-Institute/hpc-utility/internal/node"
+)
+
+func TestStore(t *testing.T) {
+
+	s, err := Open(filepath.Join(t.TempDir(), "nodes"))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer s.Close()
+
+	// a file not being a snapshot is ignored
+	if err := os.WriteFile(filepath.Join(s.(*DirStore).Dir, "README"), []byte("snapshots"), 0644); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	testStore(t, s)
+}
+
+func TestStoreSQLite(t *testing.T) {
+
+	for _, target := range []string{
+		filepath.Join(t.TempDir(), "nodes.db"),
+		"sqlite:" + filepath.Join(t.TempDir(), "nodes"),
+	} {
+		s, err := Open(target)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		if _, ok := s.(*SQLiteStore); !ok {
+			t.Errorf("expect SQLite store of %s, got %T", target, s)
+		}
+
+		testStore(t, s)
+		s.Close()
+	}
+
+	// the snapshots are kept after reopening the database
+	path := filepath.Join(t.TempDir(), "nodes.sqlite")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err := s.Save(Snapshot{Time: time.Date(2024, 11, 20, 13, 0, 0, 0, time.UTC), Nodes: []node.Node{}}); err != nil {
+		t.Fatalf("%s", err)
+	}
+	s.Close()
+
+	if s, err = Open(path); err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer s.Close()
+	if snaps, err := s.Load(time.Time{}, time.Time{}); err != nil || len(snaps) != 1 {
+		t.Errorf("unexpected snapshots after reopening: %+v %v", snaps, err)
+	}
+}
+
+// testStore saves the snapshots into the store `s` and loads them in time windows.
+func testStore(t *testing.T, s Store) {
+
+	t0 := time.Date(2024, 11, 20, 13, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		snap := Snapshot{
+			Time:  t0.Add(time.Duration(i) * time.Hour),
+			Nodes: []node.Node{{ID: "dccn-c083", Cluster: node.ClusterSlurm, TotalProcs: 64, AvailProcs: 64 - i}},
+		}
+		if err := s.Save(snap); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	// the snapshot at the same second is replaced
+	if err := s.Save(Snapshot{Time: t0.Add(2 * time.Hour), Nodes: []node.Node{{ID: "dccn-c083", AvailProcs: 62}}}); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	snaps, err := s.Load(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(snaps) != 3 || !snaps[0].Time.Equal(t0) || snaps[2].Nodes[0].AvailProcs != 62 {
+		t.Errorf("unexpected snapshots: %+v", snaps)
+	}
+
+	snaps, err = s.Load(t0.Add(30*time.Minute), t0.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(snaps) != 1 || snaps[0].Nodes[0].AvailProcs != 63 {
+		t.Errorf("unexpected snapshots in time window: %+v", snaps)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+
+	now := time.Date(2024, 11, 20, 13, 0, 0, 0, time.Local)
+
+	cases := []struct {
+		s string
+		t time.Time
+	}{
+		{"", time.Time{}},
+		{"now", now},
+		{"now-14days", now.Add(-14 * 24 * time.Hour)},
+		{"now-2hours", now.Add(-2 * time.Hour)},
+		{"2024-11-01", time.Date(2024, 11, 1, 0, 0, 0, 0, time.Local)},
+		{"2024-11-01T12:30:00", time.Date(2024, 11, 1, 12, 30, 0, 0, time.Local)},
+	}
+
+	for _, c := range cases {
+		v, err := ParseTime(c.s, now)
+		if err != nil {
+			t.Errorf("%s: %s", c.s, err)
+			continue
+		}
+		if !v.Equal(c.t) {
+			t.Errorf("%s: expect %s, got %s", c.s, c.t, v)
+		}
+	}
+
+	for _, s := range []string{"yesterday", "now-days", "now-3fortnights", "2024-13-01"} {
+		if _, err := ParseTime(s, now); err == nil {
+			t.Errorf("expect error for %s", s)
+		}
+	}
+}
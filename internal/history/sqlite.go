@@ -0,0 +1,100 @@
+package history
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	// the SQLite driver of `database/sql`, in pure Go so that the CLI is built without cgo
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema is the table of the snapshots in the SQLite database.  The snapshot is kept in
+// JSON, in the same way as the file of the directory store, with the UTC time in seconds.
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS snapshots (
+	time INTEGER PRIMARY KEY,
+	data TEXT NOT NULL
+)`
+
+// SQLiteStore is a SQLite database of snapshots.
+type SQLiteStore struct {
+	Path string
+	db   *sql.DB
+}
+
+// openSQLite opens the SQLite database at `path`, and creates the table of the snapshots if
+// it does not exist.
+func openSQLite(path string) (*SQLiteStore, error) {
+
+	if path == "" {
+		return nil, fmt.Errorf("empty snapshot store")
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open snapshot store %s: %s", path, err)
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("cannot create snapshot store %s: %s", path, err)
+	}
+
+	return &SQLiteStore{Path: path, db: db}, nil
+}
+
+// Save writes the snapshot `s` into the store.  A snapshot taken at the same second as an
+// existing one replaces it.
+func (s *SQLiteStore) Save(snap Snapshot) error {
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO snapshots (time, data) VALUES (?, ?)`, snap.Time.Unix(), string(data))
+	return err
+}
+
+// Load reads the snapshots taken in the time window between `since` and `until` from the
+// store.  A zero `since` or `until` leaves the window open at that side.  The snapshots are
+// ordered by time.
+func (s *SQLiteStore) Load(since, until time.Time) ([]Snapshot, error) {
+
+	var from, to int64 = math.MinInt64, math.MaxInt64
+	if !since.IsZero() {
+		from = since.Truncate(time.Second).Unix()
+	}
+	if !until.IsZero() {
+		to = until.Unix()
+	}
+
+	rows, err := s.db.Query(`SELECT time, data FROM snapshots WHERE time >= ? AND time <= ? ORDER BY time`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snaps := []Snapshot{}
+	for rows.Next() {
+		var t int64
+		var data string
+		if err := rows.Scan(&t, &data); err != nil {
+			return nil, err
+		}
+		var snap Snapshot
+		if err := json.Unmarshal([]byte(data), &snap); err != nil {
+			return nil, fmt.Errorf("invalid snapshot at %s: %s", time.Unix(t, 0).UTC().Format(time.RFC3339), err)
+		}
+		snaps = append(snaps, snap)
+	}
+
+	return snaps, rows.Err()
+}
+
+// Close closes the database.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
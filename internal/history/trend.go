@@ -0,0 +1,193 @@
+package history
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Donders-Institute/hpc-utility/internal/node"
+)
+
+// GroupNode is the trend group of the individual nodes, in addition to the
+// `node.SummaryTotal`, `node.SummaryCluster` and `node.SummaryPartition` groups.
+const GroupNode = "node"
+
+// Usage is the average resource allocation of a group of nodes in a time window.
+type Usage struct {
+	// Time is the start of the time window.
+	Time time.Time `json:"time"`
+	// Group is the grouping of the nodes, e.g. `node.SummaryPartition`.
+	Group string `json:"group"`
+	// Name is the name of the group member, e.g. `gpu` for the `node.SummaryPartition`
+	// group.
+	Name string `json:"name"`
+	// Samples is the number of snapshots in the time window.
+	Samples    int     `json:"samples"`
+	AllocProcs float64 `json:"alloc_procs"`
+	TotalProcs float64 `json:"total_procs"`
+	AllocGPUS  float64 `json:"alloc_gpus"`
+	TotalGPUS  float64 `json:"total_gpus"`
+	AllocMemGB float64 `json:"alloc_mem_gb"`
+	TotalMemGB float64 `json:"total_mem_gb"`
+}
+
+// add adds the capacity `c` of a snapshot to the sums of the usage.
+func (u *Usage) add(c node.Capacity) {
+	u.Samples++
+	u.AllocProcs += float64(c.AllocProcs)
+	u.TotalProcs += float64(c.TotalProcs)
+	u.AllocGPUS += float64(c.AllocGPUS)
+	u.TotalGPUS += float64(c.TotalGPUS)
+	u.AllocMemGB += float64(c.AllocMemGB)
+	u.TotalMemGB += float64(c.TotalMemGB)
+}
+
+// average turns the sums of the usage into the averages over the samples.
+func (u *Usage) average() {
+	n := float64(u.Samples)
+	u.AllocProcs /= n
+	u.TotalProcs /= n
+	u.AllocGPUS /= n
+	u.TotalGPUS /= n
+	u.AllocMemGB /= n
+	u.TotalMemGB /= n
+}
+
+// Trend computes the average resource allocation of the nodes in the `snaps` per time window
+// of the length `step`, for the nodes in total (`node.SummaryTotal`), per cluster
+// (`node.SummaryCluster`), per Slurm partition (`node.SummaryPartition`) or per node
+// (`GroupNode`) as given by `group`.  The time windows are aligned to `step` in UTC.  The
+// usages are ordered by time, and then by the name.
+func Trend(snaps []Snapshot, group string, step time.Duration) []Usage {
+
+	type key struct {
+		t    time.Time
+		name string
+	}
+
+	sums := make(map[key]*Usage)
+
+	for _, snap := range snaps {
+		t := snap.Time.UTC().Truncate(step)
+
+		caps := []node.Capacity{}
+		if group == GroupNode {
+			for _, n := range snap.Nodes {
+				c := node.Summarize([]node.Node{n})[0]
+				c.Group, c.Name = GroupNode, n.ID
+				caps = append(caps, c)
+			}
+		} else {
+			for _, c := range node.Summarize(snap.Nodes) {
+				if c.Group == group {
+					caps = append(caps, c)
+				}
+			}
+		}
+
+		for _, c := range caps {
+			k := key{t: t, name: c.Name}
+			u, ok := sums[k]
+			if !ok {
+				u = &Usage{Time: t, Group: group, Name: c.Name}
+				sums[k] = u
+			}
+			u.add(c)
+		}
+	}
+
+	usages := make([]Usage, 0, len(sums))
+	for _, u := range sums {
+		u.average()
+		usages = append(usages, *u)
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		if !usages[i].Time.Equal(usages[j].Time) {
+			return usages[i].Time.Before(usages[j].Time)
+		}
+		return usages[i].Name < usages[j].Name
+	})
+
+	return usages
+}
+
+// Downtime is the time a node spent in the DOWN or DRAIN state.
+type Downtime struct {
+	ID      string `json:"id"`
+	Cluster string `json:"cluster"`
+	// ObservedHours is the time the node is observed by the snapshots.
+	ObservedHours float64 `json:"observed_hours"`
+	// DownHours is the time the node is down, e.g. in the `DOWN` state.
+	DownHours float64 `json:"down_hours"`
+	// DrainHours is the time the node is drained but not down, e.g. in the `IDLE+DRAIN`
+	// state.
+	DrainHours float64 `json:"drain_hours"`
+}
+
+// downStates are the node states in which the node is down.
+var downStates = []string{"DOWN", "FAIL", "FAILING", "NOT_RESPONDING"}
+
+// drainStates are the node states and state flags with which the node is drained.
+var drainStates = []string{"DRAIN", "DRAINED", "DRAINING"}
+
+// Downtimes computes the time each node in the `snaps` spent in the DOWN or DRAIN state.  A
+// snapshot accounts for the time until the next snapshot, but not more than `maxGap`; a longer
+// time between snapshots is a gap in the recording.  The downtimes are ordered by the
+// cluster, and then by the node ID.
+func Downtimes(snaps []Snapshot, maxGap time.Duration) []Downtime {
+
+	downtimes := make(map[string]*Downtime)
+
+	for i := 0; i+1 < len(snaps); i++ {
+		d := snaps[i+1].Time.Sub(snaps[i].Time)
+		if d > maxGap {
+			d = maxGap
+		}
+		hours := d.Hours()
+
+		for _, n := range snaps[i].Nodes {
+			k := n.Cluster + ":" + n.ID
+			dt, ok := downtimes[k]
+			if !ok {
+				dt = &Downtime{ID: n.ID, Cluster: n.Cluster}
+				downtimes[k] = dt
+			}
+
+			dt.ObservedHours += hours
+			switch {
+			case inStates(n, downStates):
+				dt.DownHours += hours
+			case inStates(n, drainStates):
+				dt.DrainHours += hours
+			}
+		}
+	}
+
+	list := make([]Downtime, 0, len(downtimes))
+	for _, dt := range downtimes {
+		list = append(list, *dt)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Cluster != list[j].Cluster {
+			return list[i].Cluster < list[j].Cluster
+		}
+		return list[i].ID < list[j].ID
+	})
+
+	return list
+}
+
+// inStates checks whether the state or one of the state flags of the node `n` is one of the
+// `states`.
+func inStates(n node.Node, states []string) bool {
+	for _, s := range append([]string{n.State}, n.StateFlags...) {
+		for _, e := range states {
+			if strings.EqualFold(s, e) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Donders-Institute/hpc-utility/internal/node"
+)
+
+var (
+	trendStart = time.Date(2024, 11, 20, 13, 0, 0, 0, time.UTC)
+
+	// snapshots every 30 minutes; dccn-c075 is drained at the second and down at the third.
+	trendSnaps = []Snapshot{
+		{Time: trendStart, Nodes: []node.Node{
+			{ID: "dccn-c075", Cluster: node.ClusterSlurm, State: "IDLE", Partitions: []string{"batch"}, TotalProcs: 32, AvailProcs: 32, TotalMemGB: 256, AvailMemGB: 256},
+			{ID: "dccn-c083", Cluster: node.ClusterSlurm, State: "MIXED", Partitions: []string{"batch", "gpu"}, TotalProcs: 64, AvailProcs: 32, TotalGPUS: 4, AvailGPUS: 2, TotalMemGB: 512, AvailMemGB: 256},
+		}},
+		{Time: trendStart.Add(30 * time.Minute), Nodes: []node.Node{
+			{ID: "dccn-c075", Cluster: node.ClusterSlurm, State: "IDLE", StateFlags: []string{"DRAIN"}, Partitions: []string{"batch"}, TotalProcs: 32, AvailProcs: 32, TotalMemGB: 256, AvailMemGB: 256},
+			{ID: "dccn-c083", Cluster: node.ClusterSlurm, State: "MIXED", Partitions: []string{"batch", "gpu"}, TotalProcs: 64, AvailProcs: 0, TotalGPUS: 4, AvailGPUS: 0, TotalMemGB: 512, AvailMemGB: 0},
+		}},
+		{Time: trendStart.Add(60 * time.Minute), Nodes: []node.Node{
+			{ID: "dccn-c075", Cluster: node.ClusterSlurm, State: "DOWN", StateFlags: []string{"DRAIN"}, Partitions: []string{"batch"}, TotalProcs: 32, AvailProcs: 32, TotalMemGB: 256, AvailMemGB: 256},
+			{ID: "dccn-c083", Cluster: node.ClusterSlurm, State: "IDLE", Partitions: []string{"batch", "gpu"}, TotalProcs: 64, AvailProcs: 64, TotalGPUS: 4, AvailGPUS: 4, TotalMemGB: 512, AvailMemGB: 512},
+		}},
+		// a gap in the recording of 3 hours
+		{Time: trendStart.Add(4 * time.Hour), Nodes: []node.Node{
+			{ID: "dccn-c075", Cluster: node.ClusterSlurm, State: "IDLE", Partitions: []string{"batch"}, TotalProcs: 32, AvailProcs: 32, TotalMemGB: 256, AvailMemGB: 256},
+		}},
+	}
+)
+
+func TestTrend(t *testing.T) {
+
+	usages := Trend(trendSnaps, node.SummaryTotal, time.Hour)
+	for _, u := range usages {
+		t.Logf("%+v", u)
+	}
+
+	if len(usages) != 3 {
+		t.Fatalf("expect 3 usages, got %d", len(usages))
+	}
+	if u := usages[0]; !u.Time.Equal(trendStart) || u.Samples != 2 || u.AllocProcs != 48 || u.TotalProcs != 96 || u.AllocGPUS != 3 || u.AllocMemGB != 384 {
+		t.Errorf("unexpected usage: %+v", u)
+	}
+
+	usages = Trend(trendSnaps, node.SummaryPartition, time.Hour)
+	if len(usages) != 5 || usages[1].Name != "gpu" || usages[1].AllocProcs != 48 || usages[1].TotalGPUS != 4 {
+		t.Errorf("unexpected usages per partition: %+v", usages)
+	}
+
+	usages = Trend(trendSnaps, GroupNode, 30*time.Minute)
+	if len(usages) != 7 || usages[1].Name != "dccn-c083" || usages[1].AllocProcs != 32 || usages[3].AllocProcs != 64 {
+		t.Errorf("unexpected usages per node: %+v", usages)
+	}
+}
+
+func TestDowntimes(t *testing.T) {
+
+	dts := Downtimes(trendSnaps, time.Hour)
+	for _, dt := range dts {
+		t.Logf("%+v", dt)
+	}
+
+	if len(dts) != 2 {
+		t.Fatalf("expect 2 downtimes, got %d", len(dts))
+	}
+	if dt := dts[0]; dt.ID != "dccn-c075" || dt.ObservedHours != 2 || dt.DrainHours != 0.5 || dt.DownHours != 1 {
+		t.Errorf("unexpected downtime: %+v", dt)
+	}
+	if dt := dts[1]; dt.ID != "dccn-c083" || dt.ObservedHours != 2 || dt.DrainHours != 0 || dt.DownHours != 0 {
+		t.Errorf("unexpected downtime: %+v", dt)
+	}
+}
@@ -0,0 +1,262 @@
+// Package hostlist implements the Slurm-style hostlist expressions, in which a range of
+// hostnames is given by the numeric ranges in brackets.  For example, the expression
+//
+// ```
+// dccn-c[080-082,101],mentat00[1-2]
+// ```
+//
+// refers to the hosts `dccn-c080`, `dccn-c081`, `dccn-c082`, `dccn-c101`, `mentat001` and
+// `mentat002`.  The package also provides functions to convert hostnames between the short
+// name and the fully qualified domain name (FQDN).
+package hostlist
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxHosts is the maximum number of hosts an expression is expanded to.
+const maxHosts = 65536
+
+// Expand expands the hostlist expressions `exprs` into hostnames.  Hostnames are returned in
+// the order given by the expressions; duplicates are removed.
+func Expand(exprs ...string) ([]string, error) {
+
+	hosts := []string{}
+	seen := make(map[string]bool)
+
+	for _, expr := range exprs {
+		items, err := split(expr)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			hs, err := expand(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid hostlist %s: %s", expr, err)
+			}
+			for _, h := range hs {
+				if !seen[h] {
+					seen[h] = true
+					hosts = append(hosts, h)
+				}
+			}
+			if len(hosts) > maxHosts {
+				return nil, fmt.Errorf("invalid hostlist %s: more than %d hosts", expr, maxHosts)
+			}
+		}
+	}
+
+	return hosts, nil
+}
+
+// split splits the expression `expr` by the commas outside brackets.
+func split(expr string) ([]string, error) {
+
+	items := []string{}
+
+	depth := 0
+	start := 0
+	for i, c := range expr {
+		switch c {
+		case '[':
+			depth++
+			if depth > 1 {
+				return nil, fmt.Errorf("invalid hostlist %s: nested brackets", expr)
+			}
+		case ']':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("invalid hostlist %s: unbalanced brackets", expr)
+			}
+		case ',':
+			if depth == 0 {
+				items = append(items, expr[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("invalid hostlist %s: unbalanced brackets", expr)
+	}
+	items = append(items, expr[start:])
+
+	// skip empty items, e.g. from a trailing comma
+	nonEmpty := items[:0]
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			nonEmpty = append(nonEmpty, item)
+		}
+	}
+
+	return nonEmpty, nil
+}
+
+// expand expands a single hostname pattern without top-level commas, e.g.
+// `dccn-c[080-082].dccn.nl`.
+func expand(pattern string) ([]string, error) {
+
+	i := strings.Index(pattern, "[")
+	if i < 0 {
+		return []string{pattern}, nil
+	}
+	j := strings.Index(pattern, "]")
+
+	prefix, ranges, rest := pattern[:i], pattern[i+1:j], pattern[j+1:]
+
+	nums, err := expandRanges(ranges)
+	if err != nil {
+		return nil, err
+	}
+
+	tails, err := expand(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nums)*len(tails) > maxHosts {
+		return nil, fmt.Errorf("more than %d hosts", maxHosts)
+	}
+
+	hosts := make([]string, 0, len(nums)*len(tails))
+	for _, n := range nums {
+		for _, t := range tails {
+			hosts = append(hosts, prefix+n+t)
+		}
+	}
+
+	return hosts, nil
+}
+
+// expandRanges expands the comma-separated numeric ranges, e.g. `080-082,101`.  The numbers
+// are zero-padded to the width of the lower bound of the range.
+func expandRanges(ranges string) ([]string, error) {
+
+	nums := []string{}
+	for _, r := range strings.Split(ranges, ",") {
+		bounds := strings.SplitN(r, "-", 2)
+
+		lo, err := strconv.Atoi(bounds[0])
+		if err != nil || lo < 0 {
+			return nil, fmt.Errorf("invalid range: %s", r)
+		}
+
+		hi := lo
+		if len(bounds) == 2 {
+			if hi, err = strconv.Atoi(bounds[1]); err != nil || hi < lo {
+				return nil, fmt.Errorf("invalid range: %s", r)
+			}
+		}
+
+		if hi-lo >= maxHosts {
+			return nil, fmt.Errorf("more than %d hosts", maxHosts)
+		}
+
+		width := len(bounds[0])
+		for n := lo; n <= hi; n++ {
+			nums = append(nums, fmt.Sprintf("%0*d", width, n))
+		}
+	}
+
+	return nums, nil
+}
+
+// group is a set of hostnames sharing the same prefix, suffix and width of the number.
+type group struct {
+	prefix string
+	suffix string
+	width  int
+	nums   []int
+}
+
+// Compress compresses the `hosts` into a hostlist expression, e.g. `dccn-c[080-082,101]`.
+// Hostnames with the same prefix and suffix around the last number are combined.  The
+// groups of the hostnames are sorted in the expression.
+func Compress(hosts []string) string {
+
+	groups := make(map[string]*group)
+	var singles []string
+	seen := make(map[string]bool)
+
+	for _, h := range hosts {
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+
+		// the last number in the hostname
+		j := strings.LastIndexAny(h, "0123456789")
+		if j < 0 {
+			singles = append(singles, h)
+			continue
+		}
+		i := j
+		for i > 0 && h[i-1] >= '0' && h[i-1] <= '9' {
+			i--
+		}
+
+		n, err := strconv.Atoi(h[i : j+1])
+		if err != nil {
+			singles = append(singles, h)
+			continue
+		}
+
+		key := fmt.Sprintf("%s\x00%s\x00%d", h[:i], h[j+1:], j+1-i)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{prefix: h[:i], suffix: h[j+1:], width: j + 1 - i}
+			groups[key] = g
+		}
+		g.nums = append(g.nums, n)
+	}
+
+	exprs := singles
+	for _, g := range groups {
+		exprs = append(exprs, g.String())
+	}
+	sort.Strings(exprs)
+
+	return strings.Join(exprs, ",")
+}
+
+// String returns the hostlist expression of the group.
+func (g *group) String() string {
+
+	sort.Ints(g.nums)
+
+	if len(g.nums) == 1 {
+		return fmt.Sprintf("%s%0*d%s", g.prefix, g.width, g.nums[0], g.suffix)
+	}
+
+	var ranges []string
+	for i := 0; i < len(g.nums); {
+		j := i
+		for j+1 < len(g.nums) && g.nums[j+1] == g.nums[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, fmt.Sprintf("%0*d", g.width, g.nums[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%0*d-%0*d", g.width, g.nums[i], g.width, g.nums[j]))
+		}
+		i = j + 1
+	}
+
+	return fmt.Sprintf("%s[%s]%s", g.prefix, strings.Join(ranges, ","), g.suffix)
+}
+
+// FQDN returns the fully qualified domain name of the `host`, i.e. the short hostname is
+// given the `domain`.  A hostname with a dot is returned as it is.
+func FQDN(host, domain string) string {
+	if domain == "" || strings.Contains(host, ".") {
+		return host
+	}
+	return fmt.Sprintf("%s.%s", host, domain)
+}
+
+// Short returns the short hostname of the `host`, i.e. the first label of the domain name.
+func Short(host string) string {
+	return strings.Split(host, ".")[0]
+}
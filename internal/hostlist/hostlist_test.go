@@ -0,0 +1,92 @@
+package hostlist
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+
+	cases := []struct {
+		exprs []string
+		hosts string
+	}{
+		{[]string{"dccn-c005"}, "dccn-c005"},
+		{[]string{"dccn-c[080-082,101]"}, "dccn-c080,dccn-c081,dccn-c082,dccn-c101"},
+		{[]string{"mentat00[1-3],mentat005"}, "mentat001,mentat002,mentat003,mentat005"},
+		{[]string{"node[9-11]"}, "node9,node10,node11"},
+		{[]string{"dccn-c[005-006].dccn.nl"}, "dccn-c005.dccn.nl,dccn-c006.dccn.nl"},
+		{[]string{"r[1-2]n[01-02]"}, "r1n01,r1n02,r2n01,r2n02"},
+		{[]string{"dccn-c[080-081]", "dccn-c081,dccn-c082,"}, "dccn-c080,dccn-c081,dccn-c082"},
+	}
+
+	for _, c := range cases {
+		hosts, err := Expand(c.exprs...)
+		if err != nil {
+			t.Errorf("%+v: %s", c.exprs, err)
+			continue
+		}
+		t.Logf("%+v: %+v", c.exprs, hosts)
+		if strings.Join(hosts, ",") != c.hosts {
+			t.Errorf("%+v: expect %s, got %+v", c.exprs, c.hosts, hosts)
+		}
+	}
+
+	for _, expr := range []string{"dccn-c[080-082", "dccn-c080]", "dccn-c[a-b]", "dccn-c[082-080]", "dccn-c[[1-2]]", "dccn-c[]", "n[0-99999999]"} {
+		if _, err := Expand(expr); err == nil {
+			t.Errorf("expect error for %s", expr)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+
+	cases := []struct {
+		hosts []string
+		expr  string
+	}{
+		{[]string{}, ""},
+		{[]string{"dccn-c005"}, "dccn-c005"},
+		{[]string{"dccn-c082", "dccn-c080", "dccn-c081", "dccn-c101", "dccn-c080"}, "dccn-c[080-082,101]"},
+		{[]string{"dccn-c005.dccn.nl", "dccn-c006.dccn.nl", "mentat001.dccn.nl"}, "dccn-c[005-006].dccn.nl,mentat001.dccn.nl"},
+		{[]string{"node9", "node10", "login"}, "login,node10,node9"},
+	}
+
+	for _, c := range cases {
+		expr := Compress(c.hosts)
+		t.Logf("%+v: %s", c.hosts, expr)
+		if expr != c.expr {
+			t.Errorf("%+v: expect %s, got %s", c.hosts, c.expr, expr)
+		}
+
+		// the expanded expression compresses to the same expression
+		hosts, err := Expand(expr)
+		if err != nil {
+			t.Errorf("%s: %s", expr, err)
+		}
+		if Compress(hosts) != expr {
+			t.Errorf("%s: unexpected expansion %+v", expr, hosts)
+		}
+	}
+}
+
+func TestFQDN(t *testing.T) {
+
+	cases := []struct {
+		host string
+		fqdn string
+	}{
+		{"dccn-c005", "dccn-c005.dccn.nl"},
+		{"dccn-c005.dccn.nl", "dccn-c005.dccn.nl"},
+		{"mentat001.other.org", "mentat001.other.org"},
+	}
+
+	for _, c := range cases {
+		if fqdn := FQDN(c.host, "dccn.nl"); fqdn != c.fqdn {
+			t.Errorf("%s: expect %s, got %s", c.host, c.fqdn, fqdn)
+		}
+		if short := Short(c.fqdn); short != strings.Split(c.host, ".")[0] {
+			t.Errorf("%s: unexpected short name %s", c.fqdn, short)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Package machinelist implements the parser of the machine list file, in which the hosts of
+// the cluster are listed with their groups and attributes.  For example,
+//
+// ```
+// # access nodes of the Torque cluster
+// [access]
+// mentat00[1-5]  role=access cluster=torque
+//
+// # interactive GPU nodes of the Slurm cluster
+// [gpu]
+// dccn-c[083-084] role=interactive cluster=slurm  # A100 nodes
+// ```
+//
+// Each line gives a host, or a range of hosts by a hostlist expression, followed by optional
+// attributes in the form of `key=value`.  A line `[name]` starts a group; the hosts that follow
+// belong to the group until the next group.  A host may be listed in multiple groups, in which
+// case its attributes are merged.  Everything after `#` is a comment.
+//
+// A plain list of hostnames, one per line, is also a valid machine list.  Words following the
+// hostname that are not attributes are ignored.
+package machinelist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Donders-Institute/hpc-utility/internal/hostlist"
+)
+
+// Host is a host in the machine list.
+type Host struct {
+	Name string
+	// Groups are the groups the host belongs to, in the order of the machine list.
+	Groups []string
+	// Attrs are the attributes of the host, e.g. `role` or `cluster`.
+	Attrs map[string]string
+}
+
+// MachineList is the list of hosts in the order of the machine list file.
+type MachineList struct {
+	Hosts []Host
+}
+
+// Load reads the machine list file at `path`.
+func Load(path string) (*MachineList, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ml, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return ml, nil
+}
+
+// Parse parses the machine list from `r`.
+func Parse(r io.Reader) (*MachineList, error) {
+
+	ml := &MachineList{Hosts: []Host{}}
+
+	// index of the hosts in `ml.Hosts` by the hostname
+	index := make(map[string]int)
+
+	group := ""
+	lineno := 0
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineno++
+
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+
+		if line == "" {
+			continue
+		}
+
+		// the group header, e.g. `[access]`
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid group: %s", lineno, line)
+			}
+			group = strings.TrimSpace(line[1 : len(line)-1])
+			if group == "" || strings.ContainsAny(group, " \t=") {
+				return nil, fmt.Errorf("line %d: invalid group name: %s", lineno, line)
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+
+		names, err := hostlist.Expand(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineno, err)
+		}
+
+		attrs := make(map[string]string)
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				continue
+			}
+			attrs[kv[0]] = kv[1]
+		}
+
+		for _, name := range names {
+			i, ok := index[name]
+			if !ok {
+				i = len(ml.Hosts)
+				index[name] = i
+				ml.Hosts = append(ml.Hosts, Host{Name: name, Groups: []string{}, Attrs: make(map[string]string)})
+			}
+
+			h := &ml.Hosts[i]
+			if group != "" && !contains(h.Groups, group) {
+				h.Groups = append(h.Groups, group)
+			}
+			for k, v := range attrs {
+				h.Attrs[k] = v
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return ml, nil
+}
+
+// Names returns the names of all hosts.
+func (ml *MachineList) Names() []string {
+	names := make([]string, 0, len(ml.Hosts))
+	for _, h := range ml.Hosts {
+		names = append(names, h.Name)
+	}
+	return names
+}
+
+// Groups returns the names of the groups in alphabetical order.
+func (ml *MachineList) Groups() []string {
+
+	groups := []string{}
+	for _, h := range ml.Hosts {
+		for _, g := range h.Groups {
+			if !contains(groups, g) {
+				groups = append(groups, g)
+			}
+		}
+	}
+	sort.Strings(groups)
+
+	return groups
+}
+
+// Select returns the hosts selected by the `selector`, which is either the name of a group,
+// e.g. `access`, or an attribute in the form of `key=value`, e.g. `cluster=slurm`.
+func (ml *MachineList) Select(selector string) []Host {
+
+	hosts := []Host{}
+
+	kv := strings.SplitN(selector, "=", 2)
+	for _, h := range ml.Hosts {
+		if len(kv) == 2 {
+			if v, ok := h.Attrs[kv[0]]; ok && v == kv[1] {
+				hosts = append(hosts, h)
+			}
+			continue
+		}
+		if contains(h.Groups, selector) {
+			hosts = append(hosts, h)
+		}
+	}
+
+	return hosts
+}
+
+// contains checks whether `s` is one of the `list`.
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package machinelist
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var (
+	machinelist = `# access nodes of the Torque cluster
+[access]
+mentat00[1-3]  role=access cluster=torque
+mentat004.dccn.nl role=access cluster=torque  # in maintenance
+
+# interactive GPU nodes of the Slurm cluster
+[ gpu ]
+dccn-c[083-084] role=interactive cluster=slurm
+
+[slurm]
+dccn-c083 gpu=a100
+dccn-c075 cluster=slurm
+`
+
+	// the plain machine list in which the hostname is followed by other fields
+	plainlist = `mentat001 mentat001.dccn.nl
+
+mentat002 mentat002.dccn.nl
+`
+)
+
+func TestParse(t *testing.T) {
+
+	ml, err := Parse(strings.NewReader(machinelist))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for _, h := range ml.Hosts {
+		t.Logf("host: %+v", h)
+	}
+
+	if names := strings.Join(ml.Names(), ","); names != "mentat001,mentat002,mentat003,mentat004.dccn.nl,dccn-c083,dccn-c084,dccn-c075" {
+		t.Errorf("unexpected hosts: %s", names)
+	}
+
+	if groups := strings.Join(ml.Groups(), ","); groups != "access,gpu,slurm" {
+		t.Errorf("unexpected groups: %s", groups)
+	}
+
+	// the host in multiple groups with the merged attributes
+	if h := ml.Hosts[4]; h.Name != "dccn-c083" || len(h.Groups) != 2 || h.Attrs["role"] != "interactive" || h.Attrs["gpu"] != "a100" {
+		t.Errorf("unexpected host: %+v", h)
+	}
+
+	if hosts := ml.Select("access"); len(hosts) != 4 || hosts[3].Name != "mentat004.dccn.nl" {
+		t.Errorf("unexpected hosts of group access: %+v", hosts)
+	}
+
+	if hosts := ml.Select("cluster=slurm"); len(hosts) != 3 {
+		t.Errorf("unexpected hosts of attribute cluster=slurm: %+v", hosts)
+	}
+
+	if hosts := ml.Select("unknown"); len(hosts) != 0 {
+		t.Errorf("unexpected hosts of unknown group: %+v", hosts)
+	}
+}
+
+func TestParsePlain(t *testing.T) {
+
+	ml, err := Parse(strings.NewReader(plainlist))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if names := strings.Join(ml.Names(), ","); names != "mentat001,mentat002" {
+		t.Errorf("unexpected hosts: %s", names)
+	}
+	if h := ml.Hosts[0]; len(h.Groups) != 0 || len(h.Attrs) != 0 {
+		t.Errorf("unexpected host: %+v", h)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+
+	for _, in := range []string{
+		"[access\nmentat001",
+		"[]\nmentat001",
+		"mentat00[3-1]",
+	} {
+		if _, err := Parse(strings.NewReader(in)); err == nil {
+			t.Errorf("expect error of %q", in)
+		} else {
+			t.Logf("%q: %s", in, err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "machines")
+	if err := os.WriteFile(path, []byte("[access]\nmentat00 1\n"), 0644); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	ml, err := Load(path)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if hosts := ml.Select("access"); len(hosts) != 1 || hosts[0].Name != "mentat00" {
+		t.Errorf("unexpected hosts: %+v", hosts)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("expect error of missing file")
+	}
+}
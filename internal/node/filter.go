@@ -0,0 +1,102 @@
+package node
+
+import (
+	"strings"
+)
+
+// Filter defines the criteria for selecting nodes.  A criterion with the zero value is not
+// applied.  String values are compared case-insensitively.
+type Filter struct {
+	// Clusters are the accepted clusters, e.g. `ClusterSlurm`.
+	Clusters []string
+	// States are the accepted node states or state flags, e.g. `idle`, `mixed` or `drain`.  A
+	// node with a state flag making it unavailable, e.g. `IDLE+DRAIN`, only matches the state
+	// flag but not the base state.
+	States []string
+	// Partitions are the Slurm partitions of which the node should be a member of at least
+	// one.
+	Partitions []string
+	// MinFreeProcs is the minimum number of available CPU cores.
+	MinFreeProcs int
+	// MinFreeMemBytes is the minimum size of the available memory in bytes.
+	MinFreeMemBytes int64
+	// GPUModel is a substring of the GPU model, e.g. `a100`.
+	GPUModel string
+	// CPUVendor is the CPU vendor, e.g. `amd`.
+	CPUVendor string
+}
+
+// Match checks whether the node `n` fulfils all criteria of the filter.
+func (f Filter) Match(n Node) bool {
+
+	if len(f.Clusters) > 0 && !containsFold(f.Clusters, n.Cluster) {
+		return false
+	}
+
+	if len(f.States) > 0 {
+		// the base state, e.g. `idle`, of an unavailable node only matches if the state itself
+		// is unavailable, e.g. `down`
+		found := containsFold(f.States, n.State) && (containsFold(unavailableStates, n.State) || !n.Unavailable())
+		for _, s := range n.StateFlags {
+			if containsFold(f.States, s) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(f.Partitions) > 0 {
+		found := false
+		for _, p := range n.Partitions {
+			if containsFold(f.Partitions, p) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if n.AvailProcs < f.MinFreeProcs {
+		return false
+	}
+
+	if int64(n.AvailMemGB)<<30 < f.MinFreeMemBytes {
+		return false
+	}
+
+	if f.GPUModel != "" && !n.hasGPUModel(f.GPUModel) {
+		return false
+	}
+
+	if f.CPUVendor != "" && !strings.EqualFold(n.CPUVendor, f.CPUVendor) {
+		return false
+	}
+
+	return true
+}
+
+// Select returns the nodes matching the filter.
+func (f Filter) Select(nodes []Node) []Node {
+	selected := []Node{}
+	for _, n := range nodes {
+		if f.Match(n) {
+			selected = append(selected, n)
+		}
+	}
+	return selected
+}
+
+// containsFold checks whether `s` is one of the `list` under case-folding.
+func containsFold(list []string, s string) bool {
+	for _, e := range list {
+		if strings.EqualFold(e, s) {
+			return true
+		}
+	}
+	return false
+}
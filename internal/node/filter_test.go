@@ -0,0 +1,58 @@
+package node
+
+import (
+	"testing"
+)
+
+var (
+	filterNodes = []Node{
+		{ID: "dccn-c005", Cluster: ClusterTorque, State: "Busy", CPUVendor: "INTEL", AvailProcs: 0, AvailMemGB: 16},
+		{ID: "dccn-c075", Cluster: ClusterSlurm, State: "IDLE", StateFlags: []string{"DRAIN"}, CPUVendor: "INTEL", Partitions: []string{"batch"}, AvailProcs: 32, AvailMemGB: 256},
+		{ID: "dccn-c083", Cluster: ClusterSlurm, State: "MIXED", CPUVendor: "AMD", Partitions: []string{"batch", "gpu"}, AvailProcs: 8, AvailMemGB: 64, GPUModel: "nvidia_a100-sxm4-40gb"},
+		{ID: "dccn-c084", Cluster: ClusterSlurm, State: "IDLE", StateFlags: []string{"RESERVED"}, CPUVendor: "AMD", Partitions: []string{"gpu"}, AvailProcs: 4, AvailMemGB: 32},
+	}
+)
+
+func TestFilter(t *testing.T) {
+
+	cases := []struct {
+		filter Filter
+		ids    []string
+	}{
+		{Filter{}, []string{"dccn-c005", "dccn-c075", "dccn-c083", "dccn-c084"}},
+		{Filter{Clusters: []string{"slurm"}}, []string{"dccn-c075", "dccn-c083", "dccn-c084"}},
+		// the IDLE+DRAIN and IDLE+RESERVED nodes cannot take jobs
+		{Filter{States: []string{"idle", "mixed"}}, []string{"dccn-c083"}},
+		{Filter{States: []string{"drain"}}, []string{"dccn-c075"}},
+		{Filter{States: []string{"idle", "drain"}}, []string{"dccn-c075"}},
+		{Filter{States: []string{"reserved"}}, []string{"dccn-c084"}},
+		{Filter{Partitions: []string{"gpu"}}, []string{"dccn-c083", "dccn-c084"}},
+		{Filter{MinFreeProcs: 8}, []string{"dccn-c075", "dccn-c083"}},
+		{Filter{MinFreeMemBytes: 100 << 30}, []string{"dccn-c075"}},
+		{Filter{GPUModel: "A100"}, []string{"dccn-c083"}},
+		{Filter{CPUVendor: "intel"}, []string{"dccn-c005", "dccn-c075"}},
+		{Filter{CPUVendor: "intel", MinFreeProcs: 1}, []string{"dccn-c075"}},
+		{Filter{Partitions: []string{"gpu"}, MinFreeProcs: 16}, []string{}},
+	}
+
+	for _, c := range cases {
+		nodes := c.filter.Select(filterNodes)
+
+		ids := []string{}
+		for _, n := range nodes {
+			ids = append(ids, n.ID)
+		}
+		t.Logf("%+v: %+v", c.filter, ids)
+
+		if len(ids) != len(c.ids) {
+			t.Errorf("filter %+v: expect %+v, got %+v", c.filter, c.ids, ids)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != c.ids[i] {
+				t.Errorf("filter %+v: expect %+v, got %+v", c.filter, c.ids, ids)
+				break
+			}
+		}
+	}
+}
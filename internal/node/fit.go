@@ -0,0 +1,117 @@
+package node
+
+import (
+	"fmt"
+)
+
+// Fit is the result of matching the resource requirements of a job against a node.
+type Fit int
+
+const (
+	// FitNever means that the node can never run the job, because the configured resources
+	// or the partitions of the node do not satisfy the requirements.
+	FitNever Fit = iota
+	// FitLater means that the node can run the job once the allocated resources are freed
+	// up or the node is back in service.
+	FitLater
+	// FitNow means that the node can start the job right now.
+	FitNow
+)
+
+// String returns the name of the fit, i.e. `never`, `later` or `now`.
+func (f Fit) String() string {
+	switch f {
+	case FitNow:
+		return "now"
+	case FitLater:
+		return "later"
+	default:
+		return "never"
+	}
+}
+
+// unavailableStates are the node states and state flags with which no new job can be started
+// on the node.  A node with the `RESERVED` flag only takes jobs of the reservation.
+var unavailableStates = []string{"DOWN", "DRAIN", "DRAINED", "DRAINING", "FAIL", "MAINT", "MAINTENANCE", "RESERVED", "FUTURE", "POWERED_DOWN"}
+
+// Unavailable checks whether the node cannot take new jobs because of the node state or one
+// of the state flags, e.g. `IDLE+DRAIN`.
+func (n Node) Unavailable() bool {
+	for _, s := range append([]string{n.State}, n.StateFlags...) {
+		if containsFold(unavailableStates, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Request defines the resource requirements of a job on a single node.  A requirement with
+// the zero value is not applied.
+type Request struct {
+	Procs    int
+	MemBytes int64
+	GPUs     int
+	// GPUModel is a substring of the GPU model, e.g. `a100`.
+	GPUModel string
+	// TmpBytes is the size of the local disk space.
+	TmpBytes  int64
+	Partition string
+}
+
+// Fit checks whether the node can run a job with the resource requirements `r`.  It also
+// returns the reasons why the job cannot be started on the node right now.
+func (n Node) Fit(r Request) (Fit, []string) {
+
+	var never, later []string
+
+	// partition and GPU model
+	if r.Partition != "" && !containsFold(n.Partitions, r.Partition) {
+		never = append(never, fmt.Sprintf("not in partition %s", r.Partition))
+	}
+	if r.GPUModel != "" && !n.hasGPUModel(r.GPUModel) {
+		never = append(never, fmt.Sprintf("no %s gpu", r.GPUModel))
+	}
+
+	// resources: the configured total decides whether the job can ever fit, the available
+	// amount decides whether it fits now.
+	check := func(name string, req, total, avail int64, format func(int64) string) {
+		switch {
+		case req <= 0:
+		case total < req:
+			never = append(never, fmt.Sprintf("%s: %s total < %s", name, format(total), format(req)))
+		case avail < req:
+			later = append(later, fmt.Sprintf("%s: %s avail < %s", name, format(avail), format(req)))
+		}
+	}
+	count := func(v int64) string { return fmt.Sprintf("%d", v) }
+	size := func(v int64) string { return fmt.Sprintf("%dG", v>>30) }
+
+	check("cpus", int64(r.Procs), int64(n.TotalProcs), int64(n.AvailProcs), count)
+	check("mem", r.MemBytes, int64(n.TotalMemGB)<<30, int64(n.AvailMemGB)<<30, size)
+	// only the GPUs of the requested model count on a node with GPUs of multiple models
+	totalGPUs, availGPUs := n.TotalGPUS, n.AvailGPUS
+	if r.GPUModel != "" && len(n.GPUs) > 0 {
+		totalGPUs, availGPUs = n.GPUsOf(r.GPUModel)
+	}
+	check("gpus", int64(r.GPUs), int64(totalGPUs), int64(availGPUs), count)
+	check("tmp", r.TmpBytes, int64(n.TotalDiskGB)<<30, int64(n.AvailDiskGB)<<30, size)
+
+	if len(never) > 0 {
+		return FitNever, never
+	}
+
+	// node state
+	if n.Unavailable() || n.Reason != "" {
+		s := fmt.Sprintf("state %s", n.FullState())
+		if n.Reason != "" {
+			s = fmt.Sprintf("%s (%s)", s, n.Reason)
+		}
+		later = append(later, s)
+	}
+
+	if len(later) > 0 {
+		return FitLater, later
+	}
+
+	return FitNow, nil
+}
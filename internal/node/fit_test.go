@@ -0,0 +1,64 @@
+package node
+
+import (
+	"testing"
+)
+
+var (
+	fitNode = Node{
+		ID:          "dccn-c083",
+		Cluster:     ClusterSlurm,
+		State:       "MIXED",
+		Partitions:  []string{"batch", "gpu"},
+		TotalProcs:  64,
+		AvailProcs:  8,
+		TotalMemGB:  512,
+		AvailMemGB:  64,
+		TotalDiskGB: 400,
+		AvailDiskGB: 400,
+		TotalGPUS:   4,
+		AvailGPUS:   1,
+		GPUModel:    "nvidia_a100-sxm4-40gb",
+	}
+)
+
+func TestFit(t *testing.T) {
+
+	drained := fitNode
+	drained.State = "IDLE"
+	drained.Reason = "memory test"
+
+	reserved := fitNode
+	reserved.StateFlags = []string{"RESERVED"}
+
+	mixed := fitNode
+	mixed.GPUs = []GPU{{Model: "nvidia_a100-sxm4-40gb", Total: 2, Avail: 0}, {Model: "nvidia_v100", Total: 2, Avail: 1}}
+
+	cases := []struct {
+		node    Node
+		req     Request
+		fit     Fit
+		reasons int
+	}{
+		{fitNode, Request{}, FitNow, 0},
+		{fitNode, Request{Procs: 8, MemBytes: 32 << 30, GPUs: 1, GPUModel: "A100", TmpBytes: 200 << 30, Partition: "gpu"}, FitNow, 0},
+		{fitNode, Request{Procs: 16, GPUs: 2}, FitLater, 2},
+		{fitNode, Request{Procs: 128, GPUs: 2}, FitNever, 1},
+		{fitNode, Request{GPUModel: "h100", Partition: "interactive"}, FitNever, 2},
+		{fitNode, Request{TmpBytes: 1 << 40}, FitNever, 1},
+		{mixed, Request{GPUs: 1, GPUModel: "v100"}, FitNow, 0},
+		{mixed, Request{GPUs: 1, GPUModel: "a100"}, FitLater, 1},
+		{mixed, Request{GPUs: 3, GPUModel: "a100"}, FitNever, 1},
+		{drained, Request{Procs: 1}, FitLater, 1},
+		{reserved, Request{Procs: 1}, FitLater, 1},
+		{drained, Request{Procs: 16, MemBytes: 1 << 40}, FitNever, 1},
+	}
+
+	for _, c := range cases {
+		fit, reasons := c.node.Fit(c.req)
+		t.Logf("%+v: %s %+v", c.req, fit, reasons)
+		if fit != c.fit || len(reasons) != c.reasons {
+			t.Errorf("%+v: expect %s with %d reasons, got %s %+v", c.req, c.fit, c.reasons, fit, reasons)
+		}
+	}
+}
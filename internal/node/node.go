@@ -0,0 +1,170 @@
+package node
+
+import (
+	"strings"
+	"time"
+
+	trqhelper "github.com/Donders-Institute/hpc-torque-helper/pkg/client"
+)
+
+const (
+	// ClusterTorque is the name of the Torque cluster.
+	ClusterTorque = "torque"
+	// ClusterSlurm is the name of the Slurm cluster.
+	ClusterSlurm = "slurm"
+)
+
+// Node defines the scheduler-neutral data structure of the resource status of a cluster
+// node.  Attributes not provided by a scheduler are left with the zero value.
+type Node struct {
+	// ID is the hostname of the node.
+	ID string `json:"id"`
+	// Cluster is the name of the cluster the node belongs to, i.e. `ClusterTorque` or
+	// `ClusterSlurm`.
+	Cluster string `json:"cluster"`
+	State   string `json:"state"`
+	// StateFlags are the flags of the Slurm node state, e.g. `DRAIN` for the state
+	// `IDLE+DRAIN`.
+	StateFlags []string `json:"state_flags"`
+	// Reason is the reason why the node is down or drained.
+	Reason string `json:"reason"`
+	// ReasonUser and ReasonTime are the user who set the reason and when.
+	ReasonUser string    `json:"reason_user"`
+	ReasonTime time.Time `json:"reason_time"`
+	// Reservations are the current and upcoming reservations of the node.
+	Reservations []Reservation `json:"reservations"`
+	// Features is the list of (available) node features.
+	Features []string `json:"features"`
+	// ActiveFeatures is the list of node features currently active on the node.
+	ActiveFeatures []string `json:"active_features"`
+	// Partitions is the list of Slurm partitions the node belongs to.
+	Partitions []string `json:"partitions"`
+	// CPUVendor is the manufacturer of the CPU, e.g. `AMD` or `INTEL`.
+	CPUVendor   string  `json:"cpu_vendor"`
+	TotalProcs  int     `json:"total_procs"`
+	AvailProcs  int     `json:"avail_procs"`
+	CPULoad     float64 `json:"cpu_load"`
+	TotalMemGB  int     `json:"total_mem_gb"`
+	AvailMemGB  int     `json:"avail_mem_gb"`
+	FreeMemGB   int     `json:"free_mem_gb"`
+	TotalDiskGB int     `json:"total_disk_gb"`
+	AvailDiskGB int     `json:"avail_disk_gb"`
+	TotalGPUS   int     `json:"total_gpus"`
+	AvailGPUS   int     `json:"avail_gpus"`
+	// GPUModel is the model of the GPUs, e.g. `nvidia_a100-sxm4-40gb`.  For a node with GPUs
+	// of multiple models, it is the first model in `GPUs`.
+	GPUModel string `json:"gpu_model"`
+	// GPUs is the GPU status per model.  It is only provided by Slurm.
+	GPUs        []GPU     `json:"gpus"`
+	NetworkGbps int       `json:"network_gbps"`
+	BootTime    time.Time `json:"boot_time"`
+	// LastBusyTime is the last time the node had jobs running on it.
+	LastBusyTime time.Time `json:"last_busy_time"`
+}
+
+// Reservation is a reservation of the node, e.g. for maintenance.
+type Reservation struct {
+	Name      string    `json:"name"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	// Flags are the reservation flags, e.g. `MAINT`.
+	Flags []string `json:"flags"`
+}
+
+// FullState returns the node state with the state flags, e.g. `IDLE+DRAIN`.
+func (n Node) FullState() string {
+	return strings.Join(append([]string{n.State}, n.StateFlags...), "+")
+}
+
+// GPU is the status of the GPUs of a model on a node.
+type GPU struct {
+	// Model is the GPU model, e.g. `nvidia_a100-sxm4-40gb`.  It is empty if the GPUs are
+	// configured without a type.
+	Model string `json:"model"`
+	Total int    `json:"total"`
+	Avail int    `json:"avail"`
+	// UsedIndices are the indices of the GPUs in use, e.g. `[0 2]`.
+	UsedIndices []int `json:"used_indices"`
+	// Sockets are the CPU sockets the GPUs are affine to.
+	Sockets []int `json:"sockets"`
+}
+
+// hasGPUModel checks whether the node has GPUs of which the model contains `model`
+// case-insensitively.
+func (n Node) hasGPUModel(model string) bool {
+	model = strings.ToLower(model)
+	if strings.Contains(strings.ToLower(n.GPUModel), model) {
+		return true
+	}
+	for _, g := range n.GPUs {
+		if strings.Contains(strings.ToLower(g.Model), model) {
+			return true
+		}
+	}
+	return false
+}
+
+// GPUsOf returns the total and available number of GPUs of which the model contains `model`
+// case-insensitively.  If the node has no GPU status per model, the overall number of GPUs is
+// returned when `GPUModel` matches.
+func (n Node) GPUsOf(model string) (total, avail int) {
+
+	model = strings.ToLower(model)
+
+	if len(n.GPUs) == 0 {
+		if strings.Contains(strings.ToLower(n.GPUModel), model) {
+			return n.TotalGPUS, n.AvailGPUS
+		}
+		return 0, 0
+	}
+
+	for _, g := range n.GPUs {
+		if strings.Contains(strings.ToLower(g.Model), model) {
+			total += g.Total
+			avail += g.Avail
+		}
+	}
+	return total, avail
+}
+
+// HasFeature checks whether the node has the feature `f`.  For a Slurm node, the partitions
+// are also considered as node features.
+func (n Node) HasFeature(f string) bool {
+	for _, l := range [][]string{n.Features, n.Partitions} {
+		for _, e := range l {
+			if e == f {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FromTorque converts the node resource status retrieved from the Torque helper into `Node`.
+func FromTorque(r trqhelper.NodeResourceStatus) Node {
+
+	n := Node{
+		ID:          r.ID,
+		Cluster:     ClusterTorque,
+		State:       r.State,
+		Features:    r.Features,
+		TotalProcs:  r.TotalProcs,
+		AvailProcs:  r.AvailProcs,
+		TotalMemGB:  r.TotalMemGB,
+		AvailMemGB:  r.AvailMemGB,
+		TotalDiskGB: r.TotalDiskGB,
+		AvailDiskGB: r.AvailDiskGB,
+		TotalGPUS:   r.TotalGPUS,
+		AvailGPUS:   r.AvailGPUS,
+		NetworkGbps: r.NetworkGbps,
+	}
+
+	switch {
+	case r.IsAMD:
+		n.CPUVendor = "AMD"
+	case r.IsIntel:
+		n.CPUVendor = "INTEL"
+	}
+
+	return n
+}
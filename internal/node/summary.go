@@ -0,0 +1,152 @@
+package node
+
+import (
+	"sort"
+)
+
+const (
+	// SummaryTotal is the summary group of all nodes.
+	SummaryTotal = "total"
+	// SummaryCluster is the summary group of the nodes by cluster.
+	SummaryCluster = "cluster"
+	// SummaryPartition is the summary group of the nodes by Slurm partition.  A node in
+	// multiple partitions is counted in each of them.
+	SummaryPartition = "partition"
+	// SummaryState is the summary group of the nodes by node state.
+	SummaryState = "state"
+)
+
+// GPUCapacity is the aggregated capacity of the GPUs of a model.
+type GPUCapacity struct {
+	Model string `json:"model"`
+	Total int    `json:"total"`
+	Avail int    `json:"avail"`
+}
+
+// Capacity is the aggregated resource capacity of a group of nodes.  The allocated amount of
+// a resource is the total minus the available amount of each node.  The available amount of
+// the nodes which cannot take jobs, e.g. a drained or reserved node, is not counted, so that
+// the total may be more than the available plus the allocated amount.
+type Capacity struct {
+	// Group is the grouping of the nodes, e.g. `SummaryCluster`.
+	Group string `json:"group"`
+	// Name is the name of the group member, e.g. `slurm` for the `SummaryCluster` group.
+	Name        string        `json:"name"`
+	Nodes       int           `json:"nodes"`
+	TotalProcs  int           `json:"total_procs"`
+	AvailProcs  int           `json:"avail_procs"`
+	AllocProcs  int           `json:"alloc_procs"`
+	TotalGPUS   int           `json:"total_gpus"`
+	AvailGPUS   int           `json:"avail_gpus"`
+	AllocGPUS   int           `json:"alloc_gpus"`
+	GPUModels   []GPUCapacity `json:"gpu_models"`
+	TotalMemGB  int           `json:"total_mem_gb"`
+	AvailMemGB  int           `json:"avail_mem_gb"`
+	AllocMemGB  int           `json:"alloc_mem_gb"`
+	TotalDiskGB int           `json:"total_disk_gb"`
+	AvailDiskGB int           `json:"avail_disk_gb"`
+	AllocDiskGB int           `json:"alloc_disk_gb"`
+}
+
+// add adds the resources of the node `n` to the capacity.
+func (c *Capacity) add(n Node) {
+
+	available := !n.Unavailable()
+
+	c.Nodes++
+	c.TotalProcs += n.TotalProcs
+	c.TotalGPUS += n.TotalGPUS
+	c.TotalMemGB += n.TotalMemGB
+	c.TotalDiskGB += n.TotalDiskGB
+
+	c.AllocProcs += n.TotalProcs - n.AvailProcs
+	c.AllocGPUS += n.TotalGPUS - n.AvailGPUS
+	c.AllocMemGB += n.TotalMemGB - n.AvailMemGB
+	c.AllocDiskGB += n.TotalDiskGB - n.AvailDiskGB
+
+	if available {
+		c.AvailProcs += n.AvailProcs
+		c.AvailGPUS += n.AvailGPUS
+		c.AvailMemGB += n.AvailMemGB
+		c.AvailDiskGB += n.AvailDiskGB
+	}
+
+	// GPUs by model; a node without the GPU status per model counts as a single model
+	gpus := n.GPUs
+	if len(gpus) == 0 && n.TotalGPUS > 0 {
+		gpus = []GPU{{Model: n.GPUModel, Total: n.TotalGPUS, Avail: n.AvailGPUS}}
+	}
+	for _, g := range gpus {
+		if !available {
+			g.Avail = 0
+		}
+		c.addGPUs(g)
+	}
+}
+
+// addGPUs adds the GPUs `g` to the capacity of the GPU model.  GPUs without model are counted
+// as the model `unknown`.
+func (c *Capacity) addGPUs(g GPU) {
+
+	model := g.Model
+	if model == "" {
+		model = "unknown"
+	}
+	for i := range c.GPUModels {
+		if c.GPUModels[i].Model == model {
+			c.GPUModels[i].Total += g.Total
+			c.GPUModels[i].Avail += g.Avail
+			return
+		}
+	}
+	c.GPUModels = append(c.GPUModels, GPUCapacity{Model: model, Total: g.Total, Avail: g.Avail})
+	sort.Slice(c.GPUModels, func(i, j int) bool {
+		return c.GPUModels[i].Model < c.GPUModels[j].Model
+	})
+}
+
+// Summarize aggregates the resource capacity of the `nodes` in total, by cluster, by Slurm
+// partition and by node state.  The capacities are ordered by the group in this order, and
+// then by the name.
+func Summarize(nodes []Node) []Capacity {
+
+	total := Capacity{Group: SummaryTotal, Name: "all"}
+
+	groups := map[string]map[string]*Capacity{
+		SummaryCluster:   {},
+		SummaryPartition: {},
+		SummaryState:     {},
+	}
+
+	addTo := func(group, name string, n Node) {
+		c, ok := groups[group][name]
+		if !ok {
+			c = &Capacity{Group: group, Name: name}
+			groups[group][name] = c
+		}
+		c.add(n)
+	}
+
+	for _, n := range nodes {
+		total.add(n)
+		addTo(SummaryCluster, n.Cluster, n)
+		for _, p := range n.Partitions {
+			addTo(SummaryPartition, p, n)
+		}
+		addTo(SummaryState, n.State, n)
+	}
+
+	caps := []Capacity{total}
+	for _, g := range []string{SummaryCluster, SummaryPartition, SummaryState} {
+		names := make([]string, 0, len(groups[g]))
+		for name := range groups[g] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			caps = append(caps, *groups[g][name])
+		}
+	}
+
+	return caps
+}
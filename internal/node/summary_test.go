@@ -0,0 +1,65 @@
+package node
+
+import (
+	"testing"
+)
+
+var (
+	summaryNodes = []Node{
+		{ID: "dccn-c005", Cluster: ClusterTorque, State: "Busy", TotalProcs: 16, AvailProcs: 0, TotalMemGB: 128, AvailMemGB: 16, TotalGPUS: 2, AvailGPUS: 1},
+		{ID: "dccn-c075", Cluster: ClusterSlurm, State: "IDLE", Partitions: []string{"batch"}, TotalProcs: 32, AvailProcs: 32, TotalMemGB: 256, AvailMemGB: 256, TotalDiskGB: 400, AvailDiskGB: 400},
+		{ID: "dccn-c083", Cluster: ClusterSlurm, State: "MIXED", Partitions: []string{"batch", "gpu"}, TotalProcs: 64, AvailProcs: 8, TotalMemGB: 512, AvailMemGB: 64, TotalDiskGB: 400, AvailDiskGB: 200, TotalGPUS: 4, AvailGPUS: 1, GPUModel: "nvidia_a100-sxm4-40gb"},
+	}
+)
+
+func TestSummarize(t *testing.T) {
+
+	caps := Summarize(summaryNodes)
+	for _, c := range caps {
+		t.Logf("%+v", c)
+	}
+
+	expect := []string{
+		"total:all",
+		"cluster:slurm", "cluster:torque",
+		"partition:batch", "partition:gpu",
+		"state:Busy", "state:IDLE", "state:MIXED",
+	}
+	if len(caps) != len(expect) {
+		t.Fatalf("expect %d capacities, got %d", len(expect), len(caps))
+	}
+	for i, c := range caps {
+		if c.Group+":"+c.Name != expect[i] {
+			t.Errorf("expect %s, got %s:%s", expect[i], c.Group, c.Name)
+		}
+	}
+
+	if c := caps[0]; c.Nodes != 3 || c.TotalProcs != 112 || c.AllocProcs != 72 || c.AllocMemGB != 560 || c.AllocDiskGB != 200 || c.AllocGPUS != 4 {
+		t.Errorf("unexpected total capacity: %+v", c)
+	}
+	if m := caps[0].GPUModels; len(m) != 2 || m[0].Model != "nvidia_a100-sxm4-40gb" || m[1].Model != "unknown" || m[1].Avail != 1 {
+		t.Errorf("unexpected GPU models: %+v", m)
+	}
+	// GPUs of multiple models on a node are counted per model
+	mixed := Node{ID: "dccn-c090", Cluster: ClusterSlurm, TotalGPUS: 4, AvailGPUS: 1, GPUModel: "nvidia_a100-sxm4-40gb",
+		GPUs: []GPU{{Model: "nvidia_a100-sxm4-40gb", Total: 2, Avail: 0}, {Model: "nvidia_v100", Total: 2, Avail: 1}}}
+	if m := Summarize([]Node{mixed})[0].GPUModels; len(m) != 2 || m[0].Total != 2 || m[1].Model != "nvidia_v100" || m[1].Avail != 1 {
+		t.Errorf("unexpected GPU models of mixed node: %+v", m)
+	}
+
+	if c := caps[3]; c.Nodes != 2 || c.AvailProcs != 40 {
+		t.Errorf("unexpected capacity of partition batch: %+v", c)
+	}
+
+	// the available resources of the drained node are not counted
+	drained := Node{ID: "dccn-c084", Cluster: ClusterSlurm, State: "IDLE", StateFlags: []string{"DRAIN"}, TotalProcs: 64, AvailProcs: 64,
+		TotalMemGB: 512, AvailMemGB: 512, TotalGPUS: 4, AvailGPUS: 4, GPUModel: "nvidia_a100-sxm4-40gb"}
+	c := Summarize(append([]Node{drained}, summaryNodes...))[0]
+	t.Logf("%+v", c)
+	if c.Nodes != 4 || c.TotalProcs != 176 || c.AvailProcs != 40 || c.AllocProcs != 72 || c.AvailMemGB != 336 || c.TotalGPUS != 10 || c.AvailGPUS != 2 || c.AllocGPUS != 4 {
+		t.Errorf("unexpected total capacity with drained node: %+v", c)
+	}
+	if m := c.GPUModels; len(m) != 2 || m[0].Total != 8 || m[0].Avail != 1 {
+		t.Errorf("unexpected GPU models with drained node: %+v", m)
+	}
+}
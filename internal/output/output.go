@@ -0,0 +1,246 @@
+// Package output provides the rendering layer shared by the commands to print typed records
+// in a human-readable table or in a machine-readable format (i.e. JSON, YAML or CSV).
+//
+// Records are structs of which the exported fields are tagged with the `json` struct tag.  The
+// tag name is the stable field name used in all machine-readable formats; fields tagged with
+// `json:"-"` are left out.
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// FormatTable is the human-readable table format rendered by the command itself.
+	FormatTable = "table"
+	// FormatJSON is the JSON format; records are rendered as an array of objects.
+	FormatJSON = "json"
+	// FormatYAML is the YAML format; records are rendered as a sequence of mappings.
+	FormatYAML = "yaml"
+	// FormatCSV is the CSV format with a header line of the field names.
+	FormatCSV = "csv"
+)
+
+// Formats is the list of supported output formats.
+var Formats = []string{FormatTable, FormatJSON, FormatYAML, FormatCSV}
+
+// CheckFormat returns an error if `format` is not one of the supported output `Formats`.
+func CheckFormat(format string) error {
+	for _, f := range Formats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported output format: %s (expect one of %s)", format, strings.Join(Formats, ", "))
+}
+
+// Render writes the `records`, a slice of structs, to `w` in the given `format`.  For the
+// `FormatTable` format, the function `table` is called to render the human-readable table.
+func Render(w io.Writer, format string, records interface{}, table func(w io.Writer)) error {
+
+	switch format {
+	case FormatTable:
+		table(w)
+		return nil
+	case FormatJSON:
+		return renderJSON(w, records)
+	case FormatYAML:
+		return renderYAML(w, records)
+	case FormatCSV:
+		return renderCSV(w, records)
+	default:
+		return CheckFormat(format)
+	}
+}
+
+// renderJSON writes `records` to `w` as an indented JSON array.
+func renderJSON(w io.Writer, records interface{}) error {
+	data, err := json.MarshalIndent(normalize(records), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// renderYAML writes `records` to `w` as a YAML sequence.  The records are converted via JSON
+// so that the field names and their order are the same as in the JSON output.
+func renderYAML(w io.Writer, records interface{}) error {
+	data, err := json.Marshal(normalize(records))
+	if err != nil {
+		return err
+	}
+
+	var seq []yaml.MapSlice
+	if err := yaml.Unmarshal(data, &seq); err != nil {
+		return err
+	}
+
+	if len(seq) == 0 {
+		_, err = fmt.Fprintln(w, "[]")
+		return err
+	}
+
+	data, err = yaml.Marshal(seq)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// renderCSV writes `records` to `w` in CSV with a header line of the field names.
+//
+// A value of the type `time.Time` is written in RFC3339 (or empty for the zero time), and a
+// list of scalars is joined with `;`.  Other non-scalar values are encoded in JSON.
+func renderCSV(w io.Writer, records interface{}) error {
+
+	rv := reflect.ValueOf(normalize(records))
+
+	fields, err := recordFields(rv.Type().Elem())
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+
+	header := make([]string, len(fields))
+	for i, f := range fields {
+		header[i] = f.name
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for i := 0; i < rv.Len(); i++ {
+		r := reflect.Indirect(rv.Index(i))
+		row := make([]string, len(fields))
+		for j, f := range fields {
+			if row[j], err = csvValue(r.Field(f.index)); err != nil {
+				return err
+			}
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// normalize returns `records` as a non-nil slice, so that an empty result is rendered as an
+// empty list rather than `null`.
+func normalize(records interface{}) interface{} {
+	rv := reflect.ValueOf(records)
+	if rv.Kind() != reflect.Slice {
+		panic(fmt.Sprintf("records is not a slice: %T", records))
+	}
+	if rv.IsNil() {
+		return reflect.MakeSlice(rv.Type(), 0, 0).Interface()
+	}
+	return records
+}
+
+// recordField is an exported field of the record struct with its stable name.
+type recordField struct {
+	index int
+	name  string
+}
+
+// recordFields returns the exported fields of the record type `t` with the name given by
+// the `json` struct tag.
+func recordFields(t reflect.Type) ([]recordField, error) {
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("record is not a struct: %s", t)
+	}
+
+	fields := []recordField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		fields = append(fields, recordField{index: i, name: name})
+	}
+
+	return fields, nil
+}
+
+// csvValue converts the field value `v` into a CSV cell.
+func csvValue(v reflect.Value) (string, error) {
+
+	if t, ok := v.Interface().(time.Time); ok {
+		if t.IsZero() {
+			return "", nil
+		}
+		return t.Format(time.RFC3339), nil
+	}
+
+	if s, ok := scalarValue(v); ok {
+		return s, nil
+	}
+
+	if v.Kind() == reflect.Slice {
+		elems := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s, ok := scalarValue(v.Index(i))
+			if !ok {
+				elems = nil
+				break
+			}
+			elems = append(elems, s)
+		}
+		if elems != nil {
+			return strings.Join(elems, ";"), nil
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v.Interface()); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// scalarValue converts the scalar value `v` into string.  The second return value is false
+// if `v` is not a scalar.
+func scalarValue(v reflect.Value) (string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
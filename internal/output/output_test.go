@@ -0,0 +1,105 @@
+package output
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testRecord struct {
+	ID       string    `json:"id"`
+	Procs    int       `json:"procs"`
+	Load     float64   `json:"load"`
+	Features []string  `json:"features"`
+	Boot     time.Time `json:"boot_time"`
+	Secret   string    `json:"-"`
+	internal string
+}
+
+var testRecords = []testRecord{
+	{
+		ID:       "dccn-c083",
+		Procs:    64,
+		Load:     12.5,
+		Features: []string{"matlab", "cuda"},
+		Boot:     time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC),
+		Secret:   "secret",
+	},
+	{
+		ID:    "dccn-c084, rack 2",
+		Procs: 32,
+	},
+}
+
+func TestRenderCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Render(&buf, FormatCSV, testRecords, nil); err != nil {
+		t.Fatalf("%s", err)
+	}
+	t.Logf("\n%s", buf.String())
+
+	expected := `id,procs,load,features,boot_time
+dccn-c083,64,12.5,matlab;cuda,2024-11-01T08:00:00Z
+"dccn-c084, rack 2",32,0,,
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected CSV output:\n%s", buf.String())
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Render(&buf, FormatJSON, testRecords, nil); err != nil {
+		t.Fatalf("%s", err)
+	}
+	t.Logf("\n%s", buf.String())
+
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("field with json:\"-\" tag is rendered")
+	}
+	if !strings.Contains(buf.String(), `"boot_time": "2024-11-01T08:00:00Z"`) {
+		t.Errorf("boot_time is not rendered")
+	}
+
+	// empty result is an empty list
+	buf.Reset()
+	var empty []testRecord
+	if err := Render(&buf, FormatJSON, empty, nil); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("unexpected output of empty records: %s", buf.String())
+	}
+}
+
+func TestRenderYAML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Render(&buf, FormatYAML, testRecords, nil); err != nil {
+		t.Fatalf("%s", err)
+	}
+	t.Logf("\n%s", buf.String())
+
+	// fields are in the order of the record struct
+	if !strings.HasPrefix(buf.String(), "- id: dccn-c083\n  procs: 64\n  load: 12.5\n") {
+		t.Errorf("unexpected YAML output:\n%s", buf.String())
+	}
+}
+
+func TestRenderTable(t *testing.T) {
+	called := false
+	err := Render(io.Discard, FormatTable, testRecords, func(w io.Writer) {
+		called = true
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if !called {
+		t.Errorf("table function is not called")
+	}
+
+	if err := Render(io.Discard, "xml", testRecords, nil); err == nil {
+		t.Errorf("expect error for unsupported format")
+	}
+}
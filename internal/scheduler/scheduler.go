@@ -0,0 +1,175 @@
+// Package scheduler defines a scheduler-neutral interface to the cluster job schedulers
+// (i.e. Torque and Slurm), so that the cluster subcommands work uniformly regardless the
+// scheduler managing the jobs and nodes.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/Donders-Institute/hpc-utility/internal/node"
+	"github.com/Donders-Institute/hpc-utility/internal/vnc"
+)
+
+const (
+	gib float64 = 1024 * 1024 * 1024
+)
+
+// ErrUnknownJob is returned by the `Scheduler` when the job is not managed by it.  It allows
+// the caller to fall back to another scheduler.
+var ErrUnknownJob = errors.New("unknown job")
+
+// Job defines the scheduler-neutral summary of a cluster job.
+type Job struct {
+	ID string `json:"id"`
+	// Cluster is the name of the scheduler managing the job.
+	Cluster string `json:"cluster"`
+	Name    string `json:"name"`
+	User    string `json:"user"`
+	// Queue is the Torque queue or the Slurm partition of the job.
+	Queue    string `json:"queue"`
+	State    string `json:"state"`
+	Reason   string `json:"reason"`
+	NumProcs int    `json:"num_procs"`
+	TimeUsed string `json:"time_used"`
+	// TimeLimit is the requested walltime of the job.
+	TimeLimit string `json:"time_limit"`
+	// Nodes is the list of nodes on which the job is running.
+	Nodes []string `json:"nodes"`
+}
+
+// reJobIDPart matches the numeric and non-numeric parts of a job id, e.g. `4321`, `_` and `7`
+// of the array job id `4321_7`.
+var reJobIDPart = regexp.MustCompile(`[0-9]+|[^0-9]+`)
+
+// LessJobID checks whether the job id `a` sorts before `b`.  The numbers in the ids are
+// compared numerically, so that e.g. `999` sorts before `1000`, and the tasks of a job array
+// (e.g. `4321_7` of Slurm or `4321[7]` of Torque) and the components of a heterogeneous job
+// (e.g. `4321+1`) sort right after the job itself and by the task number or the offset.
+func LessJobID(a, b string) bool {
+
+	pa, pb := reJobIDPart.FindAllString(a, -1), reJobIDPart.FindAllString(b, -1)
+
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		if pa[i] == pb[i] {
+			continue
+		}
+		na, erra := strconv.ParseUint(pa[i], 10, 64)
+		nb, errb := strconv.ParseUint(pb[i], 10, 64)
+		if erra == nil && errb == nil && na != nb {
+			return na < nb
+		}
+		return pa[i] < pb[i]
+	}
+
+	return len(pa) < len(pb)
+}
+
+// Options defines the configuration parameters for the scheduler backends.
+type Options struct {
+	TorqueServerHost string
+	TorqueHelperPort int
+	TorqueHelperCert string
+}
+
+// Scheduler defines the interface of a cluster job scheduler backend.  The calls are aborted
+// when `ctx` is done, if the scheduler supports it.
+type Scheduler interface {
+	// Name returns the name of the scheduler, e.g. `torque` or `slurm`.
+	Name() string
+	// ListNodes returns resource status of the nodes `ids`, or all nodes if `ids` is empty.
+	// Retrieving the status is aborted when `ctx` is done.
+	ListNodes(ctx context.Context, ids ...string) ([]node.Node, error)
+	// ListJobs returns jobs of the `users`, or jobs of all users if `users` is empty.
+	ListJobs(ctx context.Context, users ...string) ([]Job, error)
+	// JobInfo writes detailed information of the job `id` to `w`.
+	JobInfo(ctx context.Context, w io.Writer, id string) error
+	// JobTrace writes the trace log of the job `id` in the time window between `since`
+	// and `until` to `w`.  The time window is ignored if it is not supported by the scheduler.
+	JobTrace(ctx context.Context, w io.Writer, id, since, until string) error
+	// JobMemory writes the memory usage of the running job `id` to `w`.
+	JobMemory(ctx context.Context, w io.Writer, id string) error
+	// Config writes the scheduler configuration to `w`.
+	Config(ctx context.Context, w io.Writer) error
+}
+
+// QueuePrinter is implemented by the schedulers printing the jobs in the memory of the
+// scheduler server, e.g. `qstat` of Torque.
+type QueuePrinter interface {
+	// PrintQueue writes the jobs in the memory of the server to `w`, in XML if `xml` is true.
+	PrintQueue(ctx context.Context, w io.Writer, xml bool) error
+}
+
+// VNCLister is implemented by the schedulers providing the VNC servers on the access nodes,
+// e.g. Torque via the Torque helper service on the access nodes.
+type VNCLister interface {
+	// ListVNCServers returns the VNC servers running on the access node `host`.
+	ListVNCServers(ctx context.Context, host string) ([]vnc.Server, error)
+}
+
+// backend defines a registered scheduler backend.
+type backend struct {
+	// priority determines the order of the backend in auto-detection; lower goes first.
+	priority int
+	// new creates the `Scheduler` with the given options.
+	new func(opts Options) Scheduler
+	// available checks whether the scheduler can be used in the current environment.
+	available func(opts Options) bool
+}
+
+// backends is the registry of scheduler backends by name.
+var backends = make(map[string]backend)
+
+// Register registers a scheduler backend by `name`.  It is called by the `init` function of
+// the backend implementation.
+func Register(name string, priority int, new func(opts Options) Scheduler, available func(opts Options) bool) {
+	if _, ok := backends[name]; ok {
+		panic(fmt.Sprintf("scheduler backend already registered: %s", name))
+	}
+	backends[name] = backend{priority: priority, new: new, available: available}
+}
+
+// Names returns names of the registered scheduler backends in the order of auto-detection.
+func Names() []string {
+	names := make([]string, 0, len(backends))
+	for n := range backends {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		bi, bj := backends[names[i]], backends[names[j]]
+		if bi.priority != bj.priority {
+			return bi.priority < bj.priority
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// New returns the schedulers selected by `name`.  If `name` is `auto`, all the registered
+// schedulers available in the current environment are returned in the order of `Names`.
+func New(name string, opts Options) ([]Scheduler, error) {
+
+	if name == "auto" {
+		scheds := []Scheduler{}
+		for _, n := range Names() {
+			if b := backends[n]; b.available(opts) {
+				scheds = append(scheds, b.new(opts))
+			}
+		}
+		if len(scheds) == 0 {
+			return nil, fmt.Errorf("no scheduler available")
+		}
+		return scheds, nil
+	}
+
+	b, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown scheduler: %s", name)
+	}
+	return []Scheduler{b.new(opts)}, nil
+}
@@ -0,0 +1,251 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Donders-Institute/hpc-utility/internal/node"
+	"github.com/Donders-Institute/hpc-utility/internal/slurm"
+	"github.com/Donders-Institute/hpc-utility/internal/torque/torquetest"
+	"github.com/Donders-Institute/hpc-utility/internal/util"
+)
+
+func TestNames(t *testing.T) {
+	names := Names()
+	t.Logf("%+v", names)
+
+	if len(names) != 2 || names[0] != node.ClusterSlurm || names[1] != node.ClusterTorque {
+		t.Errorf("unexpected scheduler names: %+v", names)
+	}
+}
+
+func TestLessJobID(t *testing.T) {
+
+	ids := []string{"4321+1", "10000", "4321_10", "999", "4321", "4321_9", "4321+0", "4321_[11-20]", "123.dccn-l029.dccn.nl", "123[2].dccn-l029.dccn.nl"}
+	sort.Slice(ids, func(i, j int) bool {
+		return LessJobID(ids[i], ids[j])
+	})
+	t.Logf("%+v", ids)
+
+	expect := []string{"123.dccn-l029.dccn.nl", "123[2].dccn-l029.dccn.nl", "999", "4321", "4321+0", "4321+1", "4321_9", "4321_10", "4321_[11-20]", "10000"}
+	for i, id := range expect {
+		if ids[i] != id {
+			t.Errorf("expect %s at %d, got %s", id, i, ids[i])
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+
+	scheds, err := New(node.ClusterTorque, Options{})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(scheds) != 1 || scheds[0].Name() != node.ClusterTorque {
+		t.Errorf("unexpected schedulers: %+v", scheds)
+	}
+
+	if _, err := New("pbspro", Options{}); err == nil {
+		t.Errorf("expect error for unknown scheduler")
+	}
+}
+
+func TestNewAutoTorque(t *testing.T) {
+
+	srv, err := torquetest.NewServer()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// Torque is detected by the Torque helper service, regardless of the certificate
+	opts := Options{TorqueServerHost: srv.Host, TorqueHelperPort: srv.Port}
+
+	hasTorque := func() bool {
+		scheds, _ := New("auto", opts)
+		for _, s := range scheds {
+			if s.Name() == node.ClusterTorque {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !hasTorque() {
+		t.Errorf("expect torque to be detected")
+	}
+
+	srv.Close()
+	if hasTorque() {
+		t.Errorf("expect torque not to be detected without the Torque helper service")
+	}
+}
+
+func TestTorqueUnknownJob(t *testing.T) {
+
+	srv, err := torquetest.NewServer()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer srv.Close()
+
+	srv.Handle("TraceJob", func(arg string) torquetest.Response {
+		if arg == "999" {
+			return torquetest.Response{ExitCode: 1, Error: "tracejob: Couldn't find Job Id 999.localhost in logs of past 3 days"}
+		}
+		return torquetest.Response{ExitCode: 2, Error: "tracejob: permission denied"}
+	})
+
+	s := newTorque(Options{TorqueServerHost: srv.Host, TorqueHelperPort: srv.Port, TorqueHelperCert: srv.CertFile})
+
+	err = s.JobTrace(context.Background(), io.Discard, "999", "", "")
+	t.Logf("%s", err)
+	if !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("expect ErrUnknownJob for a job not found by tracejob, got %v", err)
+	}
+
+	// other errors of the Torque helper are not an unknown job
+	err = s.JobTrace(context.Background(), io.Discard, "123", "", "")
+	t.Logf("%s", err)
+	if err == nil || errors.Is(err, ErrUnknownJob) {
+		t.Errorf("expect the error of tracejob, got %v", err)
+	}
+}
+
+func TestTorqueListNodesContext(t *testing.T) {
+
+	srv, err := torquetest.NewServer()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer srv.Close()
+
+	// the Torque helper service does not respond
+	block := make(chan struct{})
+	defer close(block)
+	srv.Handle("Checknode", func(arg string) torquetest.Response {
+		<-block
+		return torquetest.Response{}
+	})
+
+	s := newTorque(Options{TorqueServerHost: srv.Host, TorqueHelperPort: srv.Port, TorqueHelperCert: srv.CertFile})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = s.ListNodes(ctx, "dccn-c005")
+	t.Logf("%s", err)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expect deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("call is not given up when the context is done")
+	}
+}
+
+// countRunner counts the system calls replayed by the `ReplayRunner`.
+type countRunner struct {
+	util.ReplayRunner
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (r *countRunner) Run(ctx context.Context, name string, args []string) (bytes.Buffer, error) {
+	r.mu.Lock()
+	r.calls[strings.Join(append([]string{name}, args...), " ")]++
+	r.mu.Unlock()
+	return r.ReplayRunner.Run(ctx, name, args)
+}
+
+func TestSlurmListNodesReservations(t *testing.T) {
+
+	runner := &countRunner{ReplayRunner: util.ReplayRunner{Dir: "../../testdata/exec"}, calls: make(map[string]int)}
+	defer func(r util.Runner) { util.DefaultRunner = r }(util.DefaultRunner)
+	util.DefaultRunner = runner
+
+	s := newSlurm(Options{})
+	for _, id := range []string{"dccn-c083", "dccn-c084", "dccn-c075"} {
+		if _, err := s.ListNodes(context.Background(), id); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	// the reservations are retrieved once for the nodes listed one by one
+	if n := runner.calls["scontrol show reservation"]; n != 1 {
+		t.Errorf("expect reservations retrieved once, got %d", n)
+	}
+}
+
+// failRunner fails every system call with the error `err`.
+type failRunner struct {
+	err error
+}
+
+func (r failRunner) Run(ctx context.Context, name string, args []string) (bytes.Buffer, error) {
+	return bytes.Buffer{}, &util.ExecError{Cmd: name, Args: args, ExitCode: -1, Err: r.err}
+}
+
+func TestSlurmUnknownJob(t *testing.T) {
+
+	defer func(r util.Runner) { util.DefaultRunner = r }(util.DefaultRunner)
+	util.DefaultRunner = &util.ReplayRunner{Dir: "../../testdata/exec"}
+
+	s := newSlurm(Options{})
+
+	err := s.JobInfo(context.Background(), io.Discard, "9999")
+	t.Logf("%s", err)
+	if !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("expect ErrUnknownJob for an invalid job id, got %v", err)
+	}
+
+	// a failure of the system call is not an unknown job
+	util.DefaultRunner = failRunner{err: context.DeadlineExceeded}
+
+	err = s.JobInfo(context.Background(), io.Discard, "4322")
+	t.Logf("%s", err)
+	if err == nil || errors.Is(err, ErrUnknownJob) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expect the error of scontrol, got %v", err)
+	}
+
+	err = s.JobTrace(context.Background(), io.Discard, "4300", "", "")
+	t.Logf("%s", err)
+	if err == nil || errors.Is(err, ErrUnknownJob) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expect the error of sacct, got %v", err)
+	}
+}
+
+func TestSetReservations(t *testing.T) {
+
+	now := time.Date(2024, 11, 20, 12, 0, 0, 0, time.Local)
+
+	resvs := []slurm.Reservation{
+		{Name: "maint", StartTime: now.Add(48 * time.Hour), EndTime: now.Add(56 * time.Hour), Nodes: []string{"dccn-c075", "dccn-c083"}, Flags: []string{"MAINT"}},
+		{Name: "upgrade", StartTime: now.Add(-48 * time.Hour), EndTime: now.Add(-44 * time.Hour), Nodes: []string{"dccn-c083"}},
+		{Name: "project", StartTime: now.Add(-time.Hour), EndTime: now.Add(time.Hour), Nodes: []string{"dccn-c083"}},
+	}
+
+	nodes := []node.Node{{ID: "dccn-c075"}, {ID: "dccn-c083"}, {ID: "dccn-c084"}}
+	setReservations(nodes, resvs, now)
+
+	for _, n := range nodes {
+		t.Logf("%s: %+v", n.ID, n.Reservations)
+	}
+
+	if r := nodes[0].Reservations; len(r) != 1 || r[0].Name != "maint" || r[0].Flags[0] != "MAINT" {
+		t.Errorf("unexpected reservations of %s: %+v", nodes[0].ID, r)
+	}
+	if r := nodes[1].Reservations; len(r) != 2 || r[0].Name != "project" || r[1].Name != "maint" {
+		t.Errorf("unexpected reservations of %s: %+v", nodes[1].ID, r)
+	}
+	if r := nodes[2].Reservations; len(r) != 0 {
+		t.Errorf("unexpected reservations of %s: %+v", nodes[2].ID, r)
+	}
+}
@@ -0,0 +1,354 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Donders-Institute/hpc-utility/internal/hostlist"
+	"github.com/Donders-Institute/hpc-utility/internal/node"
+	"github.com/Donders-Institute/hpc-utility/internal/slurm"
+	"github.com/Donders-Institute/hpc-utility/internal/util"
+	"github.com/olekukonko/tablewriter"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	Register(node.ClusterSlurm, 0, newSlurm, func(opts Options) bool {
+		_, err := exec.LookPath("scontrol")
+		return err == nil
+	})
+}
+
+// reservationsMaxAge is the time for which the reservations retrieved by `ListNodes` are
+// reused, so that the reservations are retrieved once for the nodes listed one by one, e.g.
+// by `nodes status`, rather than for every node.
+const reservationsMaxAge = time.Minute
+
+// Slurm implements the `Scheduler` interface for the Slurm cluster.
+type Slurm struct {
+	// mu guards the reservations cached for the subsequent `ListNodes` calls.
+	mu       sync.Mutex
+	resvs    []slurm.Reservation
+	resvTime time.Time
+}
+
+func newSlurm(opts Options) Scheduler {
+	return &Slurm{}
+}
+
+// Name returns the name of the Slurm scheduler.
+func (s *Slurm) Name() string {
+	return node.ClusterSlurm
+}
+
+// ListNodes returns resource status of the Slurm nodes, together with the current and
+// upcoming reservations of the nodes.
+func (s *Slurm) ListNodes(ctx context.Context, ids ...string) ([]node.Node, error) {
+
+	nodes, err := s.listNodes(ctx, ids...)
+	if err != nil {
+		return nodes, err
+	}
+
+	// the node status is still useful without the reservations
+	resvs, err := s.reservations(ctx)
+	if err != nil {
+		log.Errorf("fail get Slurm reservations: %s", err)
+		return nodes, nil
+	}
+	setReservations(nodes, resvs, time.Now())
+
+	return nodes, nil
+}
+
+// reservations returns the Slurm reservations, retrieved at most once in the
+// `reservationsMaxAge`.  The concurrent calls wait for the reservations being retrieved.
+func (s *Slurm) reservations(ctx context.Context) ([]slurm.Reservation, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.resvTime.IsZero() && time.Since(s.resvTime) < reservationsMaxAge {
+		return s.resvs, nil
+	}
+
+	resvs, err := slurm.GetReservations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.resvs, s.resvTime = resvs, time.Now()
+
+	return resvs, nil
+}
+
+// listNodes returns resource status of the Slurm nodes `ids`, or all nodes if `ids` is empty.
+func (s *Slurm) listNodes(ctx context.Context, ids ...string) ([]node.Node, error) {
+
+	if len(ids) == 0 {
+		return slurm.GetNodeInfo(ctx, "ALL")
+	}
+
+	nodes := []node.Node{}
+	for _, id := range ids {
+		// Slurm node names are short hostnames
+		ns, err := slurm.GetNodeInfo(ctx, hostlist.Short(id))
+		if err != nil {
+			return nodes, err
+		}
+		nodes = append(nodes, ns...)
+	}
+	return nodes, nil
+}
+
+// setReservations sets the reservations `resvs` not yet ended at `now` to the reserved
+// `nodes`.
+func setReservations(nodes []node.Node, resvs []slurm.Reservation, now time.Time) {
+
+	for i := range nodes {
+		for _, r := range resvs {
+			if !r.EndTime.IsZero() && r.EndTime.Before(now) {
+				continue
+			}
+			for _, h := range r.Nodes {
+				if h != nodes[i].ID {
+					continue
+				}
+				nodes[i].Reservations = append(nodes[i].Reservations, node.Reservation{
+					Name:      r.Name,
+					StartTime: r.StartTime,
+					EndTime:   r.EndTime,
+					Flags:     r.Flags,
+				})
+				break
+			}
+		}
+		sort.SliceStable(nodes[i].Reservations, func(a, b int) bool {
+			return nodes[i].Reservations[a].StartTime.Before(nodes[i].Reservations[b].StartTime)
+		})
+	}
+}
+
+// ListJobs returns jobs in the Slurm queue.
+func (s *Slurm) ListJobs(ctx context.Context, users ...string) ([]Job, error) {
+
+	sjobs, err := slurm.GetJobs(ctx, users...)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]Job, 0, len(sjobs))
+	for _, j := range sjobs {
+		job := Job{
+			ID:        j.ID,
+			Cluster:   node.ClusterSlurm,
+			Name:      j.Name,
+			User:      j.User,
+			Queue:     j.Partition,
+			State:     j.State,
+			Reason:    j.Reason,
+			NumProcs:  j.NumCPUs,
+			TimeUsed:  j.TimeUsed,
+			TimeLimit: j.TimeLimit,
+			Nodes:     []string{},
+		}
+		if j.NodeList != "" {
+			// the node list is a hostlist expression, e.g. dccn-c[080-081]
+			if job.Nodes, err = hostlist.Expand(j.NodeList); err != nil {
+				job.Nodes = []string{j.NodeList}
+			}
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// isInvalidJobID checks whether the error `err` of `scontrol` is caused by a job id unknown
+// to Slurm, i.e. `slurm_load_jobs error: Invalid job id specified`.
+func isInvalidJobID(err error) bool {
+	var xerr *util.ExecError
+	return errors.As(err, &xerr) && strings.Contains(xerr.Stderr, "Invalid job id")
+}
+
+// jobInfo retrieves the job information.  The error is `ErrUnknownJob` if the job is not
+// known to Slurm; other errors, e.g. a timeout of `scontrol`, are returned as they are.
+func (s *Slurm) jobInfo(ctx context.Context, id string) ([]slurm.JobInfo, error) {
+	infos, err := slurm.GetJobInfo(ctx, id)
+	if isInvalidJobID(err) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownJob, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(infos) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownJob, id)
+	}
+	return infos, nil
+}
+
+// JobInfo writes the output of `scontrol show job` in a readable form to `w`.
+func (s *Slurm) JobInfo(ctx context.Context, w io.Writer, id string) error {
+
+	infos, err := s.jobInfo(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// formatTime prints the zero time as `N/A`.
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return "N/A"
+		}
+		return t.Format(time.RFC3339)
+	}
+
+	// formatTRES prints the trackable resources in a sorted order.
+	formatTRES := func(tres map[string]string) string {
+		rs := []string{}
+		for k, v := range tres {
+			rs = append(rs, fmt.Sprintf("%s=%s", k, v))
+		}
+		sort.Strings(rs)
+		return strings.Join(rs, ",")
+	}
+
+	for _, info := range infos {
+		fmt.Fprintf(w, "\n%-s (slurm)", info.ID)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Name", info.Name)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "User", info.User)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Account", info.Account)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "QOS", info.QOS)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Partition", info.Partition)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "State", info.State)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Reason", info.Reason)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Exit code", info.ExitCode)
+		if info.ArrayJobID != "" {
+			fmt.Fprintf(w, "\n\t%-16s: %-s_%-s", "Array job", info.ArrayJobID, info.ArrayTaskID)
+		}
+		if info.HetJobID != "" {
+			fmt.Fprintf(w, "\n\t%-16s: %-s+%-s", "Het job", info.HetJobID, info.HetJobOffset)
+		}
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Submit time", formatTime(info.SubmitTime))
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Start time", formatTime(info.StartTime))
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "End time", formatTime(info.EndTime))
+		fmt.Fprintf(w, "\n\t%-16s: %-s/%-s", "Walltime", info.RunTime, info.TimeLimit)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Requested TRES", formatTRES(info.ReqTRES))
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Allocated TRES", formatTRES(info.AllocTRES))
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Node list", info.NodeList)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Work dir", info.WorkDir)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Command", info.Command)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Stdout", info.StdOut)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Stderr", info.StdErr)
+		fmt.Fprintln(w)
+	}
+
+	return nil
+}
+
+// JobTrace writes the lifecycle of the job assembled from the Slurm accounting records to `w`.
+func (s *Slurm) JobTrace(ctx context.Context, w io.Writer, id, since, until string) error {
+
+	records, err := slurm.GetAcctRecords(ctx, id, since, until)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownJob, id)
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"time", "job id", "event", "detail"})
+	for _, e := range slurm.BuildJobTimeline(records) {
+		table.Append([]string{
+			e.Time.Format(time.RFC3339),
+			e.JobID,
+			e.Event,
+			e.Detail,
+		})
+	}
+	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
+	table.Render()
+
+	return nil
+}
+
+// JobMemory writes the memory usage of the running job per job step and per node to `w`.
+func (s *Slurm) JobMemory(ctx context.Context, w io.Writer, id string) error {
+
+	if _, err := s.jobInfo(ctx, id); err != nil {
+		return err
+	}
+
+	steps, err := slurm.GetStepMemory(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if len(steps) == 0 {
+		return fmt.Errorf("no running job step: %s", id)
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{
+		"step",
+		"tasks",
+		"nodes",
+		"current rss\n[gb]",
+		"average rss\n[gb]",
+		"peak rss\n[gb]",
+		"peak\n(node/task)",
+	})
+	for _, s := range steps {
+		table.Append([]string{
+			s.JobID,
+			fmt.Sprintf("%d", s.NumTasks),
+			s.NodeList,
+			fmt.Sprintf("%.2f", float64(s.CurrentRSSBytes)/gib),
+			fmt.Sprintf("%.2f", float64(s.AveRSSBytes)/gib),
+			fmt.Sprintf("%.2f", float64(s.MaxRSSBytes)/gib),
+			fmt.Sprintf("%s/%s", s.MaxRSSNode, s.MaxRSSTask),
+		})
+	}
+	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAlignment(tablewriter.ALIGN_RIGHT)
+	table.Render()
+
+	table = tablewriter.NewWriter(w)
+	table.SetHeader([]string{
+		"node",
+		"current rss\n[gb]",
+		"peak rss\n[gb]",
+	})
+	for _, n := range slurm.AggregateNodeMemory(steps) {
+		current := fmt.Sprintf("%.2f", float64(n.CurrentRSSBytes)/gib)
+		if n.CurrentRSSUnknown {
+			current = "unknown"
+		}
+		table.Append([]string{
+			n.Node,
+			current,
+			fmt.Sprintf("%.2f", float64(n.MaxRSSBytes)/gib),
+		})
+	}
+	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAlignment(tablewriter.ALIGN_RIGHT)
+	table.Render()
+
+	return nil
+}
+
+// Config writes the output of `scontrol show config` to `w`.
+func (s *Slurm) Config(ctx context.Context, w io.Writer) error {
+	out, err := slurm.GetConfig(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprint(w, out)
+	return err
+}
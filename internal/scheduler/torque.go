@@ -0,0 +1,249 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	trqhelper "github.com/Donders-Institute/hpc-torque-helper/pkg/client"
+	"github.com/Donders-Institute/hpc-utility/internal/hostlist"
+	"github.com/Donders-Institute/hpc-utility/internal/node"
+	"github.com/Donders-Institute/hpc-utility/internal/torque"
+	"github.com/Donders-Institute/hpc-utility/internal/util"
+	"github.com/Donders-Institute/hpc-utility/internal/vnc"
+	log "github.com/sirupsen/logrus"
+)
+
+// helperCall is the name of the calls of the Torque helper client for the timeout of
+// `util.CallContext`, e.g. `--timeouts trqhelper=30s`.
+const helperCall = "trqhelper"
+
+// torqueDialTimeout is the timeout of connecting to the Torque helper service in the
+// auto-detection of the Torque scheduler.
+const torqueDialTimeout = time.Second
+
+// reTorqueUnknownJob matches the errors of the Torque helper service for a job unknown to the
+// Torque server, e.g. `qstat: Unknown Job Id Error 123.dccn-l029.dccn.nl` of `qstat` and
+// `Couldn't find Job Id 123.dccn-l029.dccn.nl` of `tracejob`, or a job id not valid for Torque,
+// e.g. the Slurm array job `4321_7`.
+var reTorqueUnknownJob = regexp.MustCompile(`(?i)unknown job id|couldn't find job id|invalid job id`)
+
+func init() {
+	Register(node.ClusterTorque, 1, newTorque, func(opts Options) bool {
+		if opts.TorqueServerHost == "" {
+			return false
+		}
+		// the Torque client commands are not checked as they are also provided by Slurm,
+		// e.g. `qstat` of the Torque compatibility wrappers; instead, Torque is available
+		// if the Torque helper service on the server accepts connections.
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(opts.TorqueServerHost, strconv.Itoa(opts.TorqueHelperPort)), torqueDialTimeout)
+		if err != nil {
+			log.Debugf("torque not available: %s", err)
+			return false
+		}
+		conn.Close()
+		return true
+	})
+}
+
+// Torque implements the `Scheduler` interface for the Torque cluster via the Torque helper
+// service.
+type Torque struct {
+	srv trqhelper.TorqueHelperSrvClient
+	mom trqhelper.TorqueHelperMomClient
+}
+
+func newTorque(opts Options) Scheduler {
+	return &Torque{
+		srv: trqhelper.TorqueHelperSrvClient{
+			SrvHost:     opts.TorqueServerHost,
+			SrvPort:     opts.TorqueHelperPort,
+			SrvCertFile: opts.TorqueHelperCert,
+		},
+		mom: trqhelper.TorqueHelperMomClient{
+			SrvHost:     opts.TorqueServerHost,
+			SrvPort:     opts.TorqueHelperPort,
+			SrvCertFile: opts.TorqueHelperCert,
+		},
+	}
+}
+
+// Name returns the name of the Torque scheduler.
+func (t *Torque) Name() string {
+	return node.ClusterTorque
+}
+
+// ListNodes returns resource status of the Torque nodes.  The Torque helper client makes a
+// new connection to the helper service for every node.
+func (t *Torque) ListNodes(ctx context.Context, ids ...string) ([]node.Node, error) {
+
+	if len(ids) == 0 {
+		ids = []string{"ALL"}
+	}
+
+	nodes := []node.Node{}
+	for _, id := range ids {
+		rs, err := util.CallContext(ctx, helperCall, func() ([]trqhelper.NodeResourceStatus, error) {
+			return t.srv.GetNodeResourceStatus(id)
+		})
+		if err != nil {
+			return nodes, fmt.Errorf("%s: %w", t.srv.SrvHost, err)
+		}
+		for _, r := range rs {
+			if r.ID != "GLOBAL" {
+				nodes = append(nodes, node.FromTorque(r))
+			}
+		}
+	}
+	return nodes, nil
+}
+
+// getJobs returns the jobs in the memory of the Torque server.
+func (t *Torque) getJobs(ctx context.Context) ([]torque.Job, error) {
+	return util.CallContext(ctx, helperCall, func() ([]torque.Job, error) {
+		return torque.GetJobs(&t.srv)
+	})
+}
+
+// ListJobs returns jobs in the memory of the Torque server.
+func (t *Torque) ListJobs(ctx context.Context, users ...string) ([]Job, error) {
+
+	tjobs, err := t.getJobs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", t.srv.SrvHost, err)
+	}
+
+	jobs := make([]Job, 0, len(tjobs))
+	for _, j := range tjobs {
+		if len(users) > 0 && slices.Index(users, j.User()) < 0 {
+			continue
+		}
+		jobs = append(jobs, Job{
+			ID:        j.ID,
+			Cluster:   node.ClusterTorque,
+			Name:      j.Name,
+			User:      j.User(),
+			Queue:     j.Queue,
+			State:     j.StateName(),
+			NumProcs:  j.NumProcs(),
+			TimeUsed:  j.UsedWalltime,
+			TimeLimit: j.ReqWalltime,
+			Nodes:     j.Hosts(),
+		})
+	}
+	return jobs, nil
+}
+
+// JobInfo writes information of the job in the memory of the Torque server to `w`.
+func (t *Torque) JobInfo(ctx context.Context, w io.Writer, id string) error {
+
+	tjobs, err := t.getJobs(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", t.srv.SrvHost, err)
+	}
+
+	for _, j := range tjobs {
+		if j.ID != id && strings.Split(j.ID, ".")[0] != id {
+			continue
+		}
+		fmt.Fprintf(w, "\n%-s (torque)", j.ID)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Name", j.Name)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "User", j.User())
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Queue", j.Queue)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "State", j.StateName())
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Exit code", j.ExitStatus)
+		fmt.Fprintf(w, "\n\t%-16s: %-s/%-s", "Walltime", j.UsedWalltime, j.ReqWalltime)
+		fmt.Fprintf(w, "\n\t%-16s: %-s/%-s", "Memory", j.UsedMem, j.ReqMem)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Nodes", j.ReqNodes)
+		fmt.Fprintf(w, "\n\t%-16s: %-s", "Exec hosts", hostlist.Compress(j.Hosts()))
+		fmt.Fprintln(w)
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrUnknownJob, id)
+}
+
+// JobTrace writes the trace log of the job retrieved from the Torque server to `w`.  Only
+// the trace log recorded in the last 3 days is available; `since` and `until` are ignored.
+func (t *Torque) JobTrace(ctx context.Context, w io.Writer, id, since, until string) error {
+	return torqueJobError(id, printTo(ctx, w, func() error {
+		return t.srv.PrintClusterTracejob(id)
+	}))
+}
+
+// JobMemory writes the memory usage of the running job retrieved from the Torque helper
+// service on the job's execution host to `w`.
+func (t *Torque) JobMemory(ctx context.Context, w io.Writer, id string) error {
+	return torqueJobError(id, printTo(ctx, w, func() error {
+		return t.mom.PrintJobMemoryInfo(id)
+	}))
+}
+
+// Config writes the Torque and Moab server configurations to `w`.
+func (t *Torque) Config(ctx context.Context, w io.Writer) error {
+	return printTo(ctx, w, func() error {
+		return t.srv.PrintClusterConfig()
+	})
+}
+
+// PrintQueue writes the jobs in the memory of the Torque server to `w`, i.e. the output of
+// `qstat`, or of `qstat -x` if `xml` is true.
+func (t *Torque) PrintQueue(ctx context.Context, w io.Writer, xml bool) error {
+	return printTo(ctx, w, func() error {
+		return t.srv.PrintClusterQstat(xml)
+	})
+}
+
+// ListVNCServers returns the VNC servers running on the access node `host`, retrieved from
+// the Torque helper service on the node.  The start time and the activity of the servers are
+// not provided by the service.
+func (t *Torque) ListVNCServers(ctx context.Context, host string) ([]vnc.Server, error) {
+
+	c := trqhelper.TorqueHelperAccClient{
+		SrvHost:     host,
+		SrvPort:     t.srv.SrvPort,
+		SrvCertFile: t.srv.SrvCertFile,
+	}
+
+	servers, err := util.CallContext(ctx, helperCall, func() ([]trqhelper.VNCServer, error) {
+		return c.GetVNCServers()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", host, err)
+	}
+
+	_servers := make([]vnc.Server, 0, len(servers))
+	for _, s := range servers {
+		_servers = append(_servers, vnc.Server{ID: s.ID, Owner: s.Owner})
+	}
+	return _servers, nil
+}
+
+// torqueJobError wraps the error `err` of the Torque helper call on the job `id` with
+// `ErrUnknownJob` if the job is unknown to the Torque server; other errors are returned as
+// they are.
+func torqueJobError(id string, err error) error {
+	if err != nil && reTorqueUnknownJob.MatchString(err.Error()) {
+		return fmt.Errorf("%w: %s: %s", ErrUnknownJob, id, err)
+	}
+	return err
+}
+
+// printTo redirects the data printed on the stdout by the function `f` to `w`.  It returns
+// when `ctx` is done, without waiting for `f`.
+func printTo(ctx context.Context, w io.Writer, f func() error) error {
+	out, err := util.CallContext(ctx, helperCall, func() (bytes.Buffer, error) {
+		return util.CaptureStdout(f)
+	})
+	if _, werr := out.WriteTo(w); werr != nil && err == nil {
+		err = werr
+	}
+	return err
+}
@@ -0,0 +1,266 @@
+package slurm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Donders-Institute/hpc-utility/internal/util"
+)
+
+// sacctFields is the list of fields retrieved from `sacct`.
+var sacctFields = []string{
+	"JobID",
+	"JobIDRaw",
+	"JobName",
+	"User",
+	"Partition",
+	"State",
+	"ExitCode",
+	"Reason",
+	"Submit",
+	"Eligible",
+	"Start",
+	"End",
+	"Elapsed",
+	"NodeList",
+	"NNodes",
+	"AllocCPUS",
+	"TotalCPU",
+	"MaxRSS",
+	"ReqMem",
+	"ReqTRES",
+	"AllocTRES",
+}
+
+// AcctRecord defines the data structure of a job or job step accounting record in the
+// output of `sacct`.
+type AcctRecord struct {
+	// JobID is the job or job step ID, e.g. `4321_7`, `4321_7.batch` or `4321_7.0`.
+	JobID string
+	// JobIDRaw is the job or job step ID with the array task resolved, e.g. `4322.batch`.
+	JobIDRaw  string
+	Name      string
+	User      string
+	Partition string
+	State     string
+	ExitCode  string
+	Reason    string
+	Submit    time.Time
+	Eligible  time.Time
+	Start     time.Time
+	End       time.Time
+	Elapsed   string
+	NodeList  string
+	NumNodes  int
+	AllocCPUs int
+	// TotalCPU is the CPU time consumed by the job or the job step, e.g. `01:02:03.456`.
+	TotalCPU string
+	// MaxRSS is the maximum resident set size of all tasks in the job step, e.g. `1053236K`.
+	// It is only available for job steps.
+	MaxRSS string
+	// ReqMem is the requested memory, e.g. `16G`.  Older Slurm versions suffix the value
+	// with `n` (per node) or `c` (per CPU).
+	ReqMem    string
+	ReqTRES   map[string]string
+	AllocTRES map[string]string
+}
+
+// IsStep returns true if the record is a job step rather than the job allocation.
+func (r AcctRecord) IsStep() bool {
+	return strings.Contains(r.JobID, ".")
+}
+
+// parseParsable converts the `--parsable2` output of the Slurm accounting commands, i.e.
+// `sacct` and `sstat`, into rows of field-value maps using the header line.
+func parseParsable(out string) ([]map[string]string, error) {
+
+	rows := make([]map[string]string, 0)
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		return rows, nil
+	}
+
+	fields := strings.Split(lines[0], "|")
+
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		data := strings.Split(line, "|")
+		if len(data) != len(fields) {
+			return rows, fmt.Errorf("unexpected parsable output: %s", line)
+		}
+
+		row := make(map[string]string)
+		for i, f := range fields {
+			row[f] = data[i]
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// parseAcctRecords converts the `sacct --parsable2` output, with the header line, into
+// array of `AcctRecord`.  Columns are mapped to the `AcctRecord` attributes using the
+// header line.
+//
+// The expected `out` looks like the one below (only a subset of `sacctFields` is shown):
+//
+// ```
+// JobID|JobIDRaw|JobName|User|Partition|State|ExitCode|Reason|Submit|Eligible|Start|End|Elapsed|NodeList
+// 4321|4321|my job|user1|batch|REQUEUED|0:0|None|2024-11-20T13:02:11|2024-11-20T13:02:11|2024-11-20T13:02:12|2024-11-20T13:10:40|00:08:28|dccn-c083
+// 4321|4321|my job|user1|batch|COMPLETED|0:0|None|2024-11-20T13:10:40|2024-11-20T13:12:41|2024-11-20T13:12:42|2024-11-20T15:12:42|02:00:00|dccn-c084
+// 4321.batch|4321.batch|batch|||COMPLETED|0:0||2024-11-20T13:12:42|2024-11-20T13:12:42|2024-11-20T13:12:42|2024-11-20T15:12:42|02:00:00|dccn-c084
+// ```
+func parseAcctRecords(out string) ([]AcctRecord, error) {
+
+	records := make([]AcctRecord, 0)
+
+	rows, err := parseParsable(out)
+	if err != nil {
+		return records, err
+	}
+
+	for _, row := range rows {
+
+		value := func(f string) string {
+			return row[f]
+		}
+
+		intValue := func(f string) (int, error) {
+			if v := value(f); v != "" {
+				return strconv.Atoi(v)
+			}
+			return 0, nil
+		}
+
+		nnodes, err := intValue("NNodes")
+		if err != nil {
+			return records, fmt.Errorf("invalid number of nodes: %s", err)
+		}
+
+		ncpus, err := intValue("AllocCPUS")
+		if err != nil {
+			return records, fmt.Errorf("invalid number of allocated CPUs: %s", err)
+		}
+
+		records = append(records, AcctRecord{
+			JobID:     value("JobID"),
+			JobIDRaw:  value("JobIDRaw"),
+			Name:      value("JobName"),
+			User:      value("User"),
+			Partition: value("Partition"),
+			State:     value("State"),
+			ExitCode:  value("ExitCode"),
+			Reason:    value("Reason"),
+			Submit:    parseTime(value("Submit")),
+			Eligible:  parseTime(value("Eligible")),
+			Start:     parseTime(value("Start")),
+			End:       parseTime(value("End")),
+			Elapsed:   value("Elapsed"),
+			NodeList:  value("NodeList"),
+			NumNodes:  nnodes,
+			AllocCPUs: ncpus,
+			TotalCPU:  value("TotalCPU"),
+			MaxRSS:    value("MaxRSS"),
+			ReqMem:    value("ReqMem"),
+			ReqTRES:   parseTRES(value("ReqTRES")),
+			AllocTRES: parseTRES(value("AllocTRES")),
+		})
+	}
+
+	return records, nil
+}
+
+// GetAcctRecords makes a system call `sacct` and returns the accounting records of the
+// job `id` and its steps, including the records of the earlier (requeued) runs of the job.
+//
+// The `since` and `until` arguments are passed to the `--starttime` and `--endtime` options
+// of `sacct` respectively and can be in any format `sacct` accepts, e.g. `2024-11-01` or
+// `now-14days`.  They are ignored if empty.  The system call is terminated when `ctx` is done
+// or the timeout of `sacct` given by `util.DefaultExecutor` is reached.
+func GetAcctRecords(ctx context.Context, id, since, until string) ([]AcctRecord, error) {
+
+	args := []string{
+		"--parsable2",
+		"--duplicates",
+		fmt.Sprintf("--format=%s", strings.Join(sacctFields, ",")),
+		fmt.Sprintf("--jobs=%s", id),
+	}
+
+	if since != "" {
+		args = append(args, fmt.Sprintf("--starttime=%s", since))
+	}
+	if until != "" {
+		args = append(args, fmt.Sprintf("--endtime=%s", until))
+	}
+
+	stdout, err := util.ExecCmdContext(ctx, "sacct", args)
+	if err != nil {
+		return []AcctRecord{}, err
+	}
+
+	return parseAcctRecords(stdout.String())
+}
+
+// JobEvent defines an event in the lifecycle of a job.
+type JobEvent struct {
+	Time   time.Time
+	JobID  string
+	Event  string
+	Detail string
+}
+
+// BuildJobTimeline assembles the lifecycle events of a job from its accounting records.
+// The returned events are sorted by time.
+func BuildJobTimeline(records []AcctRecord) []JobEvent {
+
+	events := make([]JobEvent, 0)
+
+	// add appends an event only when the time is known
+	add := func(t time.Time, id, event, detail string) {
+		if t.IsZero() {
+			return
+		}
+		events = append(events, JobEvent{Time: t, JobID: id, Event: event, Detail: detail})
+	}
+
+	for _, r := range records {
+
+		if r.IsStep() {
+			add(r.Start, r.JobID, "STEP START", fmt.Sprintf("name=%s nodes=%s", r.Name, r.NodeList))
+			add(r.End, r.JobID, "STEP END", fmt.Sprintf("state=%s exit=%s", r.State, r.ExitCode))
+			continue
+		}
+
+		add(r.Submit, r.JobID, "SUBMIT", fmt.Sprintf("user=%s partition=%s", r.User, r.Partition))
+
+		if r.Start.IsZero() {
+			// the job is still pending
+			add(r.Eligible, r.JobID, "ELIGIBLE", fmt.Sprintf("pending reason=%s", r.Reason))
+			continue
+		}
+
+		add(r.Eligible, r.JobID, "ELIGIBLE", "")
+		add(r.Start, r.JobID, "START", fmt.Sprintf("nodes=%s", r.NodeList))
+
+		event := "END"
+		if strings.HasPrefix(r.State, "REQUEUED") {
+			event = "REQUEUE"
+		}
+		add(r.End, r.JobID, event, fmt.Sprintf("state=%s exit=%s elapsed=%s", r.State, r.ExitCode, r.Elapsed))
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+
+	return events
+}
@@ -0,0 +1,46 @@
+package slurm
+
+import (
+	"testing"
+)
+
+var (
+	sacctout = `JobID|JobIDRaw|JobName|User|Partition|State|ExitCode|Reason|Submit|Eligible|Start|End|Elapsed|NodeList
+4321|4321|my job|user1|batch|REQUEUED|0:0|None|2024-11-20T13:02:11|2024-11-20T13:02:11|2024-11-20T13:02:12|2024-11-20T13:10:40|00:08:28|dccn-c083
+4321|4321|my job|user1|batch|COMPLETED|0:0|None|2024-11-20T13:10:40|2024-11-20T13:12:41|2024-11-20T13:12:42|2024-11-20T15:12:42|02:00:00|dccn-c084
+4321.batch|4321.batch|batch|||COMPLETED|0:0||2024-11-20T13:12:42|2024-11-20T13:12:42|2024-11-20T13:12:42|2024-11-20T15:12:42|02:00:00|dccn-c084
+`
+)
+
+func TestParseAcctRecords(t *testing.T) {
+	records, err := parseAcctRecords(sacctout)
+	if err != nil {
+		t.Fatalf("%s\n", err)
+	}
+
+	if len(records) != 3 {
+		t.Fatalf("expect 3 records, got %d", len(records))
+	}
+
+	if !records[2].IsStep() || records[2].State != "COMPLETED" {
+		t.Errorf("unexpected step record: %+v", records[2])
+	}
+}
+
+func TestBuildJobTimeline(t *testing.T) {
+	records, _ := parseAcctRecords(sacctout)
+
+	events := BuildJobTimeline(records)
+	for _, e := range events {
+		t.Logf("%s %s %s %s", e.Time, e.JobID, e.Event, e.Detail)
+	}
+
+	// 2 x (submit, eligible, start, end) + (step start, step end)
+	if len(events) != 10 {
+		t.Fatalf("expect 10 events, got %d", len(events))
+	}
+
+	if events[3].Event != "REQUEUE" {
+		t.Errorf("expect the 4th event to be REQUEUE, got %s", events[3].Event)
+	}
+}
@@ -0,0 +1,21 @@
+package slurm
+
+import (
+	"context"
+
+	"github.com/Donders-Institute/hpc-utility/internal/util"
+)
+
+// GetConfig makes a system call `scontrol show config` and returns the output as it is.
+//
+// The system call is terminated when `ctx` is done or the timeout of `scontrol` given by
+// `util.DefaultExecutor` is reached.
+func GetConfig(ctx context.Context) (string, error) {
+
+	stdout, err := util.ExecCmdContext(ctx, "scontrol", []string{"show", "config"})
+	if err != nil {
+		return "", err
+	}
+
+	return stdout.String(), nil
+}
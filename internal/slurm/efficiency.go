@@ -0,0 +1,290 @@
+package slurm
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StepEfficiency defines the resource usage efficiency of a job step.
+type StepEfficiency struct {
+	JobID     string
+	Name      string
+	State     string
+	AllocCPUs int
+	Elapsed   time.Duration
+	TotalCPU  time.Duration
+	// CPUEfficiency is the ratio of `TotalCPU` to `Elapsed` x `AllocCPUs`.
+	CPUEfficiency float64
+	MaxRSSBytes   int64
+}
+
+// Efficiency defines the resource usage efficiency of a job.
+type Efficiency struct {
+	JobID string
+	// ArrayJobID is the ID of the job array, empty if the job is not an array task.
+	ArrayJobID string
+	State      string
+	AllocCPUs  int
+	Elapsed    time.Duration
+	TotalCPU   time.Duration
+	// CPUEfficiency is the ratio of `TotalCPU` to `Elapsed` x `AllocCPUs`.
+	CPUEfficiency float64
+	ReqMemBytes   int64
+	// MaxRSSBytes is the largest `MaxRSS` of all job steps.
+	MaxRSSBytes int64
+	// MemEfficiency is the ratio of `MaxRSSBytes` to `ReqMemBytes`.
+	MemEfficiency float64
+	ReqGPUs       int
+	AllocGPUs     int
+	Steps         []StepEfficiency
+}
+
+// ArrayEfficiency defines the aggregated resource usage efficiency of tasks in a job array.
+type ArrayEfficiency struct {
+	ArrayJobID string
+	NumTasks   int
+	// CPUEfficiency is the ratio of the summed `TotalCPU` to the summed `Elapsed` x `AllocCPUs`
+	// of all tasks.
+	CPUEfficiency float64
+	// MeanMemEfficiency and MaxMemEfficiency are the average and the largest memory
+	// efficiency of all tasks.
+	MeanMemEfficiency float64
+	MaxMemEfficiency  float64
+}
+
+// ParseDuration converts the Slurm time duration, e.g. `1-02:03:04`, `02:03:04` or
+// `03:04.567`, into `time.Duration`.
+func ParseDuration(s string) (time.Duration, error) {
+
+	var d time.Duration
+
+	if s == "" || s == "INVALID" {
+		return d, nil
+	}
+
+	days := 0
+	if data := strings.SplitN(s, "-", 2); len(data) == 2 {
+		var err error
+		if days, err = strconv.Atoi(data[0]); err != nil {
+			return d, fmt.Errorf("invalid duration: %s", s)
+		}
+		s = data[1]
+	}
+
+	// fields from the right-most one: seconds, minutes, hours
+	fields := strings.Split(s, ":")
+	if len(fields) > 3 {
+		return d, fmt.Errorf("invalid duration: %s", s)
+	}
+
+	units := []time.Duration{time.Second, time.Minute, time.Hour}
+	for i := 0; i < len(fields); i++ {
+		v, err := strconv.ParseFloat(fields[len(fields)-1-i], 64)
+		if err != nil {
+			return d, fmt.Errorf("invalid duration: %s", s)
+		}
+		d += time.Duration(v * float64(units[i]))
+	}
+
+	return d + time.Duration(days)*24*time.Hour, nil
+}
+
+// ParseMemBytes converts the Slurm memory size, e.g. `1053236K` or `16G`, into bytes.  A
+// value without unit is in bytes for `MaxRSS` and in megabytes for `ReqMem`; the unit of
+// it is given by the `defUnit` argument.
+//
+// It also returns the eventual `n` (per node) or `c` (per CPU) suffix of `ReqMem`.
+func ParseMemBytes(s string, defUnit string) (int64, string, error) {
+
+	reMem := regexp.MustCompile(`^([0-9.]+)([KMGTP]?)([nc]?)$`)
+
+	if s == "" {
+		return 0, "", nil
+	}
+
+	m := reMem.FindStringSubmatch(s)
+	if m == nil {
+		return 0, "", fmt.Errorf("invalid memory size: %s", s)
+	}
+
+	v, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid memory size: %s", s)
+	}
+
+	unit := m[2]
+	if unit == "" {
+		unit = defUnit
+	}
+
+	switch unit {
+	case "K":
+		v *= 1 << 10
+	case "M":
+		v *= 1 << 20
+	case "G":
+		v *= 1 << 30
+	case "T":
+		v *= 1 << 40
+	case "P":
+		v *= 1 << 50
+	}
+
+	return int64(v), m[3], nil
+}
+
+// ratio returns `a/b` or 0 if `b` is 0.
+func ratio(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
+
+// stepEfficiency computes the resource usage efficiency of a job step record.
+func stepEfficiency(r AcctRecord) (StepEfficiency, error) {
+
+	eff := StepEfficiency{
+		JobID:     r.JobID,
+		Name:      r.Name,
+		State:     r.State,
+		AllocCPUs: r.AllocCPUs,
+	}
+
+	var err error
+	if eff.Elapsed, err = ParseDuration(r.Elapsed); err != nil {
+		return eff, err
+	}
+	if eff.TotalCPU, err = ParseDuration(r.TotalCPU); err != nil {
+		return eff, err
+	}
+	if eff.MaxRSSBytes, _, err = ParseMemBytes(r.MaxRSS, ""); err != nil {
+		return eff, err
+	}
+
+	eff.CPUEfficiency = ratio(eff.TotalCPU.Seconds(), eff.Elapsed.Seconds()*float64(eff.AllocCPUs))
+
+	return eff, nil
+}
+
+// ComputeEfficiency computes the resource usage efficiency of jobs and their steps from the
+// accounting records.  When a job has multiple records because it was requeued, only the
+// last record of the job is taken into account.
+func ComputeEfficiency(records []AcctRecord) ([]Efficiency, error) {
+
+	effs := make([]Efficiency, 0)
+
+	// index of the job in `effs` by job id.
+	idx := make(map[string]int)
+
+	for _, r := range records {
+
+		if r.IsStep() {
+			i, ok := idx[strings.SplitN(r.JobID, ".", 2)[0]]
+			if !ok {
+				continue
+			}
+			s, err := stepEfficiency(r)
+			if err != nil {
+				return effs, err
+			}
+			effs[i].Steps = append(effs[i].Steps, s)
+			if s.MaxRSSBytes > effs[i].MaxRSSBytes {
+				effs[i].MaxRSSBytes = s.MaxRSSBytes
+			}
+			continue
+		}
+
+		eff := Efficiency{
+			JobID:     r.JobID,
+			State:     r.State,
+			AllocCPUs: r.AllocCPUs,
+		}
+
+		if data := strings.SplitN(r.JobID, "_", 2); len(data) == 2 {
+			eff.ArrayJobID = data[0]
+		}
+
+		var err error
+		if eff.Elapsed, err = ParseDuration(r.Elapsed); err != nil {
+			return effs, err
+		}
+		if eff.TotalCPU, err = ParseDuration(r.TotalCPU); err != nil {
+			return effs, err
+		}
+		eff.CPUEfficiency = ratio(eff.TotalCPU.Seconds(), eff.Elapsed.Seconds()*float64(eff.AllocCPUs))
+
+		mem, per, err := ParseMemBytes(r.ReqMem, "M")
+		if err != nil {
+			return effs, err
+		}
+		switch per {
+		case "c":
+			mem *= int64(r.AllocCPUs)
+		case "n":
+			if r.NumNodes > 0 {
+				mem *= int64(r.NumNodes)
+			}
+		}
+		eff.ReqMemBytes = mem
+
+		eff.ReqGPUs, _ = strconv.Atoi(r.ReqTRES["gres/gpu"])
+		eff.AllocGPUs, _ = strconv.Atoi(r.AllocTRES["gres/gpu"])
+
+		// the later record of a requeued job overwrites the earlier one.
+		if i, ok := idx[r.JobID]; ok {
+			effs[i] = eff
+			continue
+		}
+		idx[r.JobID] = len(effs)
+		effs = append(effs, eff)
+	}
+
+	for i := range effs {
+		effs[i].MemEfficiency = ratio(float64(effs[i].MaxRSSBytes), float64(effs[i].ReqMemBytes))
+	}
+
+	return effs, nil
+}
+
+// AggregateArrayEfficiency aggregates the efficiency of the array tasks by the job array.
+// Jobs not being part of a job array are ignored.
+func AggregateArrayEfficiency(effs []Efficiency) []ArrayEfficiency {
+
+	aggs := make([]ArrayEfficiency, 0)
+
+	idx := make(map[string]int)
+	cpuTime := make(map[string]float64)
+	coreTime := make(map[string]float64)
+
+	for _, e := range effs {
+		if e.ArrayJobID == "" {
+			continue
+		}
+
+		i, ok := idx[e.ArrayJobID]
+		if !ok {
+			i = len(aggs)
+			idx[e.ArrayJobID] = i
+			aggs = append(aggs, ArrayEfficiency{ArrayJobID: e.ArrayJobID})
+		}
+
+		aggs[i].NumTasks++
+		aggs[i].MeanMemEfficiency += e.MemEfficiency
+		if e.MemEfficiency > aggs[i].MaxMemEfficiency {
+			aggs[i].MaxMemEfficiency = e.MemEfficiency
+		}
+		cpuTime[e.ArrayJobID] += e.TotalCPU.Seconds()
+		coreTime[e.ArrayJobID] += e.Elapsed.Seconds() * float64(e.AllocCPUs)
+	}
+
+	for i, a := range aggs {
+		aggs[i].MeanMemEfficiency = ratio(a.MeanMemEfficiency, float64(a.NumTasks))
+		aggs[i].CPUEfficiency = ratio(cpuTime[a.ArrayJobID], coreTime[a.ArrayJobID])
+	}
+
+	return aggs
+}
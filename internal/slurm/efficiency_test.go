@@ -0,0 +1,67 @@
+package slurm
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	sacctEffOut = `JobID|JobIDRaw|State|Elapsed|NNodes|AllocCPUS|TotalCPU|MaxRSS|ReqMem|ReqTRES|AllocTRES
+4321_1|4322|COMPLETED|01:00:00|1|4|02:00:00||16G|cpu=4,mem=16G,node=1,gres/gpu=1|cpu=4,mem=16G,node=1,gres/gpu=1
+4321_1.batch|4322.batch|COMPLETED|01:00:00|1|4|02:00:00|8388608K|||cpu=4,mem=16G,node=1
+4321_2|4323|COMPLETED|01:00:00|1|4|04:00:00||4Gc|cpu=4,mem=16G,node=1|cpu=4,mem=16G,node=1
+4321_2.batch|4323.batch|COMPLETED|01:00:00|1|4|04:00:00|16777216K|||cpu=4,mem=16G,node=1
+`
+)
+
+func TestParseDuration(t *testing.T) {
+	cases := map[string]time.Duration{
+		"1-02:03:04": 26*time.Hour + 3*time.Minute + 4*time.Second,
+		"02:03:04":   2*time.Hour + 3*time.Minute + 4*time.Second,
+		"03:04.500":  3*time.Minute + 4500*time.Millisecond,
+		"":           0,
+	}
+	for s, expect := range cases {
+		d, err := ParseDuration(s)
+		if err != nil {
+			t.Fatalf("%s\n", err)
+		}
+		if d != expect {
+			t.Errorf("%s: expect %s, got %s", s, expect, d)
+		}
+	}
+}
+
+func TestComputeEfficiency(t *testing.T) {
+	records, err := parseAcctRecords(sacctEffOut)
+	if err != nil {
+		t.Fatalf("%s\n", err)
+	}
+
+	effs, err := ComputeEfficiency(records)
+	if err != nil {
+		t.Fatalf("%s\n", err)
+	}
+
+	if len(effs) != 2 {
+		t.Fatalf("expect 2 jobs, got %d", len(effs))
+	}
+
+	for _, e := range effs {
+		t.Logf("efficiency: %+v\n", e)
+	}
+
+	if e := effs[0]; e.CPUEfficiency != 0.5 || e.MemEfficiency != 0.5 || e.AllocGPUs != 1 || len(e.Steps) != 1 {
+		t.Errorf("unexpected efficiency: %+v", e)
+	}
+
+	// memory requested per CPU: 4G x 4 CPUs
+	if e := effs[1]; e.ReqMemBytes != 16<<30 || e.MemEfficiency != 1 {
+		t.Errorf("unexpected memory efficiency: %+v", e)
+	}
+
+	aggs := AggregateArrayEfficiency(effs)
+	if len(aggs) != 1 || aggs[0].NumTasks != 2 || aggs[0].CPUEfficiency != 0.75 || aggs[0].MaxMemEfficiency != 1 {
+		t.Errorf("unexpected array efficiency: %+v", aggs)
+	}
+}
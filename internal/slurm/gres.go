@@ -0,0 +1,252 @@
+package slurm
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Gres is a generic resource (GRES) of a Slurm node, e.g. the GPUs of a model.
+type Gres struct {
+	// Name is the name of the resource, e.g. `gpu`.
+	Name string
+	// Type is the type of the resource, e.g. `nvidia_a100-sxm4-40gb`.  It is empty if the
+	// resource is configured without a type.
+	Type string
+	// Count is the configured amount of the resource.  A size suffix (K, M, G, T or P) is
+	// applied as a multiplier of 1024, e.g. `tmp:3500G` is given in bytes.
+	Count int64
+	// Used is the amount of the resource in use.
+	Used int64
+	// Indices are the indices of the resource units in use, e.g. the GPU indices.
+	Indices []int
+	// Sockets are the CPU sockets the resource is affine to.
+	Sockets []int
+}
+
+// gresSizeSuffix are the multipliers of the size suffixes of a GRES count.
+var gresSizeSuffix = map[byte]int64{
+	'K': 1 << 10,
+	'M': 1 << 20,
+	'G': 1 << 30,
+	'T': 1 << 40,
+	'P': 1 << 50,
+}
+
+// ParseGres parses the `Gres` and `GresUsed` attributes of `scontrol show node --detail` into
+// typed entries.  The attributes look like the ones below:
+//
+// ```
+// Gres=cpu:amd:1,gpu:nvidia_a100-sxm4-40gb:4(S:0-1),tmp:3500G,network:10G
+// GresUsed=cpu:amd:0,gpu:nvidia_a100-sxm4-40gb:2(IDX:0,2),tmp:1073741824000,network:0
+// ```
+//
+// The usage in `used` is matched to the configured resource by the name and type; resources
+// only found in `used`, e.g. `license:0`, are ignored.  An empty `used` results in no usage.
+func ParseGres(gres, used string) ([]Gres, error) {
+
+	entries := []Gres{}
+
+	if gres == "" || gres == "(null)" {
+		return entries, nil
+	}
+
+	for _, spec := range splitGres(gres) {
+		g, err := parseGresSpec(spec)
+		if err != nil {
+			return entries, fmt.Errorf("invalid Gres %s: %s", spec, err)
+		}
+		entries = append(entries, g)
+	}
+
+	if used == "" || used == "(null)" {
+		return entries, nil
+	}
+
+	for _, spec := range splitGres(used) {
+		u, err := parseGresSpec(spec)
+		if err != nil {
+			return entries, fmt.Errorf("invalid GresUsed %s: %s", spec, err)
+		}
+		for i := range entries {
+			if entries[i].Name == u.Name && entries[i].Type == u.Type {
+				entries[i].Used = u.Count
+				entries[i].Indices = u.Indices
+				break
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// splitGres splits the GRES specifications by the commas outside parentheses.
+func splitGres(s string) []string {
+
+	specs := []string{}
+
+	depth := 0
+	start := 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				specs = append(specs, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	specs = append(specs, s[start:])
+
+	nonEmpty := specs[:0]
+	for _, spec := range specs {
+		if spec = strings.TrimSpace(spec); spec != "" {
+			nonEmpty = append(nonEmpty, spec)
+		}
+	}
+
+	return nonEmpty
+}
+
+// parseGresSpec parses a single GRES specification, e.g. `gpu:nvidia_a100-sxm4-40gb:4(S:0-1)`
+// or `gpu:nvidia_a100-sxm4-40gb:2(IDX:0,2)`.  The index list in the `IDX` detail is set to
+// `Indices`; the socket list in the `S` detail is set to `Sockets`.
+func parseGresSpec(spec string) (Gres, error) {
+
+	g := Gres{}
+
+	// the details in parentheses
+	details := ""
+	if i := strings.Index(spec, "("); i >= 0 {
+		if !strings.HasSuffix(spec, ")") {
+			return g, fmt.Errorf("unbalanced parentheses")
+		}
+		spec, details = spec[:i], spec[i+1:len(spec)-1]
+	}
+
+	fields := strings.Split(spec, ":")
+
+	var count string
+	switch len(fields) {
+	case 1:
+		// resource without count, e.g. `gpu`, counts one unit
+		g.Name, count = fields[0], "1"
+	case 2:
+		g.Name, count = fields[0], fields[1]
+	default:
+		g.Name = fields[0]
+		g.Type = strings.Join(fields[1:len(fields)-1], ":")
+		count = fields[len(fields)-1]
+	}
+
+	if g.Name == "" {
+		return g, fmt.Errorf("empty name")
+	}
+
+	var err error
+	if g.Count, err = parseGresCount(count); err != nil {
+		return g, err
+	}
+
+	if details == "" {
+		return g, nil
+	}
+
+	for key, value := range gresDetails(details) {
+		switch key {
+		case "IDX":
+			if g.Indices, err = parseIndexList(value); err != nil {
+				return g, err
+			}
+		case "S":
+			if g.Sockets, err = parseIndexList(value); err != nil {
+				return g, err
+			}
+		}
+	}
+
+	return g, nil
+}
+
+// gresDetails parses the details of a GRES specification, e.g. `S:0-1` or `IDX:0,2-3`,
+// into key-value pairs.  A comma separates two details only if it is followed by a key.
+func gresDetails(details string) map[string]string {
+
+	kvs := make(map[string]string)
+
+	key := ""
+	for _, part := range strings.Split(details, ",") {
+		if kv := strings.SplitN(part, ":", 2); len(kv) == 2 {
+			key = kv[0]
+			kvs[key] = kv[1]
+			continue
+		}
+		if key != "" {
+			kvs[key] = kvs[key] + "," + part
+		}
+	}
+
+	return kvs
+}
+
+// parseGresCount parses the count of a GRES specification with an optional size suffix,
+// e.g. `4` or `3500G`.
+func parseGresCount(s string) (int64, error) {
+
+	if s == "" {
+		return 0, fmt.Errorf("empty count")
+	}
+
+	multiplier := int64(1)
+	if m, ok := gresSizeSuffix[strings.ToUpper(s)[len(s)-1]]; ok {
+		multiplier = m
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid count: %s", s)
+	}
+
+	return n * multiplier, nil
+}
+
+// parseIndexList parses a list of indices, e.g. `0,2-3`, into sorted indices.  `N/A`
+// results in an empty list.
+func parseIndexList(s string) ([]int, error) {
+
+	indices := []int{}
+
+	if s == "" || s == "N/A" {
+		return indices, nil
+	}
+
+	for _, r := range strings.Split(s, ",") {
+		bounds := strings.SplitN(r, "-", 2)
+
+		lo, err := strconv.Atoi(bounds[0])
+		if err != nil || lo < 0 {
+			return nil, fmt.Errorf("invalid index range: %s", r)
+		}
+
+		hi := lo
+		if len(bounds) == 2 {
+			if hi, err = strconv.Atoi(bounds[1]); err != nil || hi < lo {
+				return nil, fmt.Errorf("invalid index range: %s", r)
+			}
+		}
+
+		for i := lo; i <= hi; i++ {
+			indices = append(indices, i)
+		}
+	}
+
+	sort.Ints(indices)
+
+	return indices, nil
+}
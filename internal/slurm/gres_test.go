@@ -0,0 +1,61 @@
+package slurm
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseGres(t *testing.T) {
+
+	gres := "cpu:amd:1,gpu:nvidia_a100-sxm4-40gb:2(S:0),gpu:nvidia_v100:2(S:1),tmp:3500G,network:10G"
+	used := "cpu:amd:0,gpu:nvidia_a100-sxm4-40gb:2(IDX:0-1),gpu:nvidia_v100:1(IDX:3),license:0,tmp:1073741824000,network:0"
+
+	entries, err := ParseGres(gres, used)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	t.Logf("%+v", entries)
+
+	expect := []string{
+		"cpu:amd:1/0:[]:[]",
+		"gpu:nvidia_a100-sxm4-40gb:2/2:[0 1]:[0]",
+		"gpu:nvidia_v100:2/1:[3]:[1]",
+		fmt.Sprintf("tmp::%d/%d:[]:[]", int64(3500)<<30, int64(1000)<<30),
+		fmt.Sprintf("network::%d/0:[]:[]", int64(10)<<30),
+	}
+	if len(entries) != len(expect) {
+		t.Fatalf("expect %d entries, got %d", len(expect), len(entries))
+	}
+	for i, g := range entries {
+		s := fmt.Sprintf("%s:%s:%d/%d:%v:%v", g.Name, g.Type, g.Count, g.Used, fmtInts(g.Indices), fmtInts(g.Sockets))
+		if s != expect[i] {
+			t.Errorf("expect %s, got %s", expect[i], s)
+		}
+	}
+}
+
+func TestParseGresWithoutUsage(t *testing.T) {
+
+	entries, err := ParseGres("gpu:4,tmp:200G", "")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if len(entries) != 2 || entries[0].Name != "gpu" || entries[0].Type != "" || entries[0].Count != 4 || entries[0].Used != 0 {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+
+	if entries, err := ParseGres("(null)", ""); err != nil || len(entries) != 0 {
+		t.Errorf("unexpected entries of (null): %+v, %v", entries, err)
+	}
+
+	for _, gres := range []string{"gpu:a100:x", "gpu:a100:4(S:0-1", "gpu:a100:4(S:1-0)", ":4"} {
+		if _, err := ParseGres(gres, ""); err == nil {
+			t.Errorf("expect error for %s", gres)
+		}
+	}
+}
+
+// fmtInts formats the integers in the same way for a nil and an empty slice.
+func fmtInts(l []int) string {
+	return fmt.Sprintf("%v", append([]int{}, l...))
+}
@@ -1,8 +1,11 @@
 package slurm
 
 import (
+	"context"
 	"strings"
 	"testing"
+
+	"github.com/Donders-Institute/hpc-utility/internal/util"
 )
 
 var (
@@ -58,6 +61,62 @@ func TestParseSingleNodeInfo(t *testing.T) {
 	}
 }
 
+func TestParseSingleNodeInfoAttributes(t *testing.T) {
+	info, err := parseSingleNodeInfo(nodeinfo[0])
+	if err != nil {
+		t.Fatalf("%s\n", err)
+	}
+
+	if info.Cluster != "slurm" || info.CPUVendor != "AMD" || info.CPULoad != 3.0 || info.FreeMemGB != 78 {
+		t.Errorf("unexpected node info: %+v", info)
+	}
+
+	if info.GPUModel != "nvidia_a100-sxm4-40gb" || info.AvailGPUS != 3 || info.TotalGPUS != 4 {
+		t.Errorf("unexpected GPU info: %+v", info)
+	}
+
+	if g := info.GPUs; len(g) != 1 || g[0].Model != "nvidia_a100-sxm4-40gb" || g[0].Avail != 3 || len(g[0].Sockets) != 2 {
+		t.Errorf("unexpected GPUs by model: %+v", g)
+	}
+
+	if len(info.Partitions) != 2 || len(info.Features) != 0 || info.BootTime.IsZero() {
+		t.Errorf("unexpected node attributes: %+v", info)
+	}
+}
+
+func TestParseSingleNodeInfoState(t *testing.T) {
+
+	out := strings.Replace(nodeinfo[1], "State=IDLE", "State=IDLE+DRAIN+MAINTENANCE", 1)
+	out = strings.Replace(out, "   CapWatts=", "   Reason=disk failure [root@2024-11-18T10:05:12]\n   CapWatts=", 1)
+
+	info, err := parseSingleNodeInfo(out)
+	if err != nil {
+		t.Fatalf("%s\n", err)
+	}
+
+	if info.State != "IDLE" || len(info.StateFlags) != 2 || info.FullState() != "IDLE+DRAIN+MAINTENANCE" {
+		t.Errorf("unexpected state: %s %+v", info.State, info.StateFlags)
+	}
+
+	if info.Reason != "disk failure" || info.ReasonUser != "root" || info.ReasonTime.Day() != 18 || info.LastBusyTime.IsZero() {
+		t.Errorf("unexpected reason: %+v", info)
+	}
+}
+
+func TestParseSingleNodeInfoGresUsed(t *testing.T) {
+
+	info, err := parseSingleNodeInfo(strings.Replace(nodeinfo[0], "   NodeAddr=",
+		"   GresUsed=cpu:amd:0,gpu:nvidia_a100-sxm4-40gb:2(IDX:0,2),tmp:0\n   NodeAddr=", 1))
+	if err != nil {
+		t.Fatalf("%s\n", err)
+	}
+
+	// the GPU usage per model is taken from `GresUsed` rather than `AllocTRES`
+	if g := info.GPUs; len(g) != 1 || g[0].Avail != 2 || len(g[0].UsedIndices) != 2 || g[0].UsedIndices[1] != 2 {
+		t.Errorf("unexpected GPUs by model: %+v", g)
+	}
+}
+
 func TestParseMultipleNodeInfo(t *testing.T) {
 
 	for _, node := range parseMultipleNodeInfo(strings.Join(nodeinfo, "\n")) {
@@ -67,12 +126,21 @@ func TestParseMultipleNodeInfo(t *testing.T) {
 }
 
 func TestGetNodeInfo(t *testing.T) {
-	nodes, err := GetNodeInfo("ALL")
+
+	// replay the recorded `scontrol` outputs
+	defer func(r util.Runner) { util.DefaultRunner = r }(util.DefaultRunner)
+	util.DefaultRunner = &util.ReplayRunner{Dir: "../../testdata/exec"}
+
+	nodes, err := GetNodeInfo(context.Background(), "ALL")
 
 	if err != nil {
 		t.Fatalf("%s\n", err)
 	}
 
+	if len(nodes) != 3 {
+		t.Errorf("expect 3 nodes, got %d", len(nodes))
+	}
+
 	for _, node := range nodes {
 		t.Logf("node info: %+v\n", node)
 	}
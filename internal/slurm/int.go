@@ -1,19 +1,20 @@
 package slurm
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"regexp"
 	"strconv"
 	"strings"
 
-	trqhelper "github.com/Donders-Institute/hpc-torque-helper/pkg/client"
+	"github.com/Donders-Institute/hpc-utility/internal/node"
 	"github.com/Donders-Institute/hpc-utility/internal/util"
 	log "github.com/sirupsen/logrus"
 )
 
 // parseSingleNodeInfo converts the output of `scontrol show node <node>` into
-// the `trqhelper.NodeResourceStatus` data structure.
+// the `node.Node` data structure.
 //
 // The expected `out` looks like the one below:
 //
@@ -40,153 +41,161 @@ import (
 //		ExtSensorsJoules=n/s ExtSensorsWatts=0 ExtSensorsTemp=n/s
 //
 // ```
-func parseSingleNodeInfo(out string) (trqhelper.NodeResourceStatus, error) {
+func parseSingleNodeInfo(out string) (node.Node, error) {
 
-	info := trqhelper.NodeResourceStatus{}
+	info := node.Node{Cluster: node.ClusterSlurm}
 
-	cpuAllocated := 0
-	memAllocated := 0
-	gpuAllocated := 0
-	tmpAllocated := 0
+	kvs := parseKeyValues(out)
+
+	info.ID = strings.Split(kvs["NodeName"], ".")[0]
+	if info.ID == "" {
+		return info, fmt.Errorf("invalid node: ID is empty")
+	}
+
+	// atoi converts the integer value of the key `k`; values like `N/A` result in 0.
+	atoi := func(k string) (int, error) {
+		v, ok := kvs[k]
+		if !ok || v == "N/A" {
+			return 0, nil
+		}
+		return strconv.Atoi(v)
+	}
+
+	// list splits the comma-separated value of the key `k`; `(null)` results in an empty list.
+	list := func(k string) []string {
+		v, ok := kvs[k]
+		if !ok || v == "" || v == "(null)" {
+			return []string{}
+		}
+		return strings.Split(v, ",")
+	}
 
 	var err error
 
-	for _, field := range strings.Fields(out) {
-		if keyValue := strings.SplitN(field, "=", 2); len(keyValue) == 2 {
-			switch keyValue[0] {
-			case "NodeName":
-				info.ID = strings.Split(keyValue[1], ".")[0]
-			case "CPUEfctv":
-				nproc, err := strconv.Atoi(keyValue[1])
-				if err != nil {
-					log.Errorf("invalid number of total CPUs: %s", err)
-					return info, err
-				}
-				info.TotalProcs = nproc
-			case "CPUAlloc":
-				if cpuAllocated, err = strconv.Atoi(keyValue[1]); err != nil {
-					log.Errorf("invalid number of allocated CPUs: %s", err)
-					return info, err
-				}
-			case "RealMemory":
-				memMB, err := strconv.Atoi(keyValue[1])
-				if err != nil {
-					log.Errorf("invalid number of total memory: %s", err)
-					return info, err
-				}
-				info.TotalMemGB = memMB / 1024
-			case "AllocMem":
-				if memAllocated, err = strconv.Atoi(keyValue[1]); err != nil {
-					log.Errorf("invalid number of total memory: %s", err)
-					return info, err
-				}
-			case "Partitions":
-				info.Features = append(info.Features, strings.Split(keyValue[1], ",")...)
+	if info.TotalProcs, err = atoi("CPUEfctv"); err != nil {
+		log.Errorf("invalid number of total CPUs: %s", err)
+		return info, err
+	}
 
-			case "State":
-				info.State = strings.Split(keyValue[1], "+")[0]
+	cpuAllocated, err := atoi("CPUAlloc")
+	if err != nil {
+		log.Errorf("invalid number of allocated CPUs: %s", err)
+		return info, err
+	}
 
-			case "Gres":
-				reCpu := regexp.MustCompile(`cpu:(amd|intel):[0-9]+`)
-				reNet := regexp.MustCompile(`network:([0-9]+)G`)
-				reTmp := regexp.MustCompile(`tmp:([0-9]+)G`)
+	if v, ok := kvs["CPULoad"]; ok && v != "N/A" {
+		if info.CPULoad, err = strconv.ParseFloat(v, 64); err != nil {
+			log.Errorf("invalid CPU load: %s", err)
+			return info, err
+		}
+	}
 
-				// CPU manufacturer
-				cpuinfo := reCpu.FindStringSubmatch(keyValue[1])
-				if len(cpuinfo) < 2 {
-					return info, fmt.Errorf("unexpected CPU information: %s", keyValue[1])
-				}
-				switch cpuinfo[1] {
-				case "amd":
-					info.IsAMD = true
-					info.IsIntel = false
-				case "intel":
-					info.IsIntel = true
-					info.IsAMD = false
-				}
+	memMB, err := atoi("RealMemory")
+	if err != nil {
+		log.Errorf("invalid number of total memory: %s", err)
+		return info, err
+	}
+	info.TotalMemGB = memMB / 1024
 
-				// network bandwidth
-				netinfo := reNet.FindStringSubmatch(keyValue[1])
-				if len(netinfo) < 2 {
-					return info, fmt.Errorf("unexpected network information: %s", keyValue[1])
-				}
-				netGb, err := strconv.Atoi(netinfo[1])
-				if err != nil {
-					log.Errorf("invalid number of network bandwidth GB: %s", err)
-					return info, err
-				}
-				info.NetworkGbps = netGb
+	memAllocated, err := atoi("AllocMem")
+	if err != nil {
+		log.Errorf("invalid number of allocated memory: %s", err)
+		return info, err
+	}
 
-				// tmp disk size
-				tmpinfo := reTmp.FindStringSubmatch(keyValue[1])
-				if len(tmpinfo) < 2 {
-					return info, fmt.Errorf("unexpected tmpdir size information: %s", keyValue[1])
-				}
-				tmpGb, err := strconv.Atoi(tmpinfo[1])
-				if err != nil {
-					log.Errorf("invalid number of tmpdir size GB: %s", err)
-					return info, err
-				}
-				info.TotalDiskGB = tmpGb
+	memFree, err := atoi("FreeMem")
+	if err != nil {
+		log.Errorf("invalid number of free memory: %s", err)
+		return info, err
+	}
+	info.FreeMemGB = memFree / 1024
+
+	info.Partitions = list("Partitions")
+	info.Features = list("AvailableFeatures")
+	info.ActiveFeatures = list("ActiveFeatures")
+	info.BootTime = parseTime(kvs["BootTime"])
+	info.LastBusyTime = parseTime(kvs["LastBusyTime"])
+
+	// the state with flags, e.g. `IDLE+DRAIN`
+	states := strings.Split(kvs["State"], "+")
+	info.State, info.StateFlags = states[0], states[1:]
+
+	// the reason with the user and time it is set, e.g. `memory test [root@2024-11-18T10:05:12]`
+	info.Reason = kvs["Reason"]
+	reReason := regexp.MustCompile(`^(.*?)\s*\[([^@\]]+)@([^\]]+)\]$`)
+	if m := reReason.FindStringSubmatch(info.Reason); len(m) == 4 {
+		info.Reason, info.ReasonUser, info.ReasonTime = m[1], m[2], parseTime(m[3])
+	}
 
-			case "GresUsed":
+	// resources provided as generic resources, e.g.
+	// `cpu:amd:1,gpu:nvidia_a100-sxm4-40gb:4(S:0-1),tmp:3500G,network:10G`.
+	// They are optional as not every node has them configured.
+	gres, err := ParseGres(kvs["Gres"], kvs["GresUsed"])
+	if err != nil {
+		log.Errorf("invalid generic resources: %s", err)
+		return info, err
+	}
 
-				// used tmp disk size
-				reTmp := regexp.MustCompile(`tmp:([0-9]+)`)
-				tmpinfo := reTmp.FindStringSubmatch(keyValue[1])
-				if len(tmpinfo) < 2 {
-					return info, fmt.Errorf("unexpected tmpdir usage information: %s", keyValue[1])
-				}
-				tmpBytes, err := strconv.Atoi(tmpinfo[1])
+	tmpAllocated := 0
+	for _, g := range gres {
+		switch g.Name {
+		case "cpu":
+			// CPU manufacturer
+			info.CPUVendor = strings.ToUpper(g.Type)
+		case "network":
+			// network bandwidth in Gbps
+			info.NetworkGbps = int(g.Count >> 30)
+		case "tmp":
+			// tmp disk size, the usage is given in bytes
+			info.TotalDiskGB = int(g.Count >> 30)
+			tmpAllocated = int(g.Used >> 30)
+		case "gpu":
+			gpu := node.GPU{
+				Model:       g.Type,
+				Total:       int(g.Count),
+				Avail:       int(g.Count - g.Used),
+				UsedIndices: g.Indices,
+				Sockets:     g.Sockets,
+			}
+			// without `GresUsed`, the GPU usage is resolved from the allocated TRES, e.g.
+			// `gres/gpu:nvidia_a100-sxm4-40gb=1`.
+			if _, ok := kvs["GresUsed"]; !ok {
+				used, err := gpuTRES(kvs["AllocTRES"], g.Type)
 				if err != nil {
-					log.Errorf("invalid number of tmpdir usage GB: %s", err)
+					log.Errorf("invalid number of allocated GPUs: %s", err)
 					return info, err
 				}
+				gpu.Avail = gpu.Total - used
+			}
+			info.GPUs = append(info.GPUs, gpu)
+		}
+	}
 
-				// convert to GB
-				tmpAllocated = tmpBytes >> 30
-
-			case "CfgTRES":
-				reGpu := regexp.MustCompile(`gres/gpu=([0-9]+)`)
-				gpuinfo := reGpu.FindStringSubmatch(keyValue[1])
-				if len(gpuinfo) == 2 {
-					ngpus, err := strconv.Atoi(gpuinfo[1])
-					if err != nil {
-						log.Errorf("invalid number of total GPUs: %s", err)
-						return info, err
-					}
-					info.TotalGPUS = ngpus
-				}
-
-			case "AllocTRES":
-				reGpu := regexp.MustCompile(`gres/gpu=([0-9]+)`)
-				gpuinfo := reGpu.FindStringSubmatch(keyValue[1])
-				if len(gpuinfo) == 2 {
-					if gpuAllocated, err = strconv.Atoi(gpuinfo[1]); err != nil {
-						log.Errorf("invalid number of allocated GPUs: %s", err)
-						return info, err
-					}
-				}
-
-			case "TmpDisk":
-				// get total tmpdir size if it is not resolved from Gres
-				if info.TotalDiskGB == 0 {
-					diskMB, err := strconv.Atoi(keyValue[1])
-					if err != nil {
-						log.Errorf("invalid number of tmp disk size: %s", err)
-						return info, err
-					}
-					info.TotalDiskGB = diskMB / 1024
-				}
+	// GPU model
+	if len(info.GPUs) > 0 {
+		info.GPUModel = info.GPUs[0].Model
+	}
 
-			default:
-				// do nothing
-			}
+	// get total tmpdir size if it is not resolved from Gres
+	if info.TotalDiskGB == 0 {
+		diskMB, err := atoi("TmpDisk")
+		if err != nil {
+			log.Errorf("invalid number of tmp disk size: %s", err)
+			return info, err
 		}
+		info.TotalDiskGB = diskMB / 1024
 	}
 
-	if info.ID == "" {
-		return info, fmt.Errorf("invalid node: ID is empty")
+	// total and allocated GPUs
+	if info.TotalGPUS, err = gpuTRES(kvs["CfgTRES"], ""); err != nil {
+		log.Errorf("invalid number of total GPUs: %s", err)
+		return info, err
+	}
+
+	gpuAllocated, err := gpuTRES(kvs["AllocTRES"], "")
+	if err != nil {
+		log.Errorf("invalid number of allocated GPUs: %s", err)
+		return info, err
 	}
 
 	info.AvailProcs = info.TotalProcs - cpuAllocated
@@ -198,9 +207,26 @@ func parseSingleNodeInfo(out string) (trqhelper.NodeResourceStatus, error) {
 
 }
 
-func parseMultipleNodeInfo(out string) []trqhelper.NodeResourceStatus {
+// gpuTRES returns the number of GPUs of the GPU type `gpuType` in the trackable resources
+// `tres`, e.g. `cpu=2,mem=104G,gres/gpu=1,gres/gpu:nvidia_a100-sxm4-40gb=1`.  An empty
+// `gpuType` refers to the GPUs of all types.
+func gpuTRES(tres, gpuType string) (int, error) {
 
-	nodes := make([]trqhelper.NodeResourceStatus, 0)
+	key := "gres/gpu"
+	if gpuType != "" {
+		key = fmt.Sprintf("gres/gpu:%s", gpuType)
+	}
+
+	re := regexp.MustCompile(fmt.Sprintf(`(?:^|,)%s=([0-9]+)`, regexp.QuoteMeta(key)))
+	if m := re.FindStringSubmatch(tres); len(m) == 2 {
+		return strconv.Atoi(m[1])
+	}
+	return 0, nil
+}
+
+func parseMultipleNodeInfo(out string) []node.Node {
+
+	nodes := make([]node.Node, 0)
 
 	for _, nodeinfo := range strings.Split(out, "NodeName=") {
 
@@ -208,23 +234,26 @@ func parseMultipleNodeInfo(out string) []trqhelper.NodeResourceStatus {
 			continue
 		}
 
-		node, err := parseSingleNodeInfo(fmt.Sprintf("NodeName=%s", nodeinfo))
+		n, err := parseSingleNodeInfo(fmt.Sprintf("NodeName=%s", nodeinfo))
 		if err != nil {
 			log.Errorf("%s", err)
 			continue
 		}
-		nodes = append(nodes, node)
+		nodes = append(nodes, n)
 	}
 
 	return nodes
 }
 
 // GetNodeInfo makes a system call `scontrol show node` and parse the
-// output into array of `trqhelper.NodeResourceStatus`.
+// output into array of `node.Node`.
 //
 // If the given argument `id` is a empty string `""“ or `"ALL"`, it will
 // get information of all Slurm nodes.
-func GetNodeInfo(id string) ([]trqhelper.NodeResourceStatus, error) {
+//
+// The system call is terminated when `ctx` is done or the timeout of `scontrol` given by
+// `util.DefaultExecutor` is reached.
+func GetNodeInfo(ctx context.Context, id string) ([]node.Node, error) {
 
 	args := []string{"show", "node", "--detail"}
 
@@ -232,15 +261,11 @@ func GetNodeInfo(id string) ([]trqhelper.NodeResourceStatus, error) {
 		args = append(args, id)
 	}
 
-	nodes := make([]trqhelper.NodeResourceStatus, 0)
-
-	stdout, stderr, ec, err := util.ExecCmd("scontrol", args)
+	nodes := make([]node.Node, 0)
 
+	stdout, err := util.ExecCmdContext(ctx, "scontrol", args)
 	if err != nil {
-		return nodes, fmt.Errorf("%s: exit code %d", err, ec)
-	}
-	if ec != 0 {
-		return nodes, fmt.Errorf("%s", stderr.String())
+		return nodes, err
 	}
 
 	nodeInfo := ""
@@ -250,12 +275,12 @@ func GetNodeInfo(id string) ([]trqhelper.NodeResourceStatus, error) {
 
 		if err == io.EOF {
 			if nodeInfo != "" {
-				node, err := parseSingleNodeInfo(nodeInfo)
+				n, err := parseSingleNodeInfo(nodeInfo)
 
 				if err != nil {
 					log.Errorf("%s", err)
 				}
-				nodes = append(nodes, node)
+				nodes = append(nodes, n)
 			}
 			break
 		}
@@ -266,11 +291,11 @@ func GetNodeInfo(id string) ([]trqhelper.NodeResourceStatus, error) {
 		}
 
 		if strings.HasPrefix(line, "NodeName=") && nodeInfo != "" {
-			node, err := parseSingleNodeInfo(nodeInfo)
+			n, err := parseSingleNodeInfo(nodeInfo)
 			if err != nil {
 				log.Errorf("%s", err)
 			}
-			nodes = append(nodes, node)
+			nodes = append(nodes, n)
 
 			// reset nodeInfo
 			nodeInfo = line
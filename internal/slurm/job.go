@@ -0,0 +1,365 @@
+package slurm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Donders-Institute/hpc-utility/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// timeLayout is the layout of the timestamps in the output of Slurm commands.
+const timeLayout = "2006-01-02T15:04:05"
+
+// squeueFormat is the output format given to `squeue`.  Fields are separated by `|`; the
+// job name is put at the end as it is the only field that may contain the separator.
+const squeueFormat = "%i|%u|%a|%P|%T|%r|%C|%D|%m|%l|%M|%V|%S|%N|%j"
+
+// Job defines the data structure of a Slurm job in the output of `squeue`.
+type Job struct {
+	ID         string
+	Name       string
+	User       string
+	Account    string
+	Partition  string
+	State      string
+	Reason     string
+	NumCPUs    int
+	NumNodes   int
+	MinMemory  string
+	TimeLimit  string
+	TimeUsed   string
+	SubmitTime time.Time
+	StartTime  time.Time
+	NodeList   string
+}
+
+// parseTime converts the timestamp in the Slurm output into `time.Time`.  Values like `N/A`,
+// `Unknown` or `None` result in a zero time.
+func parseTime(s string) time.Time {
+	t, err := time.ParseInLocation(timeLayout, s, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// parseSingleJobLine converts one line of the `squeue` output in `squeueFormat` into
+// the `Job` data structure.
+//
+// The expected `line` looks like the one below:
+//
+// ```
+// 4321_7|user1|dccn|batch|RUNNING|None|4|1|16G|1-00:00:00|2:13:47|2024-11-20T13:02:11|2024-11-20T13:02:12|dccn-c083|my job
+// ```
+func parseSingleJobLine(line string) (Job, error) {
+
+	job := Job{}
+
+	data := strings.SplitN(strings.TrimSuffix(line, "\n"), "|", 15)
+	if len(data) != 15 {
+		return job, fmt.Errorf("unexpected squeue output: %s", line)
+	}
+
+	job.ID = data[0]
+	job.User = data[1]
+	job.Account = data[2]
+	job.Partition = data[3]
+	job.State = data[4]
+	job.Reason = data[5]
+
+	var err error
+	if job.NumCPUs, err = strconv.Atoi(data[6]); err != nil {
+		return job, fmt.Errorf("invalid number of CPUs of job %s: %s", job.ID, err)
+	}
+	if job.NumNodes, err = strconv.Atoi(data[7]); err != nil {
+		return job, fmt.Errorf("invalid number of nodes of job %s: %s", job.ID, err)
+	}
+
+	job.MinMemory = data[8]
+	job.TimeLimit = data[9]
+	job.TimeUsed = data[10]
+	job.SubmitTime = parseTime(data[11])
+	job.StartTime = parseTime(data[12])
+	job.NodeList = data[13]
+	job.Name = data[14]
+
+	if job.ID == "" {
+		return job, fmt.Errorf("invalid job: ID is empty")
+	}
+
+	return job, nil
+}
+
+// parseMultipleJobLines converts the full output of `squeue` into an array of `Job`.
+// Lines that cannot be parsed are logged and skipped.
+func parseMultipleJobLines(out string) []Job {
+
+	jobs := make([]Job, 0)
+
+	for _, line := range strings.Split(out, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		job, err := parseSingleJobLine(line)
+		if err != nil {
+			log.Errorf("%s", err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs
+}
+
+// GetJobs makes a system call `squeue` and parse the output into array of `Job`.
+//
+// If `users` is given, only jobs of the given users are returned.  The system call is
+// terminated when `ctx` is done or the timeout of `squeue` given by `util.DefaultExecutor` is
+// reached.
+func GetJobs(ctx context.Context, users ...string) ([]Job, error) {
+
+	args := []string{"--all", "--noheader", fmt.Sprintf("--format=%s", squeueFormat)}
+
+	if len(users) > 0 {
+		args = append(args, fmt.Sprintf("--user=%s", strings.Join(users, ",")))
+	}
+
+	stdout, err := util.ExecCmdContext(ctx, "squeue", args)
+	if err != nil {
+		return []Job{}, err
+	}
+
+	return parseMultipleJobLines(stdout.String()), nil
+}
+
+// JobInfo defines the data structure of the detailed Slurm job information in the output of
+// `scontrol show job <id>`.
+type JobInfo struct {
+	ID      string
+	Name    string
+	User    string
+	Account string
+	QOS     string
+	State   string
+	Reason  string
+	// ExitCode is the exit code and the terminating signal of the job, e.g. `0:0`.
+	ExitCode  string
+	Partition string
+	NodeList  string
+	BatchHost string
+	NumNodes  int
+	NumCPUs   int
+	TimeLimit string
+	RunTime   string
+	// ArrayJobID and ArrayTaskID are set if the job is part of a job array.
+	ArrayJobID  string
+	ArrayTaskID string
+	// HetJobID and HetJobOffset are set if the job is a component of a heterogeneous job.
+	HetJobID     string
+	HetJobOffset string
+	SubmitTime   time.Time
+	EligibleTime time.Time
+	StartTime    time.Time
+	EndTime      time.Time
+	WorkDir      string
+	Command      string
+	StdIn        string
+	StdOut       string
+	StdErr       string
+	// ReqTRES and AllocTRES are the requested and allocated trackable resources, e.g.
+	// `cpu` -> `4`, `mem` -> `16G`, `gres/gpu` -> `1`.
+	ReqTRES   map[string]string
+	AllocTRES map[string]string
+}
+
+// parseTRES converts a TRES string, e.g. `cpu=4,mem=16G,node=1,gres/gpu=1`, into a map.
+func parseTRES(s string) map[string]string {
+	tres := make(map[string]string)
+	for _, r := range strings.Split(s, ",") {
+		if kv := strings.SplitN(r, "=", 2); len(kv) == 2 {
+			tres[kv[0]] = kv[1]
+		}
+	}
+	return tres
+}
+
+// lineKeys are the keys in the output of `scontrol` of which the value runs to the end of the
+// line, as the value may contain white spaces and `key=value`-like text, e.g. the arguments
+// of the `Command`.
+var lineKeys = map[string]bool{
+	"JobName":       true,
+	"Comment":       true,
+	"AdminComment":  true,
+	"SystemComment": true,
+	"Command":       true,
+	"WorkDir":       true,
+	"StdIn":         true,
+	"StdOut":        true,
+	"StdErr":        true,
+}
+
+// reKey matches a `key=value` field in the output of `scontrol`.
+var reKey = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_:/]*=`)
+
+// parseKeyValues splits the `key=value` output of `scontrol` into a key-value map.  The value
+// of a key in `lineKeys` is the rest of the line.  Other values containing white spaces are
+// kept together by appending the tokens without a key to the value of the previous key.
+func parseKeyValues(out string) map[string]string {
+
+	reField := regexp.MustCompile(`\S+`)
+
+	kvs := make(map[string]string)
+	key := ""
+	for _, line := range strings.Split(out, "\n") {
+		for _, loc := range reField.FindAllStringIndex(line, -1) {
+			field := line[loc[0]:loc[1]]
+			if reKey.MatchString(field) {
+				keyValue := strings.SplitN(field, "=", 2)
+				key = keyValue[0]
+				if lineKeys[key] {
+					kvs[key] = strings.TrimSpace(line[loc[0]+len(key)+1:])
+					break
+				}
+				kvs[key] = keyValue[1]
+				continue
+			}
+			if key != "" {
+				kvs[key] = fmt.Sprintf("%s %s", kvs[key], field)
+			}
+		}
+	}
+	return kvs
+}
+
+// parseSingleJobInfo converts the output of `scontrol show job <id>` into the `JobInfo`
+// data structure.
+//
+// The expected `out` looks like the one below:
+//
+// ```
+// JobId=4321 JobName=my job
+//
+//	UserId=user1(1001) GroupId=dccn(1000) MCS_label=N/A
+//	Priority=1 Nice=0 Account=dccn QOS=normal
+//	JobState=RUNNING Reason=None Dependency=(null)
+//	Requeue=1 Restarts=0 BatchFlag=1 Reboot=0 ExitCode=0:0
+//	RunTime=02:13:47 TimeLimit=1-00:00:00 TimeMin=N/A
+//	SubmitTime=2024-11-20T13:02:11 EligibleTime=2024-11-20T13:02:11
+//	StartTime=2024-11-20T13:02:12 EndTime=2024-11-21T13:02:12 Deadline=N/A
+//	Partition=batch AllocNode:Sid=mentat001:12345
+//	NodeList=dccn-c083
+//	BatchHost=dccn-c083
+//	NumNodes=1 NumCPUs=4 NumTasks=1 CPUs/Task=4 ReqB:S:C:T=0:0:*:*
+//	ReqTRES=cpu=4,mem=16G,node=1,billing=4
+//	AllocTRES=cpu=4,mem=16G,node=1,billing=4
+//	Command=/home/dccn/user1/job.sh
+//	WorkDir=/home/dccn/user1
+//	StdErr=/home/dccn/user1/slurm-4321.out
+//	StdIn=/dev/null
+//	StdOut=/home/dccn/user1/slurm-4321.out
+//
+// ```
+func parseSingleJobInfo(out string) (JobInfo, error) {
+
+	info := JobInfo{}
+
+	kvs := parseKeyValues(out)
+
+	info.ID = kvs["JobId"]
+	if info.ID == "" {
+		return info, fmt.Errorf("invalid job: ID is empty")
+	}
+
+	info.Name = kvs["JobName"]
+	info.User = strings.Split(kvs["UserId"], "(")[0]
+	info.Account = kvs["Account"]
+	info.QOS = kvs["QOS"]
+	info.State = kvs["JobState"]
+	info.Reason = kvs["Reason"]
+	info.ExitCode = kvs["ExitCode"]
+	info.Partition = kvs["Partition"]
+	info.NodeList = kvs["NodeList"]
+	info.BatchHost = kvs["BatchHost"]
+	info.TimeLimit = kvs["TimeLimit"]
+	info.RunTime = kvs["RunTime"]
+	info.ArrayJobID = kvs["ArrayJobId"]
+	info.ArrayTaskID = kvs["ArrayTaskId"]
+	info.HetJobID = kvs["HetJobId"]
+	info.HetJobOffset = kvs["HetJobOffset"]
+	info.SubmitTime = parseTime(kvs["SubmitTime"])
+	info.EligibleTime = parseTime(kvs["EligibleTime"])
+	info.StartTime = parseTime(kvs["StartTime"])
+	info.EndTime = parseTime(kvs["EndTime"])
+	info.WorkDir = kvs["WorkDir"]
+	info.Command = kvs["Command"]
+	info.StdIn = kvs["StdIn"]
+	info.StdOut = kvs["StdOut"]
+	info.StdErr = kvs["StdErr"]
+	info.ReqTRES = parseTRES(kvs["ReqTRES"])
+	info.AllocTRES = parseTRES(kvs["AllocTRES"])
+
+	var err error
+	if v, ok := kvs["NumNodes"]; ok {
+		// for pending jobs, the value can be a range, e.g. `1-2`.
+		if info.NumNodes, err = strconv.Atoi(strings.Split(v, "-")[0]); err != nil {
+			return info, fmt.Errorf("invalid number of nodes of job %s: %s", info.ID, err)
+		}
+	}
+	if v, ok := kvs["NumCPUs"]; ok {
+		if info.NumCPUs, err = strconv.Atoi(strings.Split(v, "-")[0]); err != nil {
+			return info, fmt.Errorf("invalid number of CPUs of job %s: %s", info.ID, err)
+		}
+	}
+
+	return info, nil
+}
+
+// parseMultipleJobInfo converts the output of `scontrol show job` containing one or more
+// jobs (e.g. tasks of a job array or components of a heterogeneous job) into array of
+// `JobInfo`.
+func parseMultipleJobInfo(out string) []JobInfo {
+
+	jobs := make([]JobInfo, 0)
+
+	// split on `JobId=` at the beginning of a line, not to be confused with `ArrayJobId=`
+	// or `HetJobId=`.
+	reJob := regexp.MustCompile(`(?m)^JobId=`)
+
+	for _, jobinfo := range reJob.Split(out, -1) {
+
+		if strings.TrimSpace(jobinfo) == "" {
+			continue
+		}
+
+		job, err := parseSingleJobInfo(fmt.Sprintf("JobId=%s", jobinfo))
+		if err != nil {
+			log.Errorf("%s", err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs
+}
+
+// GetJobInfo makes a system call `scontrol show job <id>` and parse the output into array
+// of `JobInfo`.  Multiple `JobInfo` are returned if the `id` refers to a job array or a
+// heterogeneous job.
+//
+// The system call is terminated when `ctx` is done or the timeout of `scontrol` given by
+// `util.DefaultExecutor` is reached.
+func GetJobInfo(ctx context.Context, id string) ([]JobInfo, error) {
+
+	stdout, err := util.ExecCmdContext(ctx, "scontrol", []string{"show", "job", id})
+	if err != nil {
+		return []JobInfo{}, err
+	}
+
+	return parseMultipleJobInfo(stdout.String()), nil
+}
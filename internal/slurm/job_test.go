@@ -0,0 +1,126 @@
+package slurm
+
+import (
+	"strings"
+	"testing"
+)
+
+var (
+	squeueout = []string{
+		`4321_7|user1|dccn|batch|RUNNING|None|4|1|16G|1-00:00:00|2:13:47|2024-11-20T13:02:11|2024-11-20T13:02:12|dccn-c083|my job`,
+		`4325|user2|dccn|gpu|PENDING|Resources|8|1|64G|12:00:00|0:00|2024-11-20T15:10:02|N/A||train|model`,
+	}
+)
+
+func TestParseSingleJobLine(t *testing.T) {
+	for _, line := range squeueout {
+		job, err := parseSingleJobLine(line)
+		if err != nil {
+			t.Fatalf("%s\n", err)
+		}
+		t.Logf("job: %+v\n", job)
+	}
+
+	job, _ := parseSingleJobLine(squeueout[1])
+	if job.Name != "train|model" {
+		t.Errorf("unexpected job name: %s", job.Name)
+	}
+	if !job.StartTime.IsZero() {
+		t.Errorf("unexpected start time of pending job: %s", job.StartTime)
+	}
+}
+
+func TestParseMultipleJobLines(t *testing.T) {
+	jobs := parseMultipleJobLines(strings.Join(squeueout, "\n"))
+	if len(jobs) != len(squeueout) {
+		t.Errorf("expect %d jobs, got %d", len(squeueout), len(jobs))
+	}
+}
+
+var (
+	jobinfo = []string{
+		`JobId=4322 ArrayJobId=4321 ArrayTaskId=7 JobName=my job
+   UserId=user1(1001) GroupId=dccn(1000) MCS_label=N/A
+   Priority=1 Nice=0 Account=dccn QOS=normal
+   JobState=RUNNING Reason=None Dependency=(null)
+   Requeue=1 Restarts=0 BatchFlag=1 Reboot=0 ExitCode=0:0
+   RunTime=02:13:47 TimeLimit=1-00:00:00 TimeMin=N/A
+   SubmitTime=2024-11-20T13:02:11 EligibleTime=2024-11-20T13:02:11
+   StartTime=2024-11-20T13:02:12 EndTime=2024-11-21T13:02:12 Deadline=N/A
+   Partition=gpu AllocNode:Sid=mentat001:12345
+   NodeList=dccn-c083
+   BatchHost=dccn-c083
+   NumNodes=1 NumCPUs=4 NumTasks=1 CPUs/Task=4 ReqB:S:C:T=0:0:*:*
+   ReqTRES=cpu=4,mem=16G,node=1,billing=4,gres/gpu=1
+   AllocTRES=cpu=4,mem=16G,node=1,billing=4,gres/gpu=1
+   Command=/home/dccn/user1/job.sh
+   WorkDir=/home/dccn/user1
+   StdErr=/home/dccn/user1/slurm-4321_7.out
+   StdIn=/dev/null
+   StdOut=/home/dccn/user1/slurm-4321_7.out`,
+
+		`JobId=4323 ArrayJobId=4321 ArrayTaskId=8 JobName=my job
+   UserId=user1(1001) GroupId=dccn(1000) MCS_label=N/A
+   JobState=PENDING Reason=Resources Dependency=(null)
+   Partition=gpu
+   NumNodes=1-1 NumCPUs=4 NumTasks=1 CPUs/Task=4 ReqB:S:C:T=0:0:*:*
+   ReqTRES=cpu=4,mem=16G,node=1,billing=4,gres/gpu=1`,
+	}
+)
+
+func TestParseSingleJobInfo(t *testing.T) {
+	info, err := parseSingleJobInfo(jobinfo[0])
+	if err != nil {
+		t.Fatalf("%s\n", err)
+	}
+	t.Logf("job info: %+v\n", info)
+
+	if info.ID != "4322" || info.Name != "my job" || info.User != "user1" || info.NumCPUs != 4 {
+		t.Errorf("unexpected job info: %+v", info)
+	}
+	if info.ReqTRES["gres/gpu"] != "1" {
+		t.Errorf("unexpected requested TRES: %+v", info.ReqTRES)
+	}
+}
+
+func TestParseMultipleJobInfo(t *testing.T) {
+	infos := parseMultipleJobInfo(strings.Join(jobinfo, "\n\n"))
+	if len(infos) != 2 {
+		t.Fatalf("expect 2 jobs, got %d", len(infos))
+	}
+	if infos[1].ArrayJobID != "4321" || infos[1].ArrayTaskID != "8" || infos[1].Reason != "Resources" {
+		t.Errorf("unexpected job info: %+v", infos[1])
+	}
+}
+
+var (
+	// jobinfoCommand is a job of which the command has arguments with `key=value`-like text.
+	jobinfoCommand = `JobId=4330 JobName=train model
+   UserId=user2(1002) GroupId=dccn(1000) MCS_label=N/A
+   JobState=RUNNING Reason=None Dependency=(null)
+   Partition=gpu
+   NumNodes=1 NumCPUs=8 NumTasks=1 CPUs/Task=8 ReqB:S:C:T=0:0:*:*
+   Comment=sweep lr=0.1 run=3
+   Command=/opt/python/bin/python train.py --epochs 10 lr=0.1 Reason=test
+   WorkDir=/home/dccn/user2/my project
+   StdOut=/home/dccn/user2/slurm-4330.out`
+)
+
+func TestParseJobInfoCommand(t *testing.T) {
+	info, err := parseSingleJobInfo(jobinfoCommand)
+	if err != nil {
+		t.Fatalf("%s\n", err)
+	}
+	t.Logf("job info: %+v\n", info)
+
+	if info.Command != "/opt/python/bin/python train.py --epochs 10 lr=0.1 Reason=test" {
+		t.Errorf("unexpected command: %s", info.Command)
+	}
+	if info.WorkDir != "/home/dccn/user2/my project" || info.Name != "train model" {
+		t.Errorf("unexpected job info: %+v", info)
+	}
+	// the arguments of the command are not taken as keys
+	if info.Reason != "None" || info.NumCPUs != 8 {
+		t.Errorf("unexpected job info: %+v", info)
+	}
+}
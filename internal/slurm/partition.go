@@ -0,0 +1,271 @@
+package slurm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Donders-Institute/hpc-utility/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// Partition defines the data structure of a Slurm partition in the output of
+// `scontrol show partition`.
+type Partition struct {
+	Name    string
+	State   string
+	Default bool
+	Hidden  bool
+	// Nodes is the hostlist expression of the partition nodes, e.g. `dccn-c[001-084]`.
+	Nodes      string
+	TotalNodes int
+	TotalCPUs  int
+	// DefaultTime and MaxTime are the default and maximum walltime, e.g. `1-00:00:00` or
+	// `UNLIMITED`.
+	DefaultTime string
+	MaxTime     string
+	// MinNodes, MaxNodes and MaxCPUsPerNode are the limits of a job; 0 means unlimited.
+	MinNodes       int
+	MaxNodes       int
+	MaxCPUsPerNode int
+	// DefMemPerCPU and MaxMemPerCPU are the default and maximum memory per CPU in megabytes;
+	// 0 means unlimited or not set.
+	DefMemPerCPU  int
+	MaxMemPerCPU  int
+	AllowAccounts []string
+	AllowGroups   []string
+	AllowQOS      []string
+	TRES          map[string]string
+}
+
+// PartitionUsage defines the current resource usage of a Slurm partition.
+type PartitionUsage struct {
+	Name string
+	// NodeStates is the number of nodes by the node state, e.g. `idle` -> 3, `mixed` -> 5.
+	NodeStates map[string]int
+	AllocCPUs  int
+	IdleCPUs   int
+	OtherCPUs  int
+	TotalCPUs  int
+	IdleGPUs   int
+	TotalGPUs  int
+}
+
+// parseLimit converts the numerical limit in the `scontrol` output into an integer.  The
+// `UNLIMITED` and `N/A` values result in 0.
+func parseLimit(s string) (int, error) {
+	switch s {
+	case "", "UNLIMITED", "N/A", "NONE":
+		return 0, nil
+	default:
+		return strconv.Atoi(s)
+	}
+}
+
+// parseSinglePartitionInfo converts the output of `scontrol show partition <name>` into
+// the `Partition` data structure.
+//
+// The expected `out` looks like the one below:
+//
+// ```
+// PartitionName=gpu
+//
+//	AllowGroups=ALL AllowAccounts=ALL AllowQos=ALL
+//	AllocNodes=ALL Default=NO QoS=N/A
+//	DefaultTime=01:00:00 DisableRootJobs=NO ExclusiveUser=NO GraceTime=0 Hidden=NO
+//	MaxNodes=1 MaxTime=2-00:00:00 MinNodes=0 LLN=NO MaxCPUsPerNode=UNLIMITED
+//	Nodes=dccn-c[083-084]
+//	PriorityJobFactor=1 PriorityTier=1 RootOnly=NO ReqResv=NO OverSubscribe=NO
+//	OverTimeLimit=NONE PreemptMode=OFF
+//	State=UP TotalCPUs=126 TotalNodes=2 SelectTypeParameters=NONE
+//	JobDefaults=(null)
+//	DefMemPerCPU=4096 MaxMemPerCPU=16384
+//	TRES=cpu=126,mem=1031156M,node=2,billing=126,gres/gpu=8
+//
+// ```
+func parseSinglePartitionInfo(out string) (Partition, error) {
+
+	p := Partition{}
+
+	kvs := parseKeyValues(out)
+
+	p.Name = kvs["PartitionName"]
+	if p.Name == "" {
+		return p, fmt.Errorf("invalid partition: name is empty")
+	}
+
+	p.State = kvs["State"]
+	p.Default = kvs["Default"] == "YES"
+	p.Hidden = kvs["Hidden"] == "YES"
+	p.Nodes = kvs["Nodes"]
+	p.DefaultTime = kvs["DefaultTime"]
+	p.MaxTime = kvs["MaxTime"]
+	p.TRES = parseTRES(kvs["TRES"])
+
+	// list splits the comma-separated value; `ALL` results in an empty list.
+	list := func(v string) []string {
+		if v == "" || v == "ALL" {
+			return []string{}
+		}
+		return strings.Split(v, ",")
+	}
+	p.AllowAccounts = list(kvs["AllowAccounts"])
+	p.AllowGroups = list(kvs["AllowGroups"])
+	p.AllowQOS = list(kvs["AllowQos"])
+
+	limits := map[string]*int{
+		"TotalNodes":     &p.TotalNodes,
+		"TotalCPUs":      &p.TotalCPUs,
+		"MinNodes":       &p.MinNodes,
+		"MaxNodes":       &p.MaxNodes,
+		"MaxCPUsPerNode": &p.MaxCPUsPerNode,
+		"DefMemPerCPU":   &p.DefMemPerCPU,
+		"MaxMemPerCPU":   &p.MaxMemPerCPU,
+	}
+	for k, v := range limits {
+		n, err := parseLimit(kvs[k])
+		if err != nil {
+			return p, fmt.Errorf("invalid %s of partition %s: %s", k, p.Name, err)
+		}
+		*v = n
+	}
+
+	return p, nil
+}
+
+// parseMultiplePartitionInfo converts the output of `scontrol show partition` into array
+// of `Partition`.
+func parseMultiplePartitionInfo(out string) []Partition {
+
+	partitions := make([]Partition, 0)
+
+	rePart := regexp.MustCompile(`(?m)^PartitionName=`)
+
+	for _, info := range rePart.Split(out, -1) {
+
+		if strings.TrimSpace(info) == "" {
+			continue
+		}
+
+		p, err := parseSinglePartitionInfo(fmt.Sprintf("PartitionName=%s", info))
+		if err != nil {
+			log.Errorf("%s", err)
+			continue
+		}
+		partitions = append(partitions, p)
+	}
+
+	return partitions
+}
+
+// parseSinfoUsage converts the output of `sinfo --noheader --format=%R|%T|%D|%C` into
+// a map of `PartitionUsage` with the partition name as key.
+//
+// The expected `out` looks like the one below:
+//
+// ```
+// batch|mixed|5|40/275/0/315
+// batch|idle|3|0/189/0/189
+// gpu|mixed|1|2/61/0/63
+// gpu|drained|1|0/0/63/63
+// ```
+func parseSinfoUsage(out string) (map[string]*PartitionUsage, error) {
+
+	usages := make(map[string]*PartitionUsage)
+
+	for _, line := range strings.Split(out, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		data := strings.Split(line, "|")
+		if len(data) != 4 {
+			return usages, fmt.Errorf("unexpected sinfo output: %s", line)
+		}
+
+		u, ok := usages[data[0]]
+		if !ok {
+			u = &PartitionUsage{Name: data[0], NodeStates: make(map[string]int)}
+			usages[data[0]] = u
+		}
+
+		nnodes, err := strconv.Atoi(data[2])
+		if err != nil {
+			return usages, fmt.Errorf("invalid number of nodes: %s", line)
+		}
+		u.NodeStates[data[1]] += nnodes
+
+		cpus := strings.Split(data[3], "/")
+		if len(cpus) != 4 {
+			return usages, fmt.Errorf("unexpected CPU counts: %s", line)
+		}
+		for i, v := range []*int{&u.AllocCPUs, &u.IdleCPUs, &u.OtherCPUs, &u.TotalCPUs} {
+			n, err := strconv.Atoi(cpus[i])
+			if err != nil {
+				return usages, fmt.Errorf("invalid CPU counts: %s", line)
+			}
+			*v += n
+		}
+	}
+
+	return usages, nil
+}
+
+// GetPartitionInfo makes a system call `scontrol show partition` and parse the output into
+// array of `Partition`.
+//
+// If the given argument `name` is a empty string `""` or `"ALL"`, it will get information
+// of all Slurm partitions.  The system call is terminated when `ctx` is done or the timeout
+// of `scontrol` given by `util.DefaultExecutor` is reached.
+func GetPartitionInfo(ctx context.Context, name string) ([]Partition, error) {
+
+	args := []string{"show", "partition"}
+
+	if name != "" && name != "ALL" {
+		args = append(args, name)
+	}
+
+	stdout, err := util.ExecCmdContext(ctx, "scontrol", args)
+	if err != nil {
+		return []Partition{}, err
+	}
+
+	return parseMultiplePartitionInfo(stdout.String()), nil
+}
+
+// GetPartitionUsage makes a system call `sinfo` to get node and CPU usage of partitions,
+// and complements it with the GPU usage retrieved from the node information.
+func GetPartitionUsage(ctx context.Context) (map[string]*PartitionUsage, error) {
+
+	args := []string{"--noheader", "--format=%R|%T|%D|%C"}
+
+	stdout, err := util.ExecCmdContext(ctx, "sinfo", args)
+	if err != nil {
+		return nil, err
+	}
+
+	usages, err := parseSinfoUsage(stdout.String())
+	if err != nil {
+		return usages, err
+	}
+
+	nodes, err := GetNodeInfo(ctx, "ALL")
+	if err != nil {
+		return usages, err
+	}
+
+	for _, n := range nodes {
+		for _, p := range n.Partitions {
+			if u, ok := usages[p]; ok {
+				u.TotalGPUs += n.TotalGPUS
+				if !n.Unavailable() {
+					u.IdleGPUs += n.AvailGPUS
+				}
+			}
+		}
+	}
+
+	return usages, nil
+}
@@ -0,0 +1,71 @@
+package slurm
+
+import (
+	"strings"
+	"testing"
+)
+
+var (
+	partitioninfo = []string{
+		`PartitionName=batch
+   AllowGroups=ALL AllowAccounts=ALL AllowQos=ALL
+   AllocNodes=ALL Default=YES QoS=N/A
+   DefaultTime=01:00:00 DisableRootJobs=NO ExclusiveUser=NO GraceTime=0 Hidden=NO
+   MaxNodes=UNLIMITED MaxTime=7-00:00:00 MinNodes=0 LLN=NO MaxCPUsPerNode=UNLIMITED
+   Nodes=dccn-c[075-084]
+   PriorityJobFactor=1 PriorityTier=1 RootOnly=NO ReqResv=NO OverSubscribe=NO
+   OverTimeLimit=NONE PreemptMode=OFF
+   State=UP TotalCPUs=630 TotalNodes=10 SelectTypeParameters=NONE
+   JobDefaults=(null)
+   DefMemPerCPU=4096 MaxMemPerCPU=UNLIMITED
+   TRES=cpu=630,mem=5155780M,node=10,billing=630,gres/gpu=8`,
+
+		`PartitionName=gpu
+   AllowGroups=ALL AllowAccounts=dccn,mrrc AllowQos=normal,long
+   AllocNodes=ALL Default=NO QoS=N/A
+   DefaultTime=01:00:00 DisableRootJobs=NO ExclusiveUser=NO GraceTime=0 Hidden=NO
+   MaxNodes=1 MaxTime=2-00:00:00 MinNodes=0 LLN=NO MaxCPUsPerNode=UNLIMITED
+   Nodes=dccn-c[083-084]
+   State=UP TotalCPUs=126 TotalNodes=2 SelectTypeParameters=NONE
+   DefMemPerCPU=4096 MaxMemPerCPU=16384
+   TRES=cpu=126,mem=1031156M,node=2,billing=126,gres/gpu=8`,
+	}
+
+	sinfoout = `batch|mixed|5|40/275/0/315
+batch|idle|3|0/189/0/189
+batch|drained|2|0/0/126/126
+gpu|mixed|1|2/61/0/63
+gpu|drained|1|0/0/63/63
+`
+)
+
+func TestParseMultiplePartitionInfo(t *testing.T) {
+	partitions := parseMultiplePartitionInfo(strings.Join(partitioninfo, "\n\n"))
+
+	if len(partitions) != 2 {
+		t.Fatalf("expect 2 partitions, got %d", len(partitions))
+	}
+
+	for _, p := range partitions {
+		t.Logf("partition: %+v\n", p)
+	}
+
+	if p := partitions[0]; !p.Default || p.MaxNodes != 0 || p.MaxMemPerCPU != 0 || len(p.AllowQOS) != 0 {
+		t.Errorf("unexpected partition: %+v", p)
+	}
+
+	if p := partitions[1]; p.MaxNodes != 1 || p.MaxMemPerCPU != 16384 || len(p.AllowAccounts) != 2 || p.MaxTime != "2-00:00:00" {
+		t.Errorf("unexpected partition: %+v", p)
+	}
+}
+
+func TestParseSinfoUsage(t *testing.T) {
+	usages, err := parseSinfoUsage(sinfoout)
+	if err != nil {
+		t.Fatalf("%s\n", err)
+	}
+
+	if u := usages["batch"]; u.NodeStates["idle"] != 3 || u.IdleCPUs != 464 || u.TotalCPUs != 630 {
+		t.Errorf("unexpected partition usage: %+v", u)
+	}
+}
@@ -0,0 +1,126 @@
+package slurm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Donders-Institute/hpc-utility/internal/hostlist"
+	"github.com/Donders-Institute/hpc-utility/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// Reservation defines the data structure of a Slurm reservation in the output of
+// `scontrol show reservation`.
+type Reservation struct {
+	Name      string
+	State     string
+	StartTime time.Time
+	EndTime   time.Time
+	// Nodes are the hostnames of the reserved nodes expanded from the hostlist expression,
+	// e.g. `dccn-c[075,083]`.
+	Nodes []string
+	// Flags are the reservation flags, e.g. `MAINT` or `IGNORE_JOBS`.
+	Flags    []string
+	Users    []string
+	Accounts []string
+}
+
+// parseSingleReservationInfo converts the output of `scontrol show reservation <name>` into
+// the `Reservation` data structure.
+//
+// The expected `out` looks like the one below:
+//
+// ```
+// ReservationName=maint StartTime=2035-12-02T08:00:00 EndTime=2035-12-02T18:00:00 Duration=10:00:00
+//
+//	Nodes=dccn-c[075,083] NodeCnt=2 CoreCnt=96 Features=(null) PartitionName=(null) Flags=MAINT,IGNORE_JOBS,SPEC_NODES
+//	TRES=cpu=96
+//	Users=root Groups=(null) Accounts=(null) Licenses=(null) State=INACTIVE BurstBuffer=(null) Watts=n/a
+//	MaxStartDelay=(null)
+//
+// ```
+func parseSingleReservationInfo(out string) (Reservation, error) {
+
+	r := Reservation{}
+
+	kvs := parseKeyValues(out)
+
+	r.Name = kvs["ReservationName"]
+	if r.Name == "" {
+		return r, fmt.Errorf("invalid reservation: name is empty")
+	}
+
+	r.State = kvs["State"]
+	r.StartTime = parseTime(kvs["StartTime"])
+	r.EndTime = parseTime(kvs["EndTime"])
+
+	// list splits the comma-separated value; `(null)` results in an empty list.
+	list := func(v string) []string {
+		if v == "" || v == "(null)" {
+			return []string{}
+		}
+		return strings.Split(v, ",")
+	}
+	r.Flags = list(kvs["Flags"])
+	r.Users = list(kvs["Users"])
+	r.Accounts = list(kvs["Accounts"])
+
+	r.Nodes = []string{}
+	if v := kvs["Nodes"]; v != "" && v != "(null)" {
+		nodes, err := hostlist.Expand(v)
+		if err != nil {
+			return r, fmt.Errorf("invalid nodes of reservation %s: %s", r.Name, err)
+		}
+		r.Nodes = nodes
+	}
+
+	return r, nil
+}
+
+// parseMultipleReservationInfo converts the output of `scontrol show reservation` into array
+// of `Reservation`.
+func parseMultipleReservationInfo(out string) []Reservation {
+
+	reservations := make([]Reservation, 0)
+
+	reResv := regexp.MustCompile(`(?m)^ReservationName=`)
+
+	// the output is `No reservations in the system` if there is no reservation.
+	if !reResv.MatchString(out) {
+		return reservations
+	}
+
+	for _, info := range reResv.Split(out, -1) {
+
+		if strings.TrimSpace(info) == "" {
+			continue
+		}
+
+		r, err := parseSingleReservationInfo(fmt.Sprintf("ReservationName=%s", info))
+		if err != nil {
+			log.Errorf("%s", err)
+			continue
+		}
+		reservations = append(reservations, r)
+	}
+
+	return reservations
+}
+
+// GetReservations makes a system call `scontrol show reservation` and parse the output into
+// array of `Reservation`.
+//
+// The system call is terminated when `ctx` is done or the timeout of `scontrol` given by
+// `util.DefaultExecutor` is reached.
+func GetReservations(ctx context.Context) ([]Reservation, error) {
+
+	stdout, err := util.ExecCmdContext(ctx, "scontrol", []string{"show", "reservation"})
+	if err != nil {
+		return []Reservation{}, err
+	}
+
+	return parseMultipleReservationInfo(stdout.String()), nil
+}
@@ -0,0 +1,43 @@
+package slurm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Donders-Institute/hpc-utility/internal/util"
+)
+
+func TestGetReservations(t *testing.T) {
+
+	// replay the recorded `scontrol` outputs
+	defer func(r util.Runner) { util.DefaultRunner = r }(util.DefaultRunner)
+	util.DefaultRunner = &util.ReplayRunner{Dir: "../../testdata/exec"}
+
+	resvs, err := GetReservations(context.Background())
+	if err != nil {
+		t.Fatalf("%s\n", err)
+	}
+
+	for _, r := range resvs {
+		t.Logf("reservation: %+v\n", r)
+	}
+
+	if len(resvs) != 3 {
+		t.Fatalf("expect 3 reservations, got %d", len(resvs))
+	}
+
+	if r := resvs[0]; r.Name != "upgrade" || len(r.Nodes) != 3 || r.Nodes[2] != "dccn-c084" || len(r.Flags) != 3 || r.Flags[0] != "MAINT" || r.State != "INACTIVE" {
+		t.Errorf("unexpected reservation: %+v", r)
+	}
+
+	if r := resvs[1]; r.Name != "gpu_project" || len(r.Users) != 0 || len(r.Accounts) != 1 || r.EndTime.Year() != 2035 {
+		t.Errorf("unexpected reservation: %+v", r)
+	}
+}
+
+func TestParseMultipleReservationInfo(t *testing.T) {
+
+	if resvs := parseMultipleReservationInfo("No reservations in the system\n"); len(resvs) != 0 {
+		t.Errorf("expect no reservation, got %+v", resvs)
+	}
+}
@@ -0,0 +1,96 @@
+package slurm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Donders-Institute/hpc-utility/internal/hostlist"
+	"github.com/Donders-Institute/hpc-utility/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// runningSqueueFormat is the output format given to `squeue` for the running jobs.  The job
+// command is put at the end as it may contain the separator.
+const runningSqueueFormat = "%i|%u|%N|%S|%j|%k|%o"
+
+// RunningJob defines the data structure of a running Slurm job with the information needed
+// to recognise the job, e.g. as an interactive job running a VNC server.
+type RunningJob struct {
+	ID   string
+	User string
+	// Node is the first node of the job.
+	Node      string
+	StartTime time.Time
+	Name      string
+	// Comment is empty if the job has no comment.
+	Comment string
+	// Command is the command of the job with its arguments, e.g. `/usr/bin/Xvnc :5` given by
+	// `salloc /usr/bin/Xvnc :5`.
+	Command string
+}
+
+// parseRunningJobLines converts the output of `squeue` in `runningSqueueFormat` into an
+// array of `RunningJob`.
+//
+// The expected `out` looks like the one below:
+//
+// ```
+// 4330|user1|dccn-c083|2024-11-20T09:12:40|vnc|vnc:5|/bin/bash
+// 4331|user2|dccn-c084|2024-11-20T10:01:02|Xvnc|(null)|/usr/bin/Xvnc :12 -geometry 1920x1080
+// ```
+func parseRunningJobLines(out string) []RunningJob {
+
+	jobs := make([]RunningJob, 0)
+
+	for _, line := range strings.Split(out, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		data := strings.SplitN(line, "|", 7)
+		if len(data) != 7 {
+			log.Errorf("unexpected squeue output: %s", line)
+			continue
+		}
+
+		nodes, err := hostlist.Expand(data[2])
+		if err != nil || len(nodes) == 0 {
+			log.Errorf("invalid nodes of job %s: %s", data[0], data[2])
+			continue
+		}
+
+		job := RunningJob{
+			ID:        data[0],
+			User:      data[1],
+			Node:      nodes[0],
+			StartTime: parseTime(data[3]),
+			Name:      data[4],
+			Command:   strings.TrimSpace(data[6]),
+		}
+		if c := strings.TrimSpace(data[5]); c != "(null)" {
+			job.Comment = c
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs
+}
+
+// GetRunningJobs makes a system call `squeue` and returns the running jobs of all users.
+//
+// The system call is terminated when `ctx` is done or the timeout of `squeue` given by
+// `util.DefaultExecutor` is reached.
+func GetRunningJobs(ctx context.Context) ([]RunningJob, error) {
+
+	args := []string{"--all", "--noheader", "--states=RUNNING", fmt.Sprintf("--format=%s", runningSqueueFormat)}
+
+	stdout, err := util.ExecCmdContext(ctx, "squeue", args)
+	if err != nil {
+		return []RunningJob{}, err
+	}
+
+	return parseRunningJobLines(stdout.String()), nil
+}
@@ -0,0 +1,36 @@
+package slurm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Donders-Institute/hpc-utility/internal/util"
+)
+
+func TestGetRunningJobs(t *testing.T) {
+
+	// replay the recorded `squeue` outputs
+	defer func(r util.Runner) { util.DefaultRunner = r }(util.DefaultRunner)
+	util.DefaultRunner = &util.ReplayRunner{Dir: "../../testdata/exec"}
+
+	jobs, err := GetRunningJobs(context.Background())
+	if err != nil {
+		t.Fatalf("%s\n", err)
+	}
+
+	for _, j := range jobs {
+		t.Logf("running job: %+v\n", j)
+	}
+
+	if len(jobs) != 4 {
+		t.Fatalf("expect 4 running jobs, got %d", len(jobs))
+	}
+
+	if j := jobs[0]; j.ID != "4321_7" || j.Comment != "" {
+		t.Errorf("unexpected running job: %+v", j)
+	}
+
+	if j := jobs[2]; j.ID != "4331" || j.User != "user2" || j.Node != "dccn-c084" || j.Command != "/usr/bin/Xvnc :12 -geometry 1920x1080 -SecurityTypes VncAuth" {
+		t.Errorf("unexpected running job: %+v", j)
+	}
+}
@@ -0,0 +1,167 @@
+package slurm
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Donders-Institute/hpc-utility/internal/hostlist"
+	"github.com/Donders-Institute/hpc-utility/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// sstatFields is the list of fields retrieved from `sstat`.
+var sstatFields = []string{
+	"JobID",
+	"NTasks",
+	"Nodelist",
+	"AveRSS",
+	"MaxRSS",
+	"MaxRSSNode",
+	"MaxRSSTask",
+	"TRESUsageInTot",
+}
+
+// StepMemory defines the memory usage of a running job step in the output of `sstat`.
+type StepMemory struct {
+	JobID    string
+	NumTasks int
+	NodeList string
+	// CurrentRSSBytes is the current resident memory summed over all tasks of the step.
+	CurrentRSSBytes int64
+	// AveRSSBytes is the average resident memory of the tasks of the step.
+	AveRSSBytes int64
+	// MaxRSSBytes is the peak resident memory of the tasks of the step; the task and the
+	// node on which the peak is reached are given by `MaxRSSTask` and `MaxRSSNode`.
+	MaxRSSBytes int64
+	MaxRSSNode  string
+	MaxRSSTask  string
+}
+
+// NodeMemory defines the memory usage of a running job on a node.
+type NodeMemory struct {
+	Node string
+	// CurrentRSSBytes is the current resident memory of the steps running only on the node.
+	CurrentRSSBytes int64
+	// CurrentRSSUnknown is true if a step running on multiple nodes runs on the node; the
+	// current memory of such a step is only known in total and not per node, so that the
+	// current memory of the node is unknown.
+	CurrentRSSUnknown bool
+	// MaxRSSBytes is the largest peak resident memory of the steps reaching the peak on
+	// the node.
+	MaxRSSBytes int64
+}
+
+// parseStepMemory converts the `sstat --parsable2` output, with the header line, into
+// array of `StepMemory`.
+//
+// The expected `out` looks like the one below:
+//
+// ```
+// JobID|NTasks|Nodelist|AveRSS|MaxRSS|MaxRSSNode|MaxRSSTask|TRESUsageInTot
+// 4321.extern|1|dccn-c083|1000K|1000K|dccn-c083|0|cpu=00:00:00,energy=0,fs/disk=2332,mem=1000K,pages=0,vmem=0
+// 4321.batch|1|dccn-c083|5242880K|7340032K|dccn-c083|0|cpu=01:10:00,energy=0,fs/disk=1073741,mem=5242880K,pages=0,vmem=6291456K
+// ```
+func parseStepMemory(out string) ([]StepMemory, error) {
+
+	steps := make([]StepMemory, 0)
+
+	rows, err := parseParsable(out)
+	if err != nil {
+		return steps, err
+	}
+
+	for _, row := range rows {
+
+		s := StepMemory{
+			JobID:      row["JobID"],
+			NodeList:   row["Nodelist"],
+			MaxRSSNode: row["MaxRSSNode"],
+			MaxRSSTask: row["MaxRSSTask"],
+		}
+
+		if v := row["NTasks"]; v != "" {
+			if s.NumTasks, err = strconv.Atoi(v); err != nil {
+				return steps, fmt.Errorf("invalid number of tasks of step %s: %s", s.JobID, err)
+			}
+		}
+
+		if s.AveRSSBytes, _, err = ParseMemBytes(row["AveRSS"], ""); err != nil {
+			return steps, err
+		}
+		if s.MaxRSSBytes, _, err = ParseMemBytes(row["MaxRSS"], ""); err != nil {
+			return steps, err
+		}
+		if s.CurrentRSSBytes, _, err = ParseMemBytes(parseTRES(row["TRESUsageInTot"])["mem"], ""); err != nil {
+			return steps, err
+		}
+
+		steps = append(steps, s)
+	}
+
+	return steps, nil
+}
+
+// AggregateNodeMemory aggregates memory usage of the job steps by node.  Current memory
+// usage can only be attributed to a node for steps running on a single node; the current
+// memory usage of the nodes of a step running on multiple nodes is marked as unknown.
+func AggregateNodeMemory(steps []StepMemory) []NodeMemory {
+
+	nodes := make([]NodeMemory, 0)
+	idx := make(map[string]int)
+
+	node := func(n string) *NodeMemory {
+		i, ok := idx[n]
+		if !ok {
+			i = len(nodes)
+			idx[n] = i
+			nodes = append(nodes, NodeMemory{Node: n})
+		}
+		return &nodes[i]
+	}
+
+	for _, s := range steps {
+		hosts, err := hostlist.Expand(s.NodeList)
+		if err != nil {
+			log.Errorf("invalid nodes of step %s: %s", s.JobID, s.NodeList)
+		}
+		switch {
+		case len(hosts) == 1:
+			node(hosts[0]).CurrentRSSBytes += s.CurrentRSSBytes
+		case len(hosts) > 1:
+			for _, h := range hosts {
+				node(h).CurrentRSSUnknown = true
+			}
+		}
+		if s.MaxRSSNode != "" {
+			if n := node(s.MaxRSSNode); s.MaxRSSBytes > n.MaxRSSBytes {
+				n.MaxRSSBytes = s.MaxRSSBytes
+			}
+		}
+	}
+
+	return nodes
+}
+
+// GetStepMemory makes a system call `sstat` and returns the memory usage of all steps of
+// the running job `id`.
+//
+// The system call is terminated when `ctx` is done or the timeout of `sstat` given by
+// `util.DefaultExecutor` is reached.
+func GetStepMemory(ctx context.Context, id string) ([]StepMemory, error) {
+
+	args := []string{
+		"--parsable2",
+		"--allsteps",
+		fmt.Sprintf("--format=%s", strings.Join(sstatFields, ",")),
+		fmt.Sprintf("--jobs=%s", id),
+	}
+
+	stdout, err := util.ExecCmdContext(ctx, "sstat", args)
+	if err != nil {
+		return []StepMemory{}, err
+	}
+
+	return parseStepMemory(stdout.String())
+}
@@ -0,0 +1,49 @@
+package slurm
+
+import (
+	"testing"
+)
+
+var (
+	sstatout = `JobID|NTasks|Nodelist|AveRSS|MaxRSS|MaxRSSNode|MaxRSSTask|TRESUsageInTot
+4321.extern|1|dccn-c083|1024K|1024K|dccn-c083|0|cpu=00:00:00,energy=0,fs/disk=2332,mem=1024K,pages=0,vmem=0
+4321.batch|1|dccn-c083|5242880K|7340032K|dccn-c083|0|cpu=01:10:00,energy=0,fs/disk=1073741,mem=5242880K,pages=0,vmem=6291456K
+4321.0|2|dccn-c[083-084]|1048576K|2097152K|dccn-c084|1|cpu=00:10:00,energy=0,fs/disk=0,mem=2097152K,pages=0,vmem=0
+`
+)
+
+func TestParseStepMemory(t *testing.T) {
+	steps, err := parseStepMemory(sstatout)
+	if err != nil {
+		t.Fatalf("%s\n", err)
+	}
+
+	if len(steps) != 3 {
+		t.Fatalf("expect 3 steps, got %d", len(steps))
+	}
+
+	if s := steps[1]; s.CurrentRSSBytes != 5<<30 || s.MaxRSSBytes != 7<<30 {
+		t.Errorf("unexpected step memory: %+v", s)
+	}
+
+	nodes := AggregateNodeMemory(steps)
+	for _, n := range nodes {
+		t.Logf("node memory: %+v", n)
+	}
+
+	if len(nodes) != 2 || nodes[0].CurrentRSSBytes != 5<<30+1<<20 || nodes[1].MaxRSSBytes != 2<<30 {
+		t.Errorf("unexpected node memory: %+v", nodes)
+	}
+
+	// the current memory of the step 4321.0 on two nodes cannot be split over the nodes
+	for _, n := range nodes {
+		if !n.CurrentRSSUnknown {
+			t.Errorf("expect unknown current memory of node %s", n.Node)
+		}
+	}
+
+	nodes = AggregateNodeMemory(steps[:2])
+	if len(nodes) != 1 || nodes[0].CurrentRSSUnknown {
+		t.Errorf("unexpected node memory: %+v", nodes)
+	}
+}
@@ -0,0 +1,149 @@
+package torque
+
+import (
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	trqhelper "github.com/Donders-Institute/hpc-torque-helper/pkg/client"
+	"github.com/Donders-Institute/hpc-utility/internal/util"
+)
+
+// jobStateNames maps the single-letter Torque job state to a descriptive name.
+var jobStateNames = map[string]string{
+	"C": "COMPLETED",
+	"E": "EXITING",
+	"H": "HELD",
+	"Q": "QUEUED",
+	"R": "RUNNING",
+	"T": "TRANSIT",
+	"W": "WAITING",
+	"S": "SUSPENDED",
+}
+
+// Job defines the data structure of a Torque job in the XML output of `qstat -x`.
+type Job struct {
+	ID       string `xml:"Job_Id"`
+	Name     string `xml:"Job_Name"`
+	Owner    string `xml:"Job_Owner"`
+	State    string `xml:"job_state"`
+	Queue    string `xml:"queue"`
+	ExecHost string `xml:"exec_host"`
+	// ReqNodes is the node specification of the job, e.g. `1:ppn=4`.
+	ReqNodes     string `xml:"Resource_List>nodes"`
+	ReqMem       string `xml:"Resource_List>mem"`
+	ReqWalltime  string `xml:"Resource_List>walltime"`
+	UsedMem      string `xml:"resources_used>mem"`
+	UsedCput     string `xml:"resources_used>cput"`
+	UsedWalltime string `xml:"resources_used>walltime"`
+	// CTime, QTime and StartTime are the job creation, queue and start time in seconds since epoch.
+	CTime      int64  `xml:"ctime"`
+	QTime      int64  `xml:"qtime"`
+	StartTime  int64  `xml:"start_time"`
+	ExitStatus string `xml:"exit_status"`
+}
+
+// User returns the username of the job owner, with the submit host stripped off.
+func (j Job) User() string {
+	return strings.Split(j.Owner, "@")[0]
+}
+
+// StateName returns the descriptive name of the job state.
+func (j Job) StateName() string {
+	if n, ok := jobStateNames[j.State]; ok {
+		return n
+	}
+	return j.State
+}
+
+// NumProcs returns the total number of processors requested by the job, derived from
+// the node specification, e.g. `2:ppn=4` results in 8.
+func (j Job) NumProcs() int {
+	reSpec := regexp.MustCompile(`^([0-9]+)(:ppn=([0-9]+))?`)
+
+	nprocs := 0
+	for _, spec := range strings.Split(j.ReqNodes, "+") {
+		m := reSpec.FindStringSubmatch(spec)
+		if m == nil {
+			// a node specified by hostname counts as one node.
+			if spec != "" {
+				nprocs++
+			}
+			continue
+		}
+		nnodes, _ := strconv.Atoi(m[1])
+		ppn := 1
+		if m[3] != "" {
+			ppn, _ = strconv.Atoi(m[3])
+		}
+		nprocs += nnodes * ppn
+	}
+	return nprocs
+}
+
+// Hosts returns the unique list of hosts on which the job is running.
+func (j Job) Hosts() []string {
+	hosts := []string{}
+	for _, h := range strings.Split(j.ExecHost, "+") {
+		h = strings.Split(h, "/")[0]
+		if h == "" {
+			continue
+		}
+		found := false
+		for _, e := range hosts {
+			if e == h {
+				found = true
+				break
+			}
+		}
+		if !found {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// SubmitTime returns the job submission time.
+func (j Job) SubmitTime() time.Time {
+	if j.QTime == 0 {
+		return time.Time{}
+	}
+	return time.Unix(j.QTime, 0)
+}
+
+// parseQstatXML parses the output of `qstat -x` and returns the Job data structure.
+func parseQstatXML(xmlData []byte) ([]Job, error) {
+	type data struct {
+		XMLName xml.Name `xml:"Data"`
+		Jobs    []Job    `xml:"Job"`
+	}
+
+	// no job in memory of the Torque server
+	if strings.TrimSpace(string(xmlData)) == "" {
+		return []Job{}, nil
+	}
+
+	d := data{}
+	if err := xml.Unmarshal(xmlData, &d); err != nil {
+		return nil, fmt.Errorf("cannot parse qstat XML output: %v", err)
+	}
+
+	return d.Jobs, nil
+}
+
+// GetJobs retrieves jobs in the memory of the Torque server via the Torque helper service.
+func GetJobs(c *trqhelper.TorqueHelperSrvClient) ([]Job, error) {
+
+	out, err := util.CaptureStdout(func() error {
+		return c.PrintClusterQstat(true)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parseQstatXML(out.Bytes())
+}
@@ -0,0 +1,34 @@
+package torque
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseQstatXML(t *testing.T) {
+	xmlData, err := os.ReadFile("testdata/qstat.xml")
+	if err != nil {
+		t.Fatalf("%s\n", err)
+	}
+
+	jobs, err := parseQstatXML(xmlData)
+	if err != nil {
+		t.Fatalf("%s\n", err)
+	}
+
+	if len(jobs) != 2 {
+		t.Fatalf("expect 2 jobs, got %d", len(jobs))
+	}
+
+	for _, j := range jobs {
+		t.Logf("job: %+v\n", j)
+	}
+
+	if j := jobs[0]; j.User() != "user1" || j.StateName() != "RUNNING" || j.NumProcs() != 4 || j.Hosts()[0] != "dccn-c005.dccn.nl" {
+		t.Errorf("unexpected job data: %+v", j)
+	}
+
+	if j := jobs[1]; j.NumProcs() != 16 {
+		t.Errorf("unexpected number of procs: %d", j.NumProcs())
+	}
+}
@@ -0,0 +1,290 @@
+// Package torquetest implements an in-process fake of the Torque helper services for the
+// integration tests of the commands interacting with the Torque cluster.
+//
+// The fake serves the `TorqueHelperSrvService`, `TorqueHelperMomService` and
+// `TorqueHelperAccService` gRPC services on the loopback interface with a self-signed TLS
+// certificate.  Responses of the gRPC methods are scripted by the test.  For example:
+//
+// ```
+// srv, _ := torquetest.NewServer()
+// defer srv.Close()
+//
+// srv.Reply("Qstat", "job list")
+// c := trqhelper.TorqueHelperSrvClient{SrvHost: srv.Host, SrvPort: srv.Port, SrvCertFile: srv.CertFile}
+// ```
+package torquetest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Response is the scripted `GeneralResponse` of a gRPC method.
+type Response struct {
+	Data     string
+	ExitCode int32
+	Error    string
+}
+
+// Handler returns the scripted response of a gRPC call.  The `arg` is the job or node id of
+// the request; it is empty for methods without argument, e.g. `Qstat`.
+type Handler func(arg string) Response
+
+// Call is a gRPC call received by the fake server.
+type Call struct {
+	// Method is the method name without the service, e.g. `Checknode`.
+	Method string
+	Arg    string
+}
+
+// Server is the fake Torque helper server.
+type Server struct {
+	// Host is the hostname of the server, i.e. `localhost`.  The server listens on the
+	// loopback address `127.0.0.1`.
+	Host string
+	// Port is the port the server is listening on.
+	Port int
+	// CertFile is the path of the server certificate to be used by the clients.
+	CertFile string
+
+	dir string
+	srv *grpc.Server
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	calls    []Call
+}
+
+// NewServer starts a fake Torque helper server on a random port of the loopback interface.
+// The self-signed certificate of the server is valid for `127.0.0.1` and `localhost`.
+func NewServer() (*Server, error) {
+
+	dir, err := os.MkdirTemp("", "torquetest")
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := generateCert(filepath.Join(dir, "server.pem"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	s := &Server{
+		Host:     "localhost",
+		Port:     l.Addr().(*net.TCPAddr).Port,
+		CertFile: filepath.Join(dir, "server.pem"),
+		dir:      dir,
+		handlers: make(map[string]Handler),
+	}
+
+	// the request and response messages are passed as raw bytes as the generated protobuf
+	// types of the Torque helper are internal to its module.
+	s.srv = grpc.NewServer(
+		grpc.Creds(credentials.NewServerTLSFromCert(&cert)),
+		grpc.ForceServerCodec(rawCodec{}),
+		grpc.UnknownServiceHandler(s.handle),
+	)
+
+	go s.srv.Serve(l)
+
+	return s, nil
+}
+
+// Handle scripts the response of the gRPC `method`, e.g. `Qstatx` or `JobMemInfo`.
+func (s *Server) Handle(method string, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = h
+}
+
+// Reply scripts the gRPC `method` to respond with `data` and exit code 0, regardless of the
+// argument of the request.
+func (s *Server) Reply(method, data string) {
+	s.Handle(method, func(arg string) Response {
+		return Response{Data: data}
+	})
+}
+
+// Calls returns the gRPC calls received by the server.
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call{}, s.calls...)
+}
+
+// Close stops the server and removes the server certificate.
+func (s *Server) Close() {
+	s.srv.Stop()
+	os.RemoveAll(s.dir)
+}
+
+// handle serves all the gRPC methods with the scripted responses.  Methods without response
+// result in the `Unimplemented` error.
+func (s *Server) handle(srv interface{}, stream grpc.ServerStream) error {
+
+	fullMethod, ok := grpc.MethodFromServerStream(stream)
+	if !ok {
+		return status.Error(codes.Internal, "unknown method")
+	}
+	method := path.Base(fullMethod)
+
+	var req []byte
+	if err := stream.RecvMsg(&req); err != nil {
+		return err
+	}
+
+	arg, err := requestArg(req)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "%s: %s", fullMethod, err)
+	}
+
+	s.mu.Lock()
+	s.calls = append(s.calls, Call{Method: method, Arg: arg})
+	h, ok := s.handlers[method]
+	s.mu.Unlock()
+
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "%s: no scripted response", fullMethod)
+	}
+
+	resp := h(arg).encode()
+	return stream.SendMsg(&resp)
+}
+
+// encode encodes the response into the wire format of the `GeneralResponse` message.
+func (r Response) encode() []byte {
+	var b []byte
+	if r.Data != "" {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, r.Data)
+	}
+	if r.ExitCode != 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(r.ExitCode))
+	}
+	if r.Error != "" {
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendString(b, r.Error)
+	}
+	return b
+}
+
+// requestArg returns the job or node id, i.e. the first field, of the `JobInfoRequest` or
+// `NodeInfoRequest` message in the wire format `b`.  It returns an empty string for the
+// `Empty` message.
+func requestArg(b []byte) (string, error) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return "", protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if num == 1 && typ == protowire.BytesType {
+			v, n := protowire.ConsumeString(b)
+			if n < 0 {
+				return "", protowire.ParseError(n)
+			}
+			return v, nil
+		}
+
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return "", protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return "", nil
+}
+
+// rawCodec passes the gRPC messages as raw bytes.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type: %T", v)
+	}
+	return *b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unexpected message type: %T", v)
+	}
+	*b = append([]byte{}, data...)
+	return nil
+}
+
+// Name returns `proto` as the raw bytes are in the protobuf wire format.
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+// generateCert generates a self-signed certificate for `127.0.0.1` and `localhost`, and
+// writes the certificate in PEM to the file `certFile`.
+func generateCert(certFile string) (tls.Certificate, error) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	tmpl := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "torquetest"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:              []string{"localhost"},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		return tls.Certificate{}, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
@@ -0,0 +1,51 @@
+package util
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+)
+
+// captureMu serialises the captures of the stdout.
+var captureMu sync.Mutex
+
+// CaptureStdout runs the function `f` and returns the data it writes to the stdout.
+//
+// It is used for retrieving data from library functions that only print their results
+// on the stdout, e.g. the printing functions of the Torque helper client.  As `os.Stdout` is
+// swapped during the capture, the captures are serialised, and the other output should be
+// written to an `io.Writer` obtained before, e.g. the output of the command, rather than
+// to `os.Stdout`.
+func CaptureStdout(f func() error) (stdout bytes.Buffer, err error) {
+
+	captureMu.Lock()
+	defer captureMu.Unlock()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return
+	}
+
+	orig := os.Stdout
+	os.Stdout = w
+
+	// drain the pipe in a go routine to avoid blocking `f` when the pipe buffer is full.
+	done := make(chan error)
+	go func() {
+		_, err := io.Copy(&stdout, r)
+		done <- err
+	}()
+
+	err = f()
+
+	os.Stdout = orig
+	w.Close()
+
+	if cerr := <-done; cerr != nil && err == nil {
+		err = cerr
+	}
+	r.Close()
+
+	return
+}
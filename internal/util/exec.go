@@ -0,0 +1,188 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ErrOutputLimit is the cause of the `ExecError` when the stdout of the command exceeds
+// `Executor.MaxOutputBytes`.
+var ErrOutputLimit = errors.New("output size limit exceeded")
+
+// ExecError is the error of a system call that cannot be started, exits with a non-zero
+// exit code, or is terminated because of the timeout, cancellation or the output size limit.
+type ExecError struct {
+	Cmd  string
+	Args []string
+	// ExitCode is the exit code of the command; it is -1 if the command is not started or
+	// terminated by a signal.
+	ExitCode int
+	// Stderr is the (trimmed) stderr of the command.
+	Stderr string
+	// Err is the underlying error, e.g. `context.DeadlineExceeded` or `ErrOutputLimit`.
+	Err error
+}
+
+// Error returns the error message with the stderr of the command if available.
+func (e *ExecError) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.Cmd, e.Err)
+	if e.ExitCode > 0 {
+		msg = fmt.Sprintf("%s: exit code %d", e.Cmd, e.ExitCode)
+	}
+	if e.Stderr != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Stderr)
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// Executor executes system calls with a timeout and a limit on the output size.
+type Executor struct {
+	// Timeout is the default timeout of a system call; 0 means no timeout.
+	Timeout time.Duration
+	// Timeouts are the timeouts by the command name, overriding the default `Timeout`.
+	Timeouts map[string]time.Duration
+	// MaxOutputBytes is the maximum size of the stdout of a system call; 0 means no limit.
+	MaxOutputBytes int64
+}
+
+// DefaultExecutor is the `Executor` used by the `DefaultRunner`.
+var DefaultExecutor = &Executor{
+	Timeout:        time.Minute,
+	Timeouts:       map[string]time.Duration{},
+	MaxOutputBytes: 64 << 20,
+}
+
+// timeout returns the timeout of the command `name`.
+func (e *Executor) timeout(name string) time.Duration {
+	if t, ok := e.Timeouts[name]; ok {
+		return t
+	}
+	return e.Timeout
+}
+
+// Run executes the command `name` with arguments `args`, and returns the stdout.  The command
+// is killed when `ctx` is done, the timeout is reached or the stdout exceeds the size limit.
+//
+// The returned error is an `*ExecError` if the command does not finish successfully.
+func (e *Executor) Run(ctx context.Context, name string, args []string) (stdout bytes.Buffer, err error) {
+
+	if t := e.timeout(name); t > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t)
+		defer cancel()
+	}
+
+	// the context is cancelled when the stdout size limit is exceeded.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	lw := &limitWriter{w: &stdout, n: e.MaxOutputBytes, exceed: cancel}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = os.Environ()
+	cmd.Stdout = lw
+	cmd.Stderr = &stderr
+
+	rerr := cmd.Run()
+	if rerr == nil {
+		return stdout, nil
+	}
+
+	xerr := &ExecError{
+		Cmd:      name,
+		Args:     args,
+		ExitCode: -1,
+		Stderr:   strings.TrimSpace(stderr.String()),
+		Err:      rerr,
+	}
+
+	switch {
+	case lw.exceeded:
+		xerr.Err = ErrOutputLimit
+	case ctx.Err() != nil:
+		xerr.Err = ctx.Err()
+	default:
+		var exitError *exec.ExitError
+		if errors.As(rerr, &exitError) {
+			xerr.ExitCode = exitError.ExitCode()
+		}
+	}
+
+	return stdout, xerr
+}
+
+// CallContext makes the call `f`, which does not support the context, e.g. a call of the
+// Torque helper client, and returns its result.  The timeout of the `DefaultExecutor` for
+// the name `name` applies.  When `ctx` is done or the timeout is reached before `f` returns,
+// an `*ExecError` is returned and `f` is left running in the background.
+func CallContext[T any](ctx context.Context, name string, f func() (T, error)) (T, error) {
+
+	if t := DefaultExecutor.timeout(name); t > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t)
+		defer cancel()
+	}
+
+	type result struct {
+		v   T
+		err error
+	}
+
+	c := make(chan result, 1)
+	go func() {
+		v, err := f()
+		c <- result{v, err}
+	}()
+
+	select {
+	case r := <-c:
+		return r.v, r.err
+	case <-ctx.Done():
+		var v T
+		return v, &ExecError{Cmd: name, ExitCode: -1, Err: ctx.Err()}
+	}
+}
+
+// ExecCmdContext executes a system call with the `DefaultRunner` and returns the stdout.
+// The returned error is an `*ExecError` carrying the exit code and the stderr if the command
+// does not finish successfully.
+func ExecCmdContext(ctx context.Context, cmdName string, cmdArgs []string) (stdout bytes.Buffer, err error) {
+	return DefaultRunner.Run(ctx, cmdName, cmdArgs)
+}
+
+// limitWriter writes up to `n` bytes to `w`; it calls `exceed` once when more data is
+// written.  No limit is applied if `n` is 0.
+type limitWriter struct {
+	w        io.Writer
+	n        int64
+	written  int64
+	exceed   func()
+	exceeded bool
+}
+
+func (l *limitWriter) Write(p []byte) (int, error) {
+	if l.exceeded {
+		return 0, ErrOutputLimit
+	}
+	if l.n > 0 && l.written+int64(len(p)) > l.n {
+		l.exceeded = true
+		l.exceed()
+		return 0, ErrOutputLimit
+	}
+	n, err := l.w.Write(p)
+	l.written += int64(n)
+	return n, err
+}
@@ -0,0 +1,123 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExecutorRun(t *testing.T) {
+
+	e := &Executor{}
+
+	stdout, err := e.Run(context.Background(), "sh", []string{"-c", "echo hello"})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if stdout.String() != "hello\n" {
+		t.Errorf("unexpected stdout: %s", stdout.String())
+	}
+}
+
+func TestExecutorRunExitCode(t *testing.T) {
+
+	e := &Executor{}
+
+	_, err := e.Run(context.Background(), "sh", []string{"-c", "echo oops >&2; exit 3"})
+	t.Logf("%s", err)
+
+	var xerr *ExecError
+	if !errors.As(err, &xerr) {
+		t.Fatalf("expect ExecError, got %v", err)
+	}
+	if xerr.ExitCode != 3 || xerr.Stderr != "oops" {
+		t.Errorf("unexpected exit code %d or stderr %s", xerr.ExitCode, xerr.Stderr)
+	}
+
+	_, err = e.Run(context.Background(), "hpcutil-no-such-command", nil)
+	t.Logf("%s", err)
+	if !errors.As(err, &xerr) || xerr.ExitCode != -1 {
+		t.Errorf("expect ExecError with exit code -1, got %v", err)
+	}
+}
+
+func TestExecutorRunTimeout(t *testing.T) {
+
+	e := &Executor{
+		Timeout:  time.Minute,
+		Timeouts: map[string]time.Duration{"sleep": 100 * time.Millisecond},
+	}
+
+	start := time.Now()
+	_, err := e.Run(context.Background(), "sleep", []string{"10"})
+	t.Logf("%s", err)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expect deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("command is not terminated at the timeout")
+	}
+}
+
+func TestExecutorRunCancel(t *testing.T) {
+
+	e := &Executor{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	_, err := e.Run(ctx, "sleep", []string{"10"})
+	t.Logf("%s", err)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expect context canceled, got %v", err)
+	}
+}
+
+func TestExecutorRunOutputLimit(t *testing.T) {
+
+	e := &Executor{MaxOutputBytes: 1024}
+
+	stdout, err := e.Run(context.Background(), "sh", []string{"-c", "yes"})
+	t.Logf("%s", err)
+
+	if !errors.Is(err, ErrOutputLimit) {
+		t.Errorf("expect output limit error, got %v", err)
+	}
+	if stdout.Len() > 1024 {
+		t.Errorf("stdout exceeds the limit: %d", stdout.Len())
+	}
+}
+
+func TestCallContext(t *testing.T) {
+
+	defer func(e *Executor) { DefaultExecutor = e }(DefaultExecutor)
+	DefaultExecutor = &Executor{Timeouts: map[string]time.Duration{"hang": 100 * time.Millisecond}}
+
+	v, err := CallContext(context.Background(), "call", func() (string, error) {
+		return "done", nil
+	})
+	if err != nil || v != "done" {
+		t.Errorf("unexpected result: %s, %v", v, err)
+	}
+
+	// the call not returning is abandoned at the timeout
+	block := make(chan struct{})
+	defer close(block)
+
+	start := time.Now()
+	_, err = CallContext(context.Background(), "hang", func() (string, error) {
+		<-block
+		return "", nil
+	})
+	t.Logf("%s", err)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expect deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("call is not abandoned at the timeout")
+	}
+}
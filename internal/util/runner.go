@@ -0,0 +1,204 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Runner runs a system call and returns the stdout.  The returned error is an `*ExecError`
+// if the command exits with a non-zero exit code.
+type Runner interface {
+	Run(ctx context.Context, name string, args []string) (stdout bytes.Buffer, err error)
+}
+
+// DefaultRunner is the `Runner` used by `ExecCmd` and `ExecCmdContext`.  It can be replaced
+// by a `RecordRunner` to record outputs of the system calls into golden files, or by a
+// `ReplayRunner` to replay the recorded outputs, e.g. in tests.
+var DefaultRunner Runner = DefaultExecutor
+
+// RecordRunner runs the system calls with `Runner` and records the outputs into golden files
+// in the directory `Dir`.  Outputs of commands that cannot be started or are terminated are
+// not recorded.
+type RecordRunner struct {
+	Runner Runner
+	Dir    string
+}
+
+// Run runs the command with the underlying `Runner` and records the output.
+func (r *RecordRunner) Run(ctx context.Context, name string, args []string) (stdout bytes.Buffer, err error) {
+
+	stdout, err = r.Runner.Run(ctx, name, args)
+
+	g := golden{Command: commandLine(name, args), Stdout: stdout.String()}
+
+	var xerr *ExecError
+	if errors.As(err, &xerr) {
+		if xerr.ExitCode <= 0 {
+			return stdout, err
+		}
+		g.ExitCode = xerr.ExitCode
+		g.Stderr = xerr.Stderr
+	} else if err != nil {
+		return stdout, err
+	}
+
+	if werr := g.write(goldenFile(r.Dir, name, args)); werr != nil {
+		return stdout, fmt.Errorf("cannot record %s: %s", g.Command, werr)
+	}
+
+	return stdout, err
+}
+
+// ReplayRunner replays the outputs of the system calls recorded in the golden files in the
+// directory `Dir`, without executing the commands.
+type ReplayRunner struct {
+	Dir string
+}
+
+// Run returns the recorded output of the command.
+func (r *ReplayRunner) Run(ctx context.Context, name string, args []string) (stdout bytes.Buffer, err error) {
+
+	if err := ctx.Err(); err != nil {
+		return stdout, &ExecError{Cmd: name, Args: args, ExitCode: -1, Err: err}
+	}
+
+	p := goldenFile(r.Dir, name, args)
+
+	g, err := readGolden(p)
+	if err != nil {
+		return stdout, &ExecError{
+			Cmd:      name,
+			Args:     args,
+			ExitCode: -1,
+			Err:      fmt.Errorf("no recorded output of %s: %s", commandLine(name, args), err),
+		}
+	}
+
+	stdout.WriteString(g.Stdout)
+
+	if g.ExitCode != 0 {
+		return stdout, &ExecError{
+			Cmd:      name,
+			Args:     args,
+			ExitCode: g.ExitCode,
+			Stderr:   g.Stderr,
+			Err:      fmt.Errorf("exit status %d", g.ExitCode),
+		}
+	}
+
+	return stdout, nil
+}
+
+// golden defines the recorded output of a system call.
+//
+// In the golden file, the command line, the exit code and the (quoted) stderr are given in
+// the header, followed by the stdout after the `---` line.  For example:
+//
+// ```
+// command: scontrol show partition gpu
+// exit: 0
+// stderr: ""
+// ---
+// PartitionName=gpu
+// ...
+// ```
+type golden struct {
+	Command  string
+	ExitCode int
+	Stderr   string
+	Stdout   string
+}
+
+// goldenFile returns the path of the golden file of the command `name` with `args` in the
+// directory `dir`.  The file name is made of the command name and a hash of the arguments.
+func goldenFile(dir, name string, args []string) string {
+	h := sha1.Sum([]byte(strings.Join(append([]string{name}, args...), "\x00")))
+	return filepath.Join(dir, fmt.Sprintf("%s-%x.golden", filepath.Base(name), h[:5]))
+}
+
+// commandLine returns the command line of the command `name` with `args`, with arguments
+// containing spaces or quotes being quoted.
+func commandLine(name string, args []string) string {
+	elems := []string{name}
+	for _, a := range args {
+		if a == "" || strings.ContainsAny(a, " \t\n\"'") {
+			a = strconv.Quote(a)
+		}
+		elems = append(elems, a)
+	}
+	return strings.Join(elems, " ")
+}
+
+// write writes the golden file to the path `p`.
+func (g golden) write(p string) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "command: %s\n", g.Command)
+	fmt.Fprintf(&buf, "exit: %d\n", g.ExitCode)
+	fmt.Fprintf(&buf, "stderr: %s\n", strconv.Quote(g.Stderr))
+	fmt.Fprintf(&buf, "---\n")
+	buf.WriteString(g.Stdout)
+
+	return os.WriteFile(p, buf.Bytes(), 0644)
+}
+
+// readGolden reads the golden file from the path `p`.
+func readGolden(p string) (golden, error) {
+
+	var g golden
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return g, err
+	}
+
+	reader := bufio.NewReader(bytes.NewReader(data))
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return g, fmt.Errorf("invalid golden file %s: header is not terminated", p)
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		if line == "---" {
+			break
+		}
+
+		kv := strings.SplitN(line, ": ", 2)
+		if len(kv) != 2 {
+			return g, fmt.Errorf("invalid golden file %s: %s", p, line)
+		}
+
+		switch kv[0] {
+		case "command":
+			g.Command = kv[1]
+		case "exit":
+			if g.ExitCode, err = strconv.Atoi(kv[1]); err != nil {
+				return g, fmt.Errorf("invalid golden file %s: %s", p, line)
+			}
+		case "stderr":
+			if g.Stderr, err = strconv.Unquote(kv[1]); err != nil {
+				return g, fmt.Errorf("invalid golden file %s: %s", p, line)
+			}
+		}
+	}
+
+	var stdout bytes.Buffer
+	if _, err := stdout.ReadFrom(reader); err != nil {
+		return g, err
+	}
+	g.Stdout = stdout.String()
+
+	return g, nil
+}
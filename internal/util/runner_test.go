@@ -0,0 +1,52 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+
+	dir := t.TempDir()
+
+	rec := &RecordRunner{Runner: &Executor{}, Dir: dir}
+	rep := &ReplayRunner{Dir: dir}
+
+	cases := []struct {
+		args     []string
+		stdout   string
+		exitCode int
+		stderr   string
+	}{
+		{[]string{"-c", "printf 'line 1\\nline 2\\n'"}, "line 1\nline 2\n", 0, ""},
+		{[]string{"-c", "echo partial; echo 'not found' >&2; exit 2"}, "partial\n", 2, "not found"},
+	}
+
+	for _, c := range cases {
+		if _, err := rec.Run(context.Background(), "sh", c.args); err != nil && c.exitCode == 0 {
+			t.Fatalf("%s", err)
+		}
+
+		stdout, err := rep.Run(context.Background(), "sh", c.args)
+		t.Logf("%q: %v", stdout.String(), err)
+
+		if stdout.String() != c.stdout {
+			t.Errorf("unexpected replayed stdout: %q", stdout.String())
+		}
+
+		var xerr *ExecError
+		if c.exitCode == 0 {
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		} else if !errors.As(err, &xerr) || xerr.ExitCode != c.exitCode || xerr.Stderr != c.stderr {
+			t.Errorf("unexpected replayed error: %v", err)
+		}
+	}
+
+	// commands not recorded
+	if _, err := rep.Run(context.Background(), "sh", []string{"-c", "true"}); err == nil {
+		t.Errorf("expect error for command not recorded")
+	}
+}
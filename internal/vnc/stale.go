@@ -0,0 +1,140 @@
+package vnc
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Report is the staleness report of a VNC server.
+type Report struct {
+	Server
+	// Idle is the time since the last activity of the VNC server, or since the start if no
+	// activity is known.  It is 0 if neither the activity nor the start time is known.
+	Idle time.Duration
+	// IdleUnknown is true if neither the activity nor the start time of the VNC server is
+	// known, e.g. for the server found by the Torque helper.  The idle check is not applied
+	// to the server.
+	IdleUnknown bool
+	// Sessions is the number of the VNC servers of the owner across the hosts.
+	Sessions int
+	// Reasons are the reasons why the VNC server is stale, e.g. `idle for 240h0m0s`.  It is
+	// empty if the VNC server is not stale.
+	Reasons []string
+}
+
+// Stale reports the staleness of the VNC `servers` at the time `now`.  A VNC server is stale if
+// it is idle for longer than `maxIdle`, or if its owner holds more than `maxSessions` VNC
+// servers across the hosts.  A zero `maxIdle` or `maxSessions` disables the check.  A server
+// of which the idle time is unknown is not checked for idleness; it is marked by `IdleUnknown`
+// so that it is not mistaken for an active server.  The reports are given in the order of the
+// `servers`.
+func Stale(servers []Server, now time.Time, maxIdle time.Duration, maxSessions int) []Report {
+
+	sessions := make(map[string]int)
+	for _, s := range servers {
+		sessions[s.Owner]++
+	}
+
+	reports := make([]Report, 0, len(servers))
+	for _, s := range servers {
+
+		r := Report{Server: s, Sessions: sessions[s.Owner], Reasons: []string{}}
+
+		last := s.LastActive
+		if last.IsZero() || s.StartTime.After(last) {
+			last = s.StartTime
+		}
+		if last.IsZero() {
+			r.IdleUnknown = true
+		} else if now.After(last) {
+			r.Idle = now.Sub(last)
+		}
+
+		if maxIdle > 0 && r.Idle > maxIdle {
+			r.Reasons = append(r.Reasons, fmt.Sprintf("idle for %s", r.Idle.Round(time.Hour)))
+		}
+		if maxSessions > 0 && r.Sessions > maxSessions {
+			r.Reasons = append(r.Reasons, fmt.Sprintf("%d sessions of %s", r.Sessions, s.Owner))
+		}
+
+		reports = append(reports, r)
+	}
+
+	return reports
+}
+
+// UserSummary is the summary of the VNC servers of a user.
+type UserSummary struct {
+	User string
+	// Sessions is the number of the VNC servers of the user.
+	Sessions int
+	// Stale is the number of the stale VNC servers of the user.
+	Stale int
+	// IdleUnknown is the number of the VNC servers of the user of which the idle time is
+	// unknown.
+	IdleUnknown int
+	// Hosts are the hosts on which the user has VNC servers.
+	Hosts []string
+	// OldestStart is the start time of the oldest VNC server; it is zero if unknown.
+	OldestStart time.Time
+	// MaxIdle is the longest known idle time of the VNC servers.
+	MaxIdle time.Duration
+	// CPUTime is the total CPU time consumed by the VNC servers, as far as known.
+	CPUTime time.Duration
+}
+
+// SummarizeUsers summarizes the `reports` per user.  The summaries are ordered by the number
+// of the stale VNC servers, then by the number of the VNC servers, in descending order, and
+// then by the username.
+func SummarizeUsers(reports []Report) []UserSummary {
+
+	summaries := make(map[string]*UserSummary)
+	hosts := make(map[string]map[string]bool)
+
+	for _, r := range reports {
+		u, ok := summaries[r.Owner]
+		if !ok {
+			u = &UserSummary{User: r.Owner, Hosts: []string{}}
+			summaries[r.Owner] = u
+			hosts[r.Owner] = make(map[string]bool)
+		}
+
+		u.Sessions++
+		if len(r.Reasons) > 0 {
+			u.Stale++
+		}
+		if r.IdleUnknown {
+			u.IdleUnknown++
+		}
+		if h, _, err := SplitID(r.ID); err == nil && !hosts[r.Owner][h] {
+			hosts[r.Owner][h] = true
+			u.Hosts = append(u.Hosts, h)
+		}
+		if !r.StartTime.IsZero() && (u.OldestStart.IsZero() || r.StartTime.Before(u.OldestStart)) {
+			u.OldestStart = r.StartTime
+		}
+		if r.Idle > u.MaxIdle {
+			u.MaxIdle = r.Idle
+		}
+		u.CPUTime += r.CPUTime
+	}
+
+	list := make([]UserSummary, 0, len(summaries))
+	for _, u := range summaries {
+		sort.Strings(u.Hosts)
+		list = append(list, *u)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Stale != list[j].Stale {
+			return list[i].Stale > list[j].Stale
+		}
+		if list[i].Sessions != list[j].Sessions {
+			return list[i].Sessions > list[j].Sessions
+		}
+		return list[i].User < list[j].User
+	})
+
+	return list
+}
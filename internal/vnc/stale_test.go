@@ -0,0 +1,56 @@
+package vnc
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStale(t *testing.T) {
+
+	now := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+
+	servers := []Server{
+		// active an hour ago
+		{ID: "mentat001.dccn.nl:51", Owner: "user1", StartTime: now.Add(-240 * time.Hour), LastActive: now.Add(-time.Hour), CPUTime: time.Hour},
+		// never connected since the start
+		{ID: "mentat002.dccn.nl:3", Owner: "user1", StartTime: now.Add(-100 * time.Hour)},
+		{ID: "mentat002.dccn.nl:9", Owner: "user1"},
+		// unknown start and activity, e.g. by the Torque helper
+		{ID: "mentat001.dccn.nl:12", Owner: "user2"},
+	}
+
+	reports := Stale(servers, now, 72*time.Hour, 2)
+
+	for _, r := range reports {
+		t.Logf("report: %+v", r)
+	}
+
+	if r := reports[0]; r.Idle != time.Hour || len(r.Reasons) != 1 || r.Reasons[0] != "3 sessions of user1" {
+		t.Errorf("unexpected report: %+v", r)
+	}
+	if r := reports[1]; r.Idle != 100*time.Hour || len(r.Reasons) != 2 || !strings.HasPrefix(r.Reasons[0], "idle for 100h") {
+		t.Errorf("unexpected report: %+v", r)
+	}
+	// the zero times are reported as unknown idle time rather than being active
+	if r := reports[2]; !r.IdleUnknown || len(r.Reasons) != 1 {
+		t.Errorf("unexpected report: %+v", r)
+	}
+	if r := reports[3]; r.Idle != 0 || !r.IdleUnknown || r.Sessions != 1 || len(r.Reasons) != 0 {
+		t.Errorf("unexpected report: %+v", r)
+	}
+	if r := reports[0]; r.IdleUnknown {
+		t.Errorf("unexpected report: %+v", r)
+	}
+
+	summaries := SummarizeUsers(reports)
+	if len(summaries) != 2 {
+		t.Fatalf("expect 2 users, got %d", len(summaries))
+	}
+	if u := summaries[0]; u.User != "user1" || u.Sessions != 3 || u.Stale != 3 || len(u.Hosts) != 2 || !u.OldestStart.Equal(now.Add(-240*time.Hour)) || u.MaxIdle != 100*time.Hour || u.CPUTime != time.Hour {
+		t.Errorf("unexpected user summary: %+v", u)
+	}
+	if u := summaries[1]; u.User != "user2" || u.Stale != 0 || u.IdleUnknown != 1 {
+		t.Errorf("unexpected user summary: %+v", u)
+	}
+}
@@ -0,0 +1,409 @@
+// Package vnc implements the discovery of VNC servers without the Torque helper service on
+// the access nodes.  The VNC servers are found by inspecting the processes in `/proc` of the
+// current host, or by querying the interactive Slurm jobs running a VNC server.
+package vnc
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Donders-Institute/hpc-utility/internal/hostlist"
+	"github.com/Donders-Institute/hpc-utility/internal/slurm"
+	log "github.com/sirupsen/logrus"
+)
+
+// Server defines data structure of a VNC server, in the same way as `VNCServer` of the Torque
+// helper client.
+type Server struct {
+	// ID is the VNC server id, e.g. mentat001.dccn.nl:1
+	ID string
+	// Owner is the VNC server owner's user id
+	Owner string
+	// PID is the process id of the VNC server; it is 0 if the server is found by the Slurm
+	// job.
+	PID int
+	// JobID is the id of the Slurm job running the VNC server; it is empty if the server is
+	// found in `/proc`.
+	JobID string
+	// StartTime is the time the VNC server is started; it is zero if unknown, e.g. for the
+	// server found by the Torque helper.
+	StartTime time.Time
+	// LastActive is the last time a VNC client connected to or disconnected from the server;
+	// it is zero if unknown.
+	LastActive time.Time
+	// CPUTime is the CPU time consumed by the VNC session; it is 0 if unknown.
+	CPUTime time.Duration
+}
+
+// serverBinaries are the names of the executables of the Xvnc-based VNC servers, e.g.
+// TigerVNC and TurboVNC.
+var serverBinaries = map[string]bool{
+	"Xvnc":      true,
+	"Xvnc-core": true,
+	"Xtigervnc": true,
+	"Xtightvnc": true,
+}
+
+// reDisplay matches the display argument of the VNC server, e.g. `:51`.
+var reDisplay = regexp.MustCompile(`^:(\d+)$`)
+
+// reVNCComment matches the comment of a Slurm job running a VNC server, e.g. `vnc:5` for the
+// VNC server on display `:5` of the job's node.
+var reVNCComment = regexp.MustCompile(`^vnc:(\d+)$`)
+
+// clockTicks is the number of clock ticks per second (`USER_HZ`) in which the CPU and start
+// times of the processes are given in the proc filesystem.
+const clockTicks = 100
+
+// logDirs are the directories in the home directory of the owner in which the VNC server
+// writes its log file, i.e. `~/.vnc` of TurboVNC and TigerVNC, and `~/.local/state/tigervnc`
+// of the recent TigerVNC versions.
+var logDirs = []string{".vnc", filepath.Join(".local", "state", "tigervnc")}
+
+// userHome returns the home directory of the user `uid`.
+var userHome = func(uid string) string {
+	if u, err := user.LookupId(uid); err == nil {
+		return u.HomeDir
+	}
+	return ""
+}
+
+// process defines the information of a process in the proc filesystem.
+type process struct {
+	pid int
+	uid string
+	// display is the X display of the process given by the `DISPLAY` environment variable;
+	// it is -1 if the variable is not set or not readable.
+	display   int
+	cpuTime   time.Duration
+	startTime time.Time
+}
+
+// ListProcServers returns the VNC servers running on the current host, found by inspecting
+// the command line of the processes in the proc filesystem mounted at `procDir`, e.g.
+// `/proc`.  The servers are identified by `host`, e.g. the FQDN of the current host.
+//
+// The CPU time of a VNC server is the CPU time of the server and the processes of the owner on
+// the display of the server, e.g. the desktop and the applications.  The last activity is the
+// last modification of the VNC log file, in which the connections of the VNC clients are
+// logged.
+func ListProcServers(procDir, host string) ([]Server, error) {
+
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// the boot time for the start time of the processes
+	btime, err := bootTime(filepath.Join(procDir, "stat"))
+	if err != nil {
+		log.Debugf("cannot get boot time: %s", err)
+	}
+
+	procs := []process{}
+	servers := []Server{}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+
+		// the process may have been terminated in the mean time
+		uid, err := processUID(filepath.Join(procDir, e.Name(), "status"))
+		if err != nil {
+			log.Debugf("cannot get owner of process %d: %s", pid, err)
+			continue
+		}
+
+		p := process{pid: pid, uid: uid, display: -1}
+		if stat, err := os.ReadFile(filepath.Join(procDir, e.Name(), "stat")); err == nil {
+			p.cpuTime, p.startTime = parseStat(stat, btime)
+		}
+		if environ, err := os.ReadFile(filepath.Join(procDir, e.Name(), "environ")); err == nil {
+			p.display = parseEnvironDisplay(environ)
+		}
+		procs = append(procs, p)
+
+		cmdline, err := os.ReadFile(filepath.Join(procDir, e.Name(), "cmdline"))
+		if err != nil {
+			continue
+		}
+
+		display, ok := parseCmdline(cmdline)
+		if !ok {
+			continue
+		}
+
+		servers = append(servers, Server{
+			ID:        fmt.Sprintf("%s:%d", host, display),
+			Owner:     username(uid),
+			PID:       pid,
+			StartTime: p.startTime,
+		})
+	}
+
+	for i := range servers {
+		_, display, _ := SplitID(servers[i].ID)
+
+		var uid string
+		for _, p := range procs {
+			if p.pid == servers[i].PID {
+				uid = p.uid
+			}
+		}
+
+		for _, p := range procs {
+			if p.uid == uid && (p.pid == servers[i].PID || p.display == display) {
+				servers[i].CPUTime += p.cpuTime
+			}
+		}
+
+		servers[i].LastActive = lastLogTime(userHome(uid), host, display)
+	}
+
+	sort.Slice(servers, func(i, j int) bool {
+		return servers[i].PID < servers[j].PID
+	})
+
+	return servers, nil
+}
+
+// bootTime returns the boot time of the system from the `btime` line of the `stat` file in
+// the proc filesystem.
+func bootTime(stat string) (time.Time, error) {
+
+	f, err := os.Open(stat)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 && fields[0] == "btime" {
+			secs, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid btime: %s", fields[1])
+			}
+			return time.Unix(secs, 0), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Time{}, fmt.Errorf("no btime in %s", stat)
+}
+
+// parseStat returns the CPU time (user and system) and the start time of a process from its
+// `stat` file in the proc filesystem, e.g. `1552 (Xvnc) S 1 ... 420 35 ... 81234 ...`.  The
+// start time is zero if the boot time `btime` is unknown.
+func parseStat(stat []byte, btime time.Time) (time.Duration, time.Time) {
+
+	// the command name in parentheses may contain spaces
+	s := string(stat)
+	i := strings.LastIndex(s, ")")
+	if i < 0 {
+		return 0, time.Time{}
+	}
+
+	// the fields after the command name, starting from the state (the 3rd field)
+	fields := strings.Fields(s[i+1:])
+	if len(fields) < 20 {
+		return 0, time.Time{}
+	}
+
+	utime, _ := strconv.ParseInt(fields[11], 10, 64)
+	stime, _ := strconv.ParseInt(fields[12], 10, 64)
+	cpu := time.Duration(utime+stime) * time.Second / clockTicks
+
+	if btime.IsZero() {
+		return cpu, time.Time{}
+	}
+
+	start, _ := strconv.ParseInt(fields[19], 10, 64)
+	return cpu, btime.Add(time.Duration(start) * time.Second / clockTicks)
+}
+
+// reEnvDisplay matches the display number of a local X display in the `DISPLAY` environment
+// variable, e.g. `:51` or `:51.0`.  Remote displays, e.g. `localhost:10.0` of the SSH X11
+// forwarding, are not matched.
+var reEnvDisplay = regexp.MustCompile(`^(?:unix)?:(\d+)(\.\d+)?$`)
+
+// parseEnvironDisplay returns the X display from the NUL-separated environment variables of a
+// process, or -1 if the `DISPLAY` variable is not set.
+func parseEnvironDisplay(environ []byte) int {
+	for _, env := range strings.Split(string(environ), "\x00") {
+		if !strings.HasPrefix(env, "DISPLAY=") {
+			continue
+		}
+		if m := reEnvDisplay.FindStringSubmatch(strings.TrimPrefix(env, "DISPLAY=")); m != nil {
+			display, _ := strconv.Atoi(m[1])
+			return display
+		}
+	}
+	return -1
+}
+
+// lastLogTime returns the last modification time of the log files of the VNC server on the
+// `host` and `display` in the `home` directory, e.g. `~/.vnc/mentat001.dccn.nl:51.log`.  The
+// log file is named by either the short hostname or the FQDN; the logs of the same display on
+// other hosts sharing the home directory are not taken.  It returns the zero time if no log
+// file is found.
+func lastLogTime(home, host string, display int) time.Time {
+
+	var last time.Time
+
+	if home == "" {
+		return last
+	}
+
+	short := hostlist.Short(host)
+	patterns := []string{
+		fmt.Sprintf("%s:%d.log", short, display),
+		fmt.Sprintf("%s.*:%d.log", short, display),
+	}
+
+	for _, dir := range logDirs {
+		files := []string{}
+		for _, p := range patterns {
+			matches, _ := filepath.Glob(filepath.Join(home, dir, p))
+			files = append(files, matches...)
+		}
+		for _, f := range files {
+			if fi, err := os.Stat(f); err == nil && fi.ModTime().After(last) {
+				last = fi.ModTime()
+			}
+		}
+	}
+
+	return last
+}
+
+// SplitID splits the VNC server id, e.g. `mentat001.dccn.nl:1`, into the host and the display.
+func SplitID(id string) (string, int, error) {
+	i := strings.LastIndex(id, ":")
+	if i <= 0 {
+		return "", 0, fmt.Errorf("invalid VNC server id: %s", id)
+	}
+	display, err := strconv.ParseUint(id[i+1:], 10, 32)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid VNC server id: %s", id)
+	}
+	return id[:i], int(display), nil
+}
+
+// parseCmdline returns the display of the VNC server from the NUL-separated command line of a
+// process, e.g. `/usr/bin/Xvnc\x00:51\x00-auth\x00...`.  It returns false if the process is not
+// a VNC server.
+func parseCmdline(cmdline []byte) (int, bool) {
+
+	args := strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+	if !serverBinaries[filepath.Base(args[0])] {
+		return 0, false
+	}
+
+	return displayArg(args[1:])
+}
+
+// displayArg returns the display given by the arguments `args` of a VNC server, e.g. `:51`.
+// It returns false if no display is given.
+func displayArg(args []string) (int, bool) {
+	for _, arg := range args {
+		if m := reDisplay.FindStringSubmatch(arg); m != nil {
+			display, _ := strconv.Atoi(m[1])
+			return display, true
+		}
+	}
+	return 0, false
+}
+
+// jobDisplay returns the display of the VNC server run by the Slurm job `j`.  The job runs a
+// VNC server if its command is a VNC server or `vncserver` with the display, e.g.
+// `salloc /usr/bin/Xvnc :5` or `salloc vncserver -fg :5`, or otherwise if it has the comment
+// `vnc:{display}`, e.g. `salloc --comment=vnc:5`.  It returns false if the job does not run a
+// VNC server.
+func jobDisplay(j slurm.RunningJob) (int, bool) {
+
+	if args := strings.Fields(j.Command); len(args) > 0 {
+		if bin := filepath.Base(args[0]); serverBinaries[bin] || bin == "vncserver" {
+			if display, ok := displayArg(args[1:]); ok {
+				return display, true
+			}
+		}
+	}
+
+	if m := reVNCComment.FindStringSubmatch(j.Comment); m != nil {
+		display, _ := strconv.Atoi(m[1])
+		return display, true
+	}
+
+	return 0, false
+}
+
+// processUID returns the real user id of the process from its `status` file in the proc
+// filesystem, e.g. the line `Uid:	1000	1000	1000	1000`.
+func processUID(status string) (string, error) {
+
+	f, err := os.Open(status)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 1 && fields[0] == "Uid:" {
+			return fields[1], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("no Uid in %s", status)
+}
+
+// username returns the name of the user `uid`, or the `uid` itself if the user is unknown.
+func username(uid string) string {
+	if u, err := user.LookupId(uid); err == nil {
+		return u.Username
+	}
+	return uid
+}
+
+// ListSlurmServers returns the VNC servers run by the running Slurm jobs, i.e. the jobs of
+// which the command is a VNC server, or with the comment `vnc:{display}`.  The servers are
+// identified by the FQDN of the job's (first) node in the network `domain`.
+func ListSlurmServers(ctx context.Context, domain string) ([]Server, error) {
+
+	jobs, err := slurm.GetRunningJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	servers := make([]Server, 0, len(jobs))
+	for _, j := range jobs {
+		display, ok := jobDisplay(j)
+		if !ok {
+			continue
+		}
+		servers = append(servers, Server{
+			ID:        fmt.Sprintf("%s:%d", hostlist.FQDN(j.Node, domain), display),
+			Owner:     j.User,
+			JobID:     j.ID,
+			StartTime: j.StartTime,
+		})
+	}
+
+	return servers, nil
+}
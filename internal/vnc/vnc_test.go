@@ -0,0 +1,192 @@
+package vnc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Donders-Institute/hpc-utility/internal/util"
+)
+
+var (
+	// processes in the proc filesystem, with the command line, the real user id, the stat and
+	// the environment variables.
+	procs = map[string][4]string{
+		"1552":  {"/usr/bin/Xvnc\x00:51\x00-auth\x00/root/.Xauthority\x00-geometry\x001920x1080\x00", "0", statOf(1552, "Xvnc", 1000, 200, 360000), ""},
+		"1560":  {"/usr/bin/xfce4-session\x00", "0", statOf(1560, "xfce4-session", 500, 100, 360100), "HOME=/root\x00DISPLAY=:51.0\x00"},
+		"1570":  {"/usr/bin/xterm\x00", "0", statOf(1570, "xterm", 300, 0, 360200), "DISPLAY=localhost:51.0\x00"},
+		"2050":  {"/opt/TurboVNC/bin/Xvnc\x00:9\x00-desktop\x00TurboVNC\x00", "54321", statOf(2050, "Xvnc", 10, 0, 720000), ""},
+		"2862":  {"/usr/bin/vncserver\x00:11\x00", "0", "", ""},
+		"3001":  {"/usr/bin/Xtigervnc\x00-rfbport\x005901\x00", "0", "", ""},
+		"self":  {"/usr/bin/Xvnc\x00:1\x00", "0", "", ""},
+		"4096":  {"/usr/bin/bash\x00", "0", "", "DISPLAY=:3\x00"},
+		"10240": {"/usr/bin/Xtigervnc\x00:3\x00-localhost\x00", "0", "", ""},
+	}
+
+	// btime is the boot time of the system in the proc filesystem.
+	btime = time.Date(2024, 11, 1, 8, 0, 0, 0, time.UTC)
+)
+
+// statOf returns the `stat` file content of a process with the CPU and start time in clock
+// ticks.
+func statOf(pid int, comm string, utime, stime, start int) string {
+	return fmt.Sprintf("%d (%s) S 1 %d %d 0 -1 4194560 100 0 0 0 %d %d 0 0 20 0 1 0 %d 123456 789 18446744073709551615",
+		pid, comm, pid, pid, utime, stime, start)
+}
+
+// mkProcDir makes a proc filesystem with the `procs` in a temporary directory.
+func mkProcDir(t *testing.T) string {
+
+	dir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, "stat"), []byte(fmt.Sprintf("cpu  1 2 3 4\nbtime %d\nprocesses 1234\n", btime.Unix())), 0644); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for pid, p := range procs {
+		files := map[string]string{
+			"cmdline": p[0],
+			"status":  "Name:\tXvnc\nUid:\t" + p[1] + "\t" + p[1] + "\t" + p[1] + "\t" + p[1] + "\n",
+			"stat":    p[2],
+			"environ": p[3],
+		}
+		if err := os.MkdirAll(filepath.Join(dir, pid), 0755); err != nil {
+			t.Fatalf("%s", err)
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, pid, name), []byte(content), 0644); err != nil {
+				t.Fatalf("%s", err)
+			}
+		}
+	}
+
+	return dir
+}
+
+func TestListProcServers(t *testing.T) {
+
+	// the VNC log file in the home directory of the owner
+	home := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(home, ".vnc"), 0755); err != nil {
+		t.Fatalf("%s", err)
+	}
+	logFile := filepath.Join(home, ".vnc", "mentat001.dccn.nl:51.log")
+	if err := os.WriteFile(logFile, []byte("Connections: accepted: 10.0.0.1::51234\n"), 0644); err != nil {
+		t.Fatalf("%s", err)
+	}
+	lastActive := time.Date(2024, 11, 20, 9, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(logFile, lastActive, lastActive); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	defer func(f func(string) string) { userHome = f }(userHome)
+	userHome = func(uid string) string {
+		if uid == "0" {
+			return home
+		}
+		return ""
+	}
+
+	servers, err := ListProcServers(mkProcDir(t), "mentat001.dccn.nl")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for _, s := range servers {
+		t.Logf("vnc server: %+v", s)
+	}
+
+	// the wrapper script, the server without display and the non-numeric entries are skipped
+	if len(servers) != 3 {
+		t.Fatalf("expect 3 VNC servers, got %d", len(servers))
+	}
+
+	if s := servers[0]; s.ID != "mentat001.dccn.nl:51" || s.Owner != "root" || s.PID != 1552 {
+		t.Errorf("unexpected VNC server: %+v", s)
+	}
+
+	// the CPU time of the server and the desktop on the display, but not the xterm of the SSH
+	// X11 forwarding
+	if s := servers[0]; s.CPUTime != 18*time.Second || !s.StartTime.Equal(btime.Add(time.Hour)) || !s.LastActive.Equal(lastActive) {
+		t.Errorf("unexpected VNC server statistics: %+v", s)
+	}
+
+	// the uid is given if the user is unknown
+	if s := servers[1]; s.ID != "mentat001.dccn.nl:9" || s.Owner != "54321" {
+		t.Errorf("unexpected VNC server: %+v", s)
+	}
+
+	if s := servers[2]; s.ID != "mentat001.dccn.nl:3" || s.PID != 10240 {
+		t.Errorf("unexpected VNC server: %+v", s)
+	}
+}
+
+func TestLastLogTime(t *testing.T) {
+
+	// the home directory shared by the access nodes, with the logs of the display 51 on two hosts
+	home := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(home, ".vnc"), 0755); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	logs := map[string]time.Time{
+		"mentat001.dccn.nl:51.log": time.Date(2024, 11, 18, 9, 0, 0, 0, time.UTC),
+		"mentat001:51.log":         time.Date(2024, 11, 19, 9, 0, 0, 0, time.UTC),
+		"mentat002.dccn.nl:51.log": time.Date(2024, 11, 20, 9, 0, 0, 0, time.UTC),
+		"mentat001.dccn.nl:5.log":  time.Date(2024, 11, 21, 9, 0, 0, 0, time.UTC),
+	}
+	for name, mtime := range logs {
+		f := filepath.Join(home, ".vnc", name)
+		if err := os.WriteFile(f, []byte{}, 0644); err != nil {
+			t.Fatalf("%s", err)
+		}
+		if err := os.Chtimes(f, mtime, mtime); err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	cases := []struct {
+		host    string
+		display int
+		last    time.Time
+	}{
+		{"mentat001.dccn.nl", 51, logs["mentat001:51.log"]},
+		{"mentat001", 51, logs["mentat001:51.log"]},
+		{"mentat002.dccn.nl", 51, logs["mentat002.dccn.nl:51.log"]},
+		{"mentat003.dccn.nl", 51, time.Time{}},
+	}
+
+	for _, c := range cases {
+		if last := lastLogTime(home, c.host, c.display); !last.Equal(c.last) {
+			t.Errorf("%s:%d: expect %s, got %s", c.host, c.display, c.last, last)
+		}
+	}
+}
+
+func TestListSlurmServers(t *testing.T) {
+
+	// replay the recorded `squeue` outputs
+	defer func(r util.Runner) { util.DefaultRunner = r }(util.DefaultRunner)
+	util.DefaultRunner = &util.ReplayRunner{Dir: "../../testdata/exec"}
+
+	servers, err := ListSlurmServers(context.Background(), "dccn.nl")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(servers) != 2 {
+		t.Fatalf("expect 2 VNC servers, got %d", len(servers))
+	}
+
+	if s := servers[0]; s.ID != "dccn-c083.dccn.nl:5" || s.Owner != "user1" || s.JobID != "4330" || s.StartTime.Day() != 11 {
+		t.Errorf("unexpected VNC server: %+v", s)
+	}
+
+	// the display is taken from the job command
+	if s := servers[1]; s.ID != "dccn-c084.dccn.nl:12" || s.Owner != "user2" || s.JobID != "4331" {
+		t.Errorf("unexpected VNC server: %+v", s)
+	}
+}